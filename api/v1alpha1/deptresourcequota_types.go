@@ -26,6 +26,7 @@ type DeptResourceQuotaStatusReason string
 const (
 	ErrorComputingQuotaResource DeptResourceQuotaStatusReason = "ErrorComputingQuotaResource"
 	ComputingQuotaResource      DeptResourceQuotaStatusReason = "Updated"
+	QuotaResourceExceeded       DeptResourceQuotaStatusReason = "QuotaExceeded"
 )
 
 // WkResources defines the company department resource limits
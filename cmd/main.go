@@ -1,15 +1,24 @@
 package main
 
 import (
+	"time"
+
 	log "github.com/sirupsen/logrus"
 	"k8s-admin-informer/pkg/app"
+	"k8s-admin-informer/pkg/logging"
+	"k8s-admin-informer/pkg/tracing"
 )
 
 func main() {
-	// 设置日志级别
-	log.SetLevel(log.InfoLevel)
+	// 日志格式与级别由 LOG_FORMAT / LOG_LEVEL 配置
+	logging.Setup()
 	//gin.SetMode(gin.ReleaseMode)
 
+	// 初始化 trace 导出，带 context 的日志附带 trace_id
+	shutdown := tracing.Init()
+	defer tracing.Shutdown(shutdown, 5*time.Second)
+	log.AddHook(tracing.LogHook{})
+
 	// 创建路由
 	server := app.NewK8sAdminInformerApp()
 
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"k8s-admin-informer/pkg/handler"
+	"k8s-admin-informer/pkg/logging"
+	"k8s-admin-informer/pkg/model"
+)
+
+// certReloader 在证书文件更新后重新加载，配合 cert-manager 等轮换证书时无需重启
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, err := os.Stat(r.certFile)
+	if err == nil && r.cert != nil && !info.ModTime().After(r.modTime) {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			log.Warnf("重新加载准入证书失败，继续使用旧证书: %v", err)
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	r.cert = &cert
+	if info != nil {
+		r.modTime = info.ModTime()
+	}
+	return r.cert, nil
+}
+
+// startAdmissionServer 在 ADMISSION_WEBHOOK_ENABLED=true 时以 TLS 启动 validating admission webhook：
+// - ADMISSION_LISTEN_ADDR 监听地址，默认 :8443
+// - ADMISSION_TLS_CERT_FILE / ADMISSION_TLS_KEY_FILE 证书路径，默认 /app/webhook-certs/tls.crt、tls.key
+func (a *App) startAdmissionServer() error {
+	if os.Getenv("ADMISSION_WEBHOOK_ENABLED") != "true" {
+		return nil
+	}
+	addr := os.Getenv("ADMISSION_LISTEN_ADDR")
+	if addr == "" {
+		addr = ":8443"
+	}
+	reloader := &certReloader{
+		certFile: os.Getenv("ADMISSION_TLS_CERT_FILE"),
+		keyFile:  os.Getenv("ADMISSION_TLS_KEY_FILE"),
+	}
+	if reloader.certFile == "" {
+		reloader.certFile = "/app/webhook-certs/tls.crt"
+	}
+	if reloader.keyFile == "" {
+		reloader.keyFile = "/app/webhook-certs/tls.key"
+	}
+	// 启动前先加载一次，证书缺失时直接报错
+	if _, err := reloader.getCertificate(nil); err != nil {
+		log.Errorf("加载准入证书失败：%v", err)
+		return err
+	}
+
+	admission := handler.NewAdmissionHandler(a.rscHandler)
+	engine := gin.New()
+	engine.Use(logging.AccessLog(), gin.Recovery(), logging.RequestID())
+	engine.POST(model.AdmissionValidatePath, admission.Validate)
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: engine,
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.getCertificate,
+		},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		log.Infof("准入 webhook 监听 %s%s", addr, model.AdmissionValidatePath)
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			log.Errorf("准入 webhook 退出：%v", err)
+		}
+	}()
+	return nil
+}
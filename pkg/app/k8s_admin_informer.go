@@ -1,13 +1,19 @@
 package app
 
 import (
-	"time"
+	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 
+	"k8s-admin-informer/pkg/auth"
+	"k8s-admin-informer/pkg/authz"
 	"k8s-admin-informer/pkg/handler"
+	"k8s-admin-informer/pkg/logging"
+	"k8s-admin-informer/pkg/model"
+	"k8s-admin-informer/pkg/tracing"
 )
 
 type App struct {
@@ -16,6 +22,8 @@ type App struct {
 	baseHandler     *handler.Handler
 	workloadHandler *handler.WorkloadHandler
 	rscHandler      *handler.ResourceHandler
+	authenticator   *auth.Authenticator
+	authorizer      *authz.Authorizer
 }
 
 func NewK8sAdminInformerApp() *App {
@@ -23,66 +31,94 @@ func NewK8sAdminInformerApp() *App {
 	if err != nil {
 		panic(err)
 	}
+	rscHandler := handler.NewResourceHandler(baseHandler)
+	prometheus.MustRegister(handler.NewDeptCollector(rscHandler), handler.NewStateCollector(rscHandler))
 	return &App{
-		engine:          gin.Default(),
+		engine:          gin.New(),
 		baseHandler:     baseHandler,
 		workloadHandler: handler.NewWorkloadHandler(baseHandler),
-		rscHandler:      handler.NewResourceHandler(baseHandler),
+		rscHandler:      rscHandler,
+		authenticator:   handler.NewAuthenticator(baseHandler),
+		authorizer:      handler.NewAuthorizer(baseHandler),
 	}
 }
 
 func (a *App) registerRoute() {
+	// 以 JSON 输出带请求 ID 的访问日志并从 panic 中恢复，按路由统计请求数、状态码与耗时，
+	// 为每个请求创建 span 并分配请求 ID，启用时校验调用方身份并限定可见部门，写请求记入审计日志
+	a.engine.Use(logging.AccessLog(), gin.Recovery(), handler.HTTPMetrics(), tracing.Middleware(), logging.RequestID(),
+		a.authenticator.Middleware(), a.authorizer.Middleware(), a.rscHandler.AuditMiddleware())
 	// 查询工作负载后面的pod和event
-	a.engine.POST("/informer/v1/getWorkloadInstance", a.workloadHandler.GetWorkloadInstance)
+	a.engine.POST(model.GetWorkloadInstancePath, a.workloadHandler.GetWorkloadInstance)
 	// 检查当前请求资源是否超过部门配额
-	a.engine.POST("/informer/v1/resource/dept/checkLimit", a.rscHandler.ComputeDeptResourceQuotaLimit)
+	a.engine.POST(model.DeptCheckLimitPath, a.rscHandler.ComputeDeptResourceQuotaLimit)
+	// 根据 Deployment/StatefulSet 清单或副本增量检查部门配额
+	a.engine.POST(model.DeptCheckManifestPath, a.rscHandler.CheckDeptLimitByManifest)
+	// 查询与释放配额预留
+	a.engine.GET(model.DeptReservationsPath, a.rscHandler.ListReservations)
+	a.engine.DELETE(model.DeptReservationPath, a.rscHandler.ReleaseReservation)
+	// 查询近期的配额校验与写操作审计记录
+	a.engine.GET(model.AuditRecordsPath, a.rscHandler.ListAuditRecords)
+	// 资源接口在响应头中报告实时用量的来源与新鲜度，并按 Accept-Encoding 压缩响应
+	usage := a.rscHandler.UsageHeaders()
+	compress := handler.Compress()
 	// 获取节点资源
-	a.engine.GET("/informer/v1/resource/node", a.rscHandler.NodeResources)
+	a.engine.GET(model.NodeResourcePath, compress, usage, a.rscHandler.NodeResources)
 	// 获取部门资源
-	a.engine.GET("/informer/v1/resource/dept", a.rscHandler.DeptResources)
+	a.engine.GET(model.DeptResourcePath, compress, usage, a.rscHandler.DeptResources)
 	// 获取集群资源
-	a.engine.GET("/informer/v1/resource/cluster", a.rscHandler.ClusterResources)
+	a.engine.GET(model.ClusterResourcePath, compress, usage, a.rscHandler.ClusterResources)
 	// 获取部门资源
-	a.engine.GET("/informer/v1/resource/env", a.rscHandler.EnvResources)
+	a.engine.GET(model.EnvResourcePath, compress, usage, a.rscHandler.EnvResources)
+	// v2 资源接口：按节点类型给出配额、用量、requests/limits 的数值与规范化字符串
+	a.engine.GET(model.NodeResourceV2Path, compress, usage, a.rscHandler.NodeResourcesV2)
+	a.engine.GET(model.DeptResourceV2Path, compress, usage, a.rscHandler.DeptResourcesV2)
+	a.engine.GET(model.ClusterResourceV2Path, compress, usage, a.rscHandler.ClusterResourcesV2)
+	a.engine.GET(model.EnvResourceV2Path, compress, usage, a.rscHandler.EnvResourcesV2)
+	// 部门成本报表
+	a.engine.GET(model.CostReportPath, compress, a.rscHandler.CostReport)
+	// 运行时日志级别，启用授权时仅管理员可调用
+	a.engine.GET(model.AdminLogLevelPath, authz.RequireAdmin(), handler.GetLogLevel)
+	a.engine.PUT(model.AdminLogLevelPath, authz.RequireAdmin(), handler.SetLogLevel)
+	// 存活与就绪检查
+	a.engine.GET(model.HealthzPath, handler.Healthz)
+	a.engine.GET(model.ReadyzPath, a.baseHandler.Readyz)
 	// prometheus metrics
-	a.engine.GET("/metrics", a.prometheusHandler())
+	a.engine.GET(model.MetricsPath, a.prometheusHandler())
+	// OpenAPI 文档与 Swagger UI
+	a.registerOpenAPI()
 }
 
 func (a *App) Run() error {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				a.rscHandler.ProbeDeptResource()
-			}
-		}
-	}()
-
-    // 同步启动 informer，完成后注册事件并进行聚合预热
-    if err := a.baseHandler.Start(); err != nil {
-        log.Errorf("启动informer出现异常：%v", err)
-        return err
-    }
-    a.rscHandler.EnableEventDrivenInvalidation()
-    a.rscHandler.SeedNodeAggFromInformer()
+	// 同步启动 informer，完成后注册事件并进行聚合预热
+	if err := a.baseHandler.Start(); err != nil {
+		log.Errorf("启动informer出现异常：%v", err)
+		return err
+	}
+	a.rscHandler.EnableEventDrivenInvalidation()
+	a.rscHandler.SeedNodeAggFromInformer()
+	a.rscHandler.StartUsageRefresh()
+	a.rscHandler.StartReservations()
+	a.rscHandler.StartCostAccounting()
+	// 可选：选主后回写 DeptResourceQuota status
+	if os.Getenv("QUOTA_STATUS_CONTROLLER_ENABLED") == "true" {
+		handler.NewQuotaStatusController(a.rscHandler).Start()
+	}
+	if err := a.startAdmissionServer(); err != nil {
+		return err
+	}
 
-    // 注册路由
-    a.registerRoute()
+	// 注册路由
+	a.registerRoute()
 
-    err := a.engine.Run(":8080")
+	err := a.engine.Run(":8080")
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// prometheusHandler 部门指标由 DeptCollector 在抓取时从缓存生成
 func (a *App) prometheusHandler() gin.HandlerFunc {
-	h := promhttp.Handler()
-
-	return func(context *gin.Context) {
-		a.rscHandler.ProbeDeptResource()
-		h.ServeHTTP(context.Writer, context.Request)
-	}
+	return gin.WrapH(promhttp.Handler())
 }
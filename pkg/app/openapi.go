@@ -0,0 +1,342 @@
+package app
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"k8s-admin-informer/pkg/model"
+	"k8s-admin-informer/pkg/openapi"
+)
+
+// cacheQuery 资源查询接口共用的缓存控制参数
+var cacheQuery = []openapi.Param{
+	{Name: "refresh", Type: "boolean", Description: "true 时强制同步重算，忽略缓存"},
+	{Name: "maxAge", Description: "缓存最大可接受时长（Go duration，如 10s、1m），超过则重算"},
+	{Name: "minVersion", Type: "integer", Description: "最低快照版本，当前版本更低时最多等待 SNAPSHOT_MAX_WAIT"},
+}
+
+// formatParam 可导出报表的接口的输出格式参数
+var formatParam = openapi.Param{Name: "format", Description: "输出格式：json（默认）、yaml、csv 或 xlsx，也可通过 Accept 请求头协商；csv/xlsx 将按节点类型嵌套的字段展开为列，数量同时给出原始值与数值（核数、字节数）"}
+
+// reportQuery 部门/节点资源接口的查询参数
+var reportQuery = append(append([]openapi.Param{}, cacheQuery...), formatParam)
+
+// apiRoutes 描述 registerRoute 中注册的全部接口，新增路由时需同步补充
+func apiRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:      http.MethodPost,
+			Path:        model.GetWorkloadInstancePath,
+			OperationID: "getWorkloadInstance",
+			Summary:     "查询工作负载后面的 pod、event 与 service",
+			Description: "启用部门授权时，任一工作负载所属部门对调用方不可见则返回 403",
+			Tags:        []string{"workload"},
+			Request:     model.GetWorkloadInstanceRequest{},
+			Responses: map[int]interface{}{
+				http.StatusOK:         model.GetWorkloadInstanceResponse{},
+				http.StatusBadRequest: model.ErrorResponse{},
+				http.StatusForbidden:  model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        model.DeptCheckLimitPath,
+			OperationID: "checkDeptLimit",
+			Summary:     "检查当前请求资源是否超过部门配额",
+			Description: "按节点类型逐项校验 cpu/memory/pods：memory 未设置配额时视为 0，且已用 + 已预留 + 申请达到配额即不通过；cpu/pods 未设置配额时不限制，恰好用满配额仍通过。请求非法时返回 400 与 error；校验未通过时返回 400 且 success=false，reason 给出原因；checks 列出每一项的配额、已用、已预留、申请、剩余额度与结论；带 reserve 且校验通过时返回 reservation；启用部门授权时部门对调用方不可见返回 403",
+			Tags:        []string{"resource"},
+			Request:     model.DeptResourceQuotaRequest{},
+			Responses: map[int]interface{}{
+				http.StatusOK:         model.DeptResourceQuotaResponse{},
+				http.StatusBadRequest: model.DeptResourceQuotaResponse{},
+				http.StatusForbidden:  model.ErrorResponse{},
+				http.StatusNotFound:   model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        model.DeptCheckManifestPath,
+			OperationID: "checkDeptLimitByManifest",
+			Summary:     "根据工作负载清单或副本增量检查部门配额",
+			Description: "manifest 为完整的 apps/v1 Deployment 或 StatefulSet（JSON 对象或 JSON/YAML 文本），也可直接以 application/yaml 提交清单并通过 dept 查询参数指定部门；" +
+				"workload+replicaDelta 表示对缓存中已有工作负载扩缩容。申请量为 副本数 ×（应用容器 limits 之和与 init 容器峰值取大），" +
+				"已存在的工作负载按新旧差值计算，节点类型由 nodeSelector / nodeAffinity 推断；启用部门授权时部门对调用方不可见返回 403",
+			Tags:    []string{"resource"},
+			Request: model.ManifestQuotaRequest{},
+			Query: []openapi.Param{
+				{Name: "dept", Description: "以 YAML 提交清单时指定部门"},
+			},
+			Responses: map[int]interface{}{
+				http.StatusOK:         model.ManifestQuotaResponse{},
+				http.StatusBadRequest: model.ManifestQuotaResponse{},
+				http.StatusForbidden:  model.ErrorResponse{},
+				http.StatusNotFound:   model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.DeptReservationsPath,
+			OperationID: "listDeptReservations",
+			Summary:     "列出未过期的配额预留",
+			Description: "checkLimit 与 checkLimit/manifest 请求带 reserve 且校验通过时生成预留，后续检查把预留量计入已用；" +
+				"预留在显式释放、过期或匹配的 Pod 出现在缓存中时释放，并持久化在 ConfigMap 中；" +
+				"启用部门授权时只返回调用方可见部门的预留，指定不可见的部门返回 403",
+			Tags:  []string{"resource"},
+			Query: []openapi.Param{{Name: "dept", Description: "部门名，缺省返回全部可见部门"}},
+			Responses: map[int]interface{}{
+				http.StatusOK:        model.ReservationList{},
+				http.StatusForbidden: model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodDelete,
+			Path:        model.DeptReservationPath,
+			OperationID: "releaseDeptReservation",
+			Summary:     "释放配额预留",
+			Description: "启用部门授权时，预留所属部门对调用方不可见则返回 403",
+			Tags:        []string{"resource"},
+			Responses: map[int]interface{}{
+				http.StatusNoContent: nil,
+				http.StatusForbidden: model.ErrorResponse{},
+				http.StatusNotFound:  model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.AuditRecordsPath,
+			OperationID: "listAuditRecords",
+			Summary:     "按时间倒序查询近期审计记录",
+			Description: "checkLimit、checkLimit/manifest 与准入校验的输入、校验时的配额与用量、结论和调用方，以及其他写操作；" +
+				"只包含内存中保留的最近记录，完整记录见审计日志文件。启用部门授权时只返回调用方可见部门的记录",
+			Tags: []string{"ops"},
+			Query: []openapi.Param{
+				{Name: "dept", Description: "部门名，缺省返回全部"},
+				{Name: "action", Description: "checkLimit、checkManifest、admission 或 write"},
+				{Name: "since", Description: "起始时间（RFC3339）或回溯时长（如 24h）"},
+				{Name: "limit", Description: "最多返回条数，默认 100"},
+			},
+			Responses: map[int]interface{}{
+				http.StatusOK:         model.AuditRecordList{},
+				http.StatusBadRequest: model.ErrorResponse{},
+				http.StatusForbidden:  model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.NodeResourcePath,
+			OperationID: "listNodeResources",
+			Summary:     "获取节点资源",
+			Description: "响应头 X-Snapshot-Version 为快照版本，X-Generated-At 为生成时间，X-Cache 为 HIT 或 MISS；ETag 与 Last-Modified 随快照变化，If-None-Match/If-Modified-Since 命中时返回 304",
+			Tags:        []string{"resource"},
+			Query:       reportQuery,
+			Responses: map[int]interface{}{
+				http.StatusOK:            model.NodeList{},
+				http.StatusBadRequest:    model.ErrorResponse{},
+				http.StatusNotModified:   nil,
+				http.StatusNotAcceptable: model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.DeptResourcePath,
+			OperationID: "listDeptResources",
+			Summary:     "获取部门资源",
+			Description: "启用部门授权时只返回调用方可见的部门；响应头 X-Snapshot-Version 为快照版本，X-Generated-At 为生成时间，X-Cache 为 HIT 或 MISS；ETag 与 Last-Modified 随快照变化，If-None-Match/If-Modified-Since 命中时返回 304",
+			Tags:        []string{"resource"},
+			Query:       reportQuery,
+			Responses: map[int]interface{}{
+				http.StatusOK:            []model.DeptResource{},
+				http.StatusBadRequest:    model.ErrorResponse{},
+				http.StatusNotModified:   nil,
+				http.StatusNotAcceptable: model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.ClusterResourcePath,
+			OperationID: "getClusterResources",
+			Summary:     "按节点类型汇总集群实时用量与 Pod requests/limits",
+			Description: "used 为节点实时用量之和，requests/limits 为已调度且未结束的 Pod 的有效 requests/limits 之和，均按节点类型给出 cpu 与 memory；" +
+				"旧字段 nonXcLimitsResources/xcLimitsResources 保留，仅含 limits 内存",
+			Tags: []string{"resource"},
+			Responses: map[int]interface{}{
+				http.StatusOK: model.ClusterResource{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.EnvResourcePath,
+			OperationID: "listEnvResources",
+			Summary:     "获取部门下各环境（namespaceGroup）资源",
+			Tags:        []string{"resource"},
+			Query: []openapi.Param{
+				{Name: "dept", Required: true, Description: "部门名称"},
+				formatParam,
+			},
+			Responses: map[int]interface{}{
+				http.StatusOK:            map[string]model.EnvResource{},
+				http.StatusBadRequest:    model.ErrorResponse{},
+				http.StatusForbidden:     model.ErrorResponse{},
+				http.StatusNotAcceptable: model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.NodeResourceV2Path,
+			OperationID: "listNodeResourcesV2",
+			Summary:     "获取节点容量、可分配量、实时用量与 Pod requests/limits",
+			Tags:        []string{"resource-v2"},
+			Responses: map[int]interface{}{
+				http.StatusOK: model.NodeListV2{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.DeptResourceV2Path,
+			OperationID: "listDeptResourcesV2",
+			Summary:     "按节点类型获取部门配额、已宣布用量、实时用量与 requests/limits",
+			Description: "启用部门授权时只返回调用方可见的部门，指定不可见的部门返回 403",
+			Tags:        []string{"resource-v2"},
+			Query: []openapi.Param{
+				{Name: "dept", Description: "只返回指定部门"},
+			},
+			Responses: map[int]interface{}{
+				http.StatusOK:        []model.DeptResourceV2{},
+				http.StatusForbidden: model.ErrorResponse{},
+				http.StatusNotFound:  model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.ClusterResourceV2Path,
+			OperationID: "getClusterResourcesV2",
+			Summary:     "按节点类型汇总集群资源",
+			Tags:        []string{"resource-v2"},
+			Responses: map[int]interface{}{
+				http.StatusOK: model.ClusterResourceV2{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.EnvResourceV2Path,
+			OperationID: "listEnvResourcesV2",
+			Summary:     "按节点类型获取部门下各环境资源",
+			Tags:        []string{"resource-v2"},
+			Query: []openapi.Param{
+				{Name: "dept", Required: true, Description: "部门名称"},
+			},
+			Responses: map[int]interface{}{
+				http.StatusOK:         []model.EnvResourceV2{},
+				http.StatusBadRequest: model.ErrorResponse{},
+				http.StatusForbidden:  model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.CostReportPath,
+			OperationID: "getCostReport",
+			Summary:     "部门成本报表",
+			Description: "按成本模型（COST_MODEL_FILE）中各节点类型的单价与计费依据，汇总一段时间内部门、环境或命名空间的 cpu/memory 成本；" +
+				"与时间段部分重叠的小时/天桶按重叠比例计入。未配置成本模型时返回 404。启用部门授权时只返回调用方可见的部门，未分摊的闲置成本仅管理员可见",
+			Tags: []string{"cost"},
+			Query: []openapi.Param{
+				{Name: "from", Description: "起始时间（RFC3339）或回溯时长（如 720h），默认 24h"},
+				{Name: "to", Description: "结束时间（RFC3339），默认当前时间"},
+				{Name: "groupBy", Description: "dept（默认）、env 或 namespace"},
+				{Name: "dept", Description: "只返回该部门"},
+				{Name: "idle", Description: "none、separate（默认，单独列出闲置成本）或 split（按各项成本占比分摊闲置成本）"},
+				formatParam,
+			},
+			Responses: map[int]interface{}{
+				http.StatusOK:            model.CostReport{},
+				http.StatusBadRequest:    model.ErrorResponse{},
+				http.StatusForbidden:     model.ErrorResponse{},
+				http.StatusNotFound:      model.ErrorResponse{},
+				http.StatusNotAcceptable: model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.AdminLogLevelPath,
+			OperationID: "getLogLevel",
+			Summary:     "查询当前日志级别",
+			Description: "启用部门授权时仅管理员可调用",
+			Tags:        []string{"ops"},
+			Responses: map[int]interface{}{
+				http.StatusOK:        model.LogLevel{},
+				http.StatusForbidden: model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodPut,
+			Path:        model.AdminLogLevelPath,
+			OperationID: "setLogLevel",
+			Summary:     "运行时调整日志级别",
+			Description: "立即生效，重启后恢复为 LOG_LEVEL；启用部门授权时仅管理员可调用",
+			Tags:        []string{"ops"},
+			Request:     model.LogLevel{},
+			Responses: map[int]interface{}{
+				http.StatusOK:         model.LogLevel{},
+				http.StatusBadRequest: model.ErrorResponse{},
+				http.StatusForbidden:  model.ErrorResponse{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.HealthzPath,
+			OperationID: "healthz",
+			Summary:     "存活检查",
+			Tags:        []string{"ops"},
+			Responses: map[int]interface{}{
+				http.StatusOK: model.HealthStatus{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.ReadyzPath,
+			OperationID: "readyz",
+			Summary:     "就绪检查",
+			Description: "全部 informer 完成同步时返回 200，否则返回 503",
+			Tags:        []string{"ops"},
+			Responses: map[int]interface{}{
+				http.StatusOK:                 model.HealthStatus{},
+				http.StatusServiceUnavailable: model.HealthStatus{},
+			},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        model.MetricsPath,
+			OperationID: "metrics",
+			Summary:     "Prometheus 指标",
+			Tags:        []string{"ops"},
+			ContentType: "text/plain",
+			Responses: map[int]interface{}{
+				http.StatusOK: nil,
+			},
+		},
+	}
+}
+
+// Spec 返回本服务的 OpenAPI 文档，与 /openapi.json 提供的内容一致
+func Spec() *openapi.Document {
+	return openapi.Build(openapi.Info{
+		Title:       "k8s-admin-informer",
+		Description: "基于 informer 缓存的工作负载与部门资源查询服务",
+		Version:     "v2",
+	}, apiRoutes())
+}
+
+// registerOpenAPI 挂载 OpenAPI 文档与 Swagger UI，并检查已注册路由是否都有文档
+func (a *App) registerOpenAPI() {
+	doc := Spec()
+
+	for _, r := range a.engine.Routes() {
+		if !doc.Has(r.Method, r.Path) {
+			log.Warnf("路由 %s %s 未在 OpenAPI 文档中描述", r.Method, r.Path)
+		}
+	}
+
+	a.engine.GET(model.OpenAPIPath, openapi.SpecHandler(doc))
+	openapi.RegisterSwaggerUI(a.engine, model.SwaggerUIPrefix, model.OpenAPIPath)
+}
@@ -0,0 +1,187 @@
+// Package audit 记录配额校验结论与写操作：每条记录包含输入、校验时的配额与用量、结论和调用方，
+// 写入按大小轮转的本地 JSON Lines 文件与可选的 HTTP 接收端，并在内存中保留最近的记录供查询。
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"k8s-admin-informer/pkg/auth"
+	"k8s-admin-informer/pkg/logging"
+	"k8s-admin-informer/pkg/model"
+)
+
+// 审计动作
+const (
+	ActionCheckLimit    = "checkLimit"
+	ActionCheckManifest = "checkManifest"
+	ActionAdmission     = "admission"
+	ActionWrite         = "write"
+)
+
+// recordedKey gin context 中标记本次请求已写入审计记录，写操作中间件不再重复记录
+const recordedKey = "audit.recorded"
+
+// Config 审计配置
+type Config struct {
+	// File 本地 JSON Lines 文件，为空时不写文件
+	File string
+	// MaxSize 文件超过该字节数时轮转
+	MaxSize int64
+	// MaxBackups 保留的历史文件数，历史文件为 File.1 … File.N
+	MaxBackups int
+	// SinkURL HTTP 接收端，记录按批以 JSON 数组 POST，为空时不发送
+	SinkURL string
+	// SinkBuffer 等待发送的记录上限，超过时丢弃
+	SinkBuffer int
+	// SinkTimeout 单次发送超时
+	SinkTimeout time.Duration
+	// Memory 内存中保留供查询的最近记录数
+	Memory int
+}
+
+// Logger 并发安全的审计记录器
+type Logger struct {
+	file *rotatingFile
+	sink *httpSink
+
+	mu     sync.Mutex
+	recent []model.AuditRecord
+	next   int
+	full   bool
+}
+
+// New 创建审计记录器，文件无法打开时只记录错误，仍保留内存记录与 HTTP 接收端
+func New(cfg Config) *Logger {
+	l := &Logger{}
+	if cfg.Memory > 0 {
+		l.recent = make([]model.AuditRecord, cfg.Memory)
+	}
+	if cfg.File != "" {
+		f, err := openRotatingFile(cfg.File, cfg.MaxSize, cfg.MaxBackups)
+		if err != nil {
+			log.Errorf("打开审计日志 %s 失败，不写入文件: %v", cfg.File, err)
+		} else {
+			l.file = f
+		}
+	}
+	if cfg.SinkURL != "" {
+		l.sink = newHTTPSink(cfg.SinkURL, cfg.SinkBuffer, cfg.SinkTimeout)
+	}
+	return l
+}
+
+// Log 写入一条记录，Time 为空时取当前时间
+func (l *Logger) Log(rec model.AuditRecord) {
+	if l == nil {
+		return
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	auditRecords.WithLabelValues(rec.Action).Inc()
+	if l.file != nil {
+		if err := l.file.write(rec); err != nil {
+			auditErrors.WithLabelValues("file").Inc()
+			log.Errorf("写入审计日志失败: %v", err)
+		}
+	}
+	if l.sink != nil {
+		l.sink.enqueue(rec)
+	}
+	if len(l.recent) > 0 {
+		l.mu.Lock()
+		l.recent[l.next] = rec
+		l.next = (l.next + 1) % len(l.recent)
+		if l.next == 0 {
+			l.full = true
+		}
+		l.mu.Unlock()
+	}
+}
+
+// Record 补充请求 ID、调用方、方法、路径与状态码后写入记录，并标记本次请求已审计
+func (l *Logger) Record(c *gin.Context, status int, rec model.AuditRecord) {
+	rec.RequestID = logging.RequestIDFrom(c.Request.Context())
+	rec.Caller = callerOf(c)
+	rec.Method = c.Request.Method
+	rec.Path = c.Request.URL.Path
+	rec.Status = status
+	c.Set(recordedKey, true)
+	l.Log(rec)
+}
+
+func callerOf(c *gin.Context) model.AuditCaller {
+	caller := model.AuditCaller{RemoteAddr: c.ClientIP()}
+	if user, ok := auth.UserFrom(c.Request.Context()); ok {
+		caller.Name = user.Name
+		caller.Groups = user.Groups
+		caller.Source = user.Source
+	}
+	return caller
+}
+
+// Query 查询条件，字段为空表示不限
+type Query struct {
+	Dept   string
+	Action string
+	Since  time.Time
+	// Allow 返回 false 的部门不返回，为 nil 时不限
+	Allow func(dept string) bool
+	Limit int
+}
+
+// Query 按时间倒序返回内存中匹配的记录
+func (l *Logger) Query(q Query) []model.AuditRecord {
+	out := make([]model.AuditRecord, 0)
+	if l == nil || len(l.recent) == 0 {
+		return out
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.next
+	if l.full {
+		n = len(l.recent)
+	}
+	for i := 0; i < n; i++ {
+		rec := l.recent[(l.next-1-i+len(l.recent))%len(l.recent)]
+		if (q.Dept != "" && rec.Dept != q.Dept) || (q.Action != "" && rec.Action != q.Action) ||
+			rec.Time.Before(q.Since) || (q.Allow != nil && !q.Allow(rec.Dept)) {
+			continue
+		}
+		out = append(out, rec)
+		if q.Limit > 0 && len(out) >= q.Limit {
+			break
+		}
+	}
+	return out
+}
+
+// Middleware 记录处理器未单独审计的写请求（GET/HEAD/OPTIONS 之外的方法），部门取自 dept 查询参数
+func (l *Logger) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		if c.GetBool(recordedKey) || l == nil {
+			return
+		}
+		l.Record(c, c.Writer.Status(), model.AuditRecord{Action: ActionWrite, Dept: c.Query("dept")})
+	}
+}
+
+// Input 将校验输入编码为记录的 Request 字段，编码失败时为空
+func Input(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,188 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"k8s-admin-informer/pkg/model"
+	"k8s-admin-informer/pkg/tracing"
+)
+
+var (
+	auditRecords = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "audit_records_total",
+			Help: "Audit records written by action.",
+		},
+		[]string{"action"},
+	)
+	auditErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "audit_errors_total",
+			Help: "Audit records that could not be written or delivered by sink (file or http).",
+		},
+		[]string{"sink"},
+	)
+	auditDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "audit_records_dropped_total",
+			Help: "Audit records dropped because the HTTP sink buffer was full.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(auditRecords, auditErrors, auditDropped)
+}
+
+// rotatingFile 按大小轮转的 JSON Lines 文件
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) write(rec model.AuditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(line)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.f.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+// rotate 依次将 path.N-1 … path.1 后移一位，当前文件改名为 path.1 后重新打开
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.maxBackups <= 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxBackups))
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// sinkBatchSize 单次发送的最大记录数
+const sinkBatchSize = 100
+
+// httpSink 异步将记录按批 POST 到接收端，发送失败时丢弃该批并计数，不阻塞请求处理
+type httpSink struct {
+	url    string
+	client *http.Client
+	queue  chan model.AuditRecord
+}
+
+func newHTTPSink(url string, buffer int, timeout time.Duration) *httpSink {
+	if buffer <= 0 {
+		buffer = 1000
+	}
+	s := &httpSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout, Transport: tracing.WrapTransport(http.DefaultTransport)},
+		queue:  make(chan model.AuditRecord, buffer),
+	}
+	go s.run()
+	return s
+}
+
+func (s *httpSink) enqueue(rec model.AuditRecord) {
+	select {
+	case s.queue <- rec:
+	default:
+		auditDropped.Inc()
+	}
+}
+
+// run 攒满一批或每秒发送一次
+func (s *httpSink) run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	batch := make([]model.AuditRecord, 0, sinkBatchSize)
+	for {
+		select {
+		case rec := <-s.queue:
+			batch = append(batch, rec)
+			if len(batch) < sinkBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := s.send(batch); err != nil {
+			auditErrors.WithLabelValues("http").Add(float64(len(batch)))
+			log.Errorf("发送 %d 条审计记录失败: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+}
+
+func (s *httpSink) send(batch []model.AuditRecord) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("audit sink returned %s", resp.Status)
+	}
+	return nil
+}
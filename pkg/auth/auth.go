@@ -0,0 +1,311 @@
+// Package auth 为 HTTP 接口提供可选的调用方认证：Kubernetes TokenReview 校验 bearer token（结果短期缓存），
+// 供服务间调用的静态 API key，以及可信代理通过请求头声明的身份。
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	authnV1 "k8s.io/api/authentication/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"k8s-admin-informer/pkg/model"
+)
+
+// 调用方身份来源
+const (
+	SourceTokenReview = "tokenReview"
+	SourceAPIKey      = "apiKey"
+	SourceProxy       = "proxy"
+)
+
+// APIKeyHeader 静态 API key 所在请求头，也可以放在 Authorization: Bearer 中
+const APIKeyHeader = "X-API-Key"
+
+// maxCacheEntries 缓存条目上限，超过时先清理过期条目
+const maxCacheEntries = 4096
+
+// User 已认证的调用方
+type User struct {
+	Name   string   `json:"name"`
+	UID    string   `json:"uid,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// Source 身份来源：tokenReview、apiKey 或 proxy
+	Source string `json:"source"`
+}
+
+// Config 认证配置
+type Config struct {
+	// Enabled 为 false 时不做认证，所有请求放行
+	Enabled bool
+	// TokenReview 是否通过 TokenReview 校验 bearer token
+	TokenReview bool
+	// Audiences TokenReview 的 audiences，为空时使用 apiserver 默认值
+	Audiences []string
+	// CacheTTL 认证成功结果的缓存时长
+	CacheTTL time.Duration
+	// FailureCacheTTL 认证失败结果的缓存时长
+	FailureCacheTTL time.Duration
+	// APIKeys 静态 API key 到调用方身份的映射
+	APIKeys map[string]User
+	// PublicPaths 无需认证的路径，以 / 结尾时按前缀匹配
+	PublicPaths []string
+	// ProxyUserHeader 可信代理写入的用户名请求头，为空时不接受代理身份
+	ProxyUserHeader string
+	// ProxyGroupHeader 可信代理写入的用户组请求头，可重复出现或以逗号分隔
+	ProxyGroupHeader string
+	// TrustedProxies 可信代理的地址段，只有来自这些地址的请求才读取代理身份请求头
+	TrustedProxies []*net.IPNet
+}
+
+type cacheEntry struct {
+	user    *User
+	expires time.Time
+}
+
+// Authenticator 并发安全的认证器
+type Authenticator struct {
+	cfg    Config
+	client kubernetes.Interface
+	// apiKeys 以 key 的 sha256 为键，内存中不保留原始 key
+	apiKeys map[string]User
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+	now   func() time.Time
+}
+
+// NewAuthenticator 创建认证器，client 为 nil 时只支持静态 API key
+func NewAuthenticator(client kubernetes.Interface, cfg Config) *Authenticator {
+	keys := make(map[string]User, len(cfg.APIKeys))
+	for key, user := range cfg.APIKeys {
+		user.Source = SourceAPIKey
+		keys[hashKey(key)] = user
+	}
+	return &Authenticator{
+		cfg:     cfg,
+		client:  client,
+		apiKeys: keys,
+		cache:   make(map[string]cacheEntry),
+		now:     time.Now,
+	}
+}
+
+// Enabled 是否启用认证
+func (a *Authenticator) Enabled() bool {
+	return a != nil && a.cfg.Enabled
+}
+
+// ErrUnauthenticated token 无效或未通过校验
+var ErrUnauthenticated = errors.New("invalid credentials")
+
+// Authenticate 依次按静态 API key、TokenReview 校验 token
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*User, error) {
+	hashed := hashKey(token)
+	if user, ok := a.apiKeys[hashed]; ok {
+		return &user, nil
+	}
+	if !a.cfg.TokenReview || a.client == nil {
+		return nil, ErrUnauthenticated
+	}
+
+	a.mu.Lock()
+	entry, ok := a.cache[hashed]
+	a.mu.Unlock()
+	if ok && a.now().Before(entry.expires) {
+		if entry.user == nil {
+			return nil, ErrUnauthenticated
+		}
+		return entry.user, nil
+	}
+
+	review, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, &authnV1.TokenReview{
+		Spec: authnV1.TokenReviewSpec{Token: token, Audiences: a.cfg.Audiences},
+	}, metaV1.CreateOptions{})
+	if err != nil {
+		// apiserver 不可用时不缓存，下一次请求重试
+		return nil, fmt.Errorf("token review: %v", err)
+	}
+	var user *User
+	ttl := a.cfg.FailureCacheTTL
+	if review.Status.Authenticated {
+		user = &User{
+			Name:   review.Status.User.Username,
+			UID:    review.Status.User.UID,
+			Groups: review.Status.User.Groups,
+			Source: SourceTokenReview,
+		}
+		ttl = a.cfg.CacheTTL
+	}
+	a.store(hashed, user, ttl)
+	if user == nil {
+		return nil, ErrUnauthenticated
+	}
+	return user, nil
+}
+
+func (a *Authenticator) store(key string, user *User, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	if len(a.cache) >= maxCacheEntries {
+		for k, e := range a.cache {
+			if !now.Before(e.expires) {
+				delete(a.cache, k)
+			}
+		}
+		if len(a.cache) >= maxCacheEntries {
+			a.cache = make(map[string]cacheEntry)
+		}
+	}
+	a.cache[key] = cacheEntry{user: user, expires: now.Add(ttl)}
+}
+
+// public 判断路径是否无需认证
+func (a *Authenticator) public(path string) bool {
+	for _, p := range a.cfg.PublicPaths {
+		if p == path || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyUser 请求来自可信代理且带有用户名请求头时返回代理声明的身份
+func (a *Authenticator) proxyUser(c *gin.Context) *User {
+	if a.cfg.ProxyUserHeader == "" {
+		return nil
+	}
+	name := strings.TrimSpace(c.GetHeader(a.cfg.ProxyUserHeader))
+	if name == "" {
+		return nil
+	}
+	ip, _ := c.RemoteIP()
+	trusted := false
+	for _, cidr := range a.cfg.TrustedProxies {
+		if ip != nil && cidr.Contains(ip) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return nil
+	}
+	user := &User{Name: name, Source: SourceProxy}
+	if a.cfg.ProxyGroupHeader != "" {
+		for _, v := range c.Request.Header.Values(a.cfg.ProxyGroupHeader) {
+			for _, g := range strings.Split(v, ",") {
+				if g = strings.TrimSpace(g); g != "" {
+					user.Groups = append(user.Groups, g)
+				}
+			}
+		}
+	}
+	return user
+}
+
+// Middleware 依次接受可信代理声明的身份、X-API-Key 或 Authorization: Bearer，失败时返回 401；
+// 认证通过的调用方写入请求 context，可通过 UserFrom 读取
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.Enabled() || a.public(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		if user := a.proxyUser(c); user != nil {
+			c.Request = c.Request.WithContext(WithUser(c.Request.Context(), user))
+			c.Next()
+			return
+		}
+		token := c.GetHeader(APIKeyHeader)
+		if token == "" {
+			if h := c.GetHeader("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+				token = strings.TrimSpace(h[7:])
+			}
+		}
+		if token == "" {
+			unauthorized(c, "missing bearer token or "+APIKeyHeader)
+			return
+		}
+		user, err := a.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			if !errors.Is(err, ErrUnauthenticated) {
+				log.WithContext(c.Request.Context()).Errorf("认证调用方失败: %v", err)
+			}
+			unauthorized(c, err.Error())
+			return
+		}
+		c.Request = c.Request.WithContext(WithUser(c.Request.Context(), user))
+		c.Next()
+	}
+}
+
+func unauthorized(c *gin.Context, msg string) {
+	c.Header("WWW-Authenticate", `Bearer realm="k8s-admin-informer"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{Error: "unauthorized: " + msg})
+}
+
+type userKey struct{}
+
+// WithUser 返回携带调用方身份的 context
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom 返回 context 中的调用方身份，未认证时为 false
+func UserFrom(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userKey{}).(*User)
+	return user, ok && user != nil
+}
+
+// LoadAPIKeys 读取静态 API key 文件，每行 "key,name[,group...]"，空行与 # 开头的行忽略
+func LoadAPIKeys(path string) (map[string]User, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keys := make(map[string]User)
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		if len(fields) < 2 || strings.TrimSpace(fields[0]) == "" || strings.TrimSpace(fields[1]) == "" {
+			return nil, fmt.Errorf("%s:%d: expected key,name[,group...]", path, line)
+		}
+		user := User{Name: strings.TrimSpace(fields[1])}
+		for _, g := range fields[2:] {
+			if g = strings.TrimSpace(g); g != "" {
+				user.Groups = append(user.Groups, g)
+			}
+		}
+		keys[strings.TrimSpace(fields[0])] = user
+	}
+	return keys, scanner.Err()
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
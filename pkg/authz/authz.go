@@ -0,0 +1,285 @@
+// Package authz 按调用方身份限定可见的部门：策略规则把用户与用户组映射到部门，管理员可见全部；
+// 可选地通过 SubjectAccessReview 判断调用方在 namespace 中是否有 Pod 读权限。
+package authz
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	authzV1 "k8s.io/api/authorization/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	"k8s-admin-informer/pkg/auth"
+	"k8s-admin-informer/pkg/model"
+)
+
+// maxCacheEntries SubjectAccessReview 结果缓存条目上限
+const maxCacheEntries = 4096
+
+// Subjects 一组用户与用户组
+type Subjects struct {
+	Users  []string `json:"users,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+func (s Subjects) match(user *auth.User) bool {
+	for _, u := range s.Users {
+		if u == user.Name {
+			return true
+		}
+	}
+	for _, g := range s.Groups {
+		for _, ug := range user.Groups {
+			if g == ug {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Rule 将用户或用户组映射到部门
+type Rule struct {
+	Subjects `json:",inline"`
+	// Depts 匹配时可见的部门
+	Depts []string `json:"depts,omitempty"`
+	// GroupPrefix 调用方的用户组以此为前缀时，去掉前缀的部分即部门名，如 "dept:" 将组 dept:foo 映射到部门 foo
+	GroupPrefix string `json:"groupPrefix,omitempty"`
+}
+
+// Policy 授权策略，admins 可见全部部门
+type Policy struct {
+	Admins Subjects `json:"admins"`
+	Rules  []Rule   `json:"rules"`
+}
+
+// LoadPolicy 读取 YAML 或 JSON 格式的策略文件
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy Policy
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Config 授权配置
+type Config struct {
+	// Enabled 为 false 时不限定部门，所有调用方可见全部数据
+	Enabled bool
+	Policy  Policy
+	// SubjectAccessReview 为 true 时，调用方在 namespace 中有 get pods 权限也可查看该 namespace 的工作负载
+	SubjectAccessReview bool
+	// CacheTTL SubjectAccessReview 结果的缓存时长
+	CacheTTL time.Duration
+}
+
+type cacheEntry struct {
+	allowed bool
+	expires time.Time
+}
+
+// Authorizer 并发安全的授权器
+type Authorizer struct {
+	cfg    Config
+	client kubernetes.Interface
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+	now   func() time.Time
+}
+
+// NewAuthorizer 创建授权器，client 为 nil 时不使用 SubjectAccessReview
+func NewAuthorizer(client kubernetes.Interface, cfg Config) *Authorizer {
+	return &Authorizer{
+		cfg:    cfg,
+		client: client,
+		cache:  make(map[string]cacheEntry),
+		now:    time.Now,
+	}
+}
+
+// Enabled 是否启用授权
+func (a *Authorizer) Enabled() bool {
+	return a != nil && a.cfg.Enabled
+}
+
+// ScopeFor 按策略计算调用方可见的部门，user 为 nil 时不可见任何部门
+func (a *Authorizer) ScopeFor(user *auth.User) *Scope {
+	scope := &Scope{user: user, authorizer: a, depts: make(map[string]bool)}
+	if user == nil {
+		return scope
+	}
+	if a.cfg.Policy.Admins.match(user) {
+		scope.admin = true
+		return scope
+	}
+	for _, rule := range a.cfg.Policy.Rules {
+		if rule.match(user) {
+			for _, d := range rule.Depts {
+				scope.depts[d] = true
+			}
+		}
+		if rule.GroupPrefix == "" {
+			continue
+		}
+		for _, g := range user.Groups {
+			if dept := strings.TrimPrefix(g, rule.GroupPrefix); dept != g && dept != "" {
+				scope.depts[dept] = true
+			}
+		}
+	}
+	return scope
+}
+
+// namespaceAllowed 通过 SubjectAccessReview 判断调用方能否读取 namespace 中的 Pod，结果按 CacheTTL 缓存
+func (a *Authorizer) namespaceAllowed(ctx context.Context, user *auth.User, namespace string) bool {
+	if !a.cfg.SubjectAccessReview || a.client == nil || user == nil {
+		return false
+	}
+	key := user.Name + "\x00" + strings.Join(user.Groups, ",") + "\x00" + namespace
+	a.mu.Lock()
+	entry, ok := a.cache[key]
+	a.mu.Unlock()
+	if ok && a.now().Before(entry.expires) {
+		return entry.allowed
+	}
+
+	review, err := a.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authzV1.SubjectAccessReview{
+		Spec: authzV1.SubjectAccessReviewSpec{
+			User:   user.Name,
+			UID:    user.UID,
+			Groups: user.Groups,
+			ResourceAttributes: &authzV1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "get",
+				Resource:  "pods",
+			},
+		},
+	}, metaV1.CreateOptions{})
+	if err != nil {
+		// apiserver 不可用时按拒绝处理且不缓存
+		log.WithContext(ctx).Errorf("SubjectAccessReview 失败: %v", err)
+		return false
+	}
+	a.store(key, review.Status.Allowed)
+	return review.Status.Allowed
+}
+
+func (a *Authorizer) store(key string, allowed bool) {
+	if a.cfg.CacheTTL <= 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	if len(a.cache) >= maxCacheEntries {
+		for k, e := range a.cache {
+			if !now.Before(e.expires) {
+				delete(a.cache, k)
+			}
+		}
+		if len(a.cache) >= maxCacheEntries {
+			a.cache = make(map[string]cacheEntry)
+		}
+	}
+	a.cache[key] = cacheEntry{allowed: allowed, expires: now.Add(a.cfg.CacheTTL)}
+}
+
+// Middleware 按请求 context 中已认证的调用方计算可见部门并写入 context，需放在认证中间件之后
+func (a *Authorizer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.Enabled() {
+			c.Next()
+			return
+		}
+		user, _ := auth.UserFrom(c.Request.Context())
+		c.Request = c.Request.WithContext(WithScope(c.Request.Context(), a.ScopeFor(user)))
+		c.Next()
+	}
+}
+
+// RequireAdmin 只允许管理员访问，未启用授权时放行
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !ScopeFrom(c.Request.Context()).Admin() {
+			Forbidden(c, "admin role required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// Forbidden 返回 403
+func Forbidden(c *gin.Context, msg string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, model.ErrorResponse{Error: "forbidden: " + msg})
+}
+
+// Scope 调用方可见的部门范围
+type Scope struct {
+	admin      bool
+	depts      map[string]bool
+	user       *auth.User
+	authorizer *Authorizer
+}
+
+// unrestricted 未启用授权时的范围
+var unrestricted = &Scope{admin: true}
+
+type scopeKey struct{}
+
+// WithScope 返回携带可见范围的 context
+func WithScope(ctx context.Context, scope *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFrom 返回 context 中的可见范围，未启用授权时可见全部
+func ScopeFrom(ctx context.Context) *Scope {
+	if scope, ok := ctx.Value(scopeKey{}).(*Scope); ok && scope != nil {
+		return scope
+	}
+	return unrestricted
+}
+
+// Admin 是否可见全部部门
+func (s *Scope) Admin() bool {
+	return s.admin
+}
+
+// Allows 部门是否可见
+func (s *Scope) Allows(dept string) bool {
+	return s.admin || s.depts[dept]
+}
+
+// Depts 可见的部门，管理员返回 nil
+func (s *Scope) Depts() []string {
+	if s.admin {
+		return nil
+	}
+	out := make([]string, 0, len(s.depts))
+	for d := range s.depts {
+		out = append(out, d)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AllowsNamespace 部门可见，或启用 SubjectAccessReview 且调用方能读取 namespace 中的 Pod 时返回 true
+func (s *Scope) AllowsNamespace(ctx context.Context, namespace, dept string) bool {
+	if s.Allows(dept) {
+		return true
+	}
+	return s.authorizer.namespaceAllowed(ctx, s.user, namespace)
+}
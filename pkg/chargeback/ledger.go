@@ -0,0 +1,504 @@
+package chargeback
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"k8s-admin-informer/pkg/model"
+)
+
+// 报表分组方式
+const (
+	GroupByDept      = "dept"
+	GroupByEnv       = "env"
+	GroupByNamespace = "namespace"
+)
+
+// 闲置成本的处理方式
+const (
+	// IdleNone 不计算闲置成本
+	IdleNone = "none"
+	// IdleSeparate 闲置成本单独列出
+	IdleSeparate = "separate"
+	// IdleSplit 闲置成本按各部门在该节点类型上的成本占比分摊
+	IdleSplit = "split"
+)
+
+var chargebackCost = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "chargeback_cost_total",
+		Help: "Accumulated cost charged to departments by node class, in the cost model currency.",
+	},
+	[]string{"dept", "class"},
+)
+
+func init() {
+	prometheus.MustRegister(chargebackCost)
+}
+
+// amount 计费量与成本的累计值
+type amount struct {
+	CPUCoreHours   float64 `json:"cpuCoreHours"`
+	MemoryGiBHours float64 `json:"memoryGiBHours"`
+	CPUCost        float64 `json:"cpuCost"`
+	MemoryCost     float64 `json:"memoryCost"`
+}
+
+// add 累加 o 的 f 倍
+func (a *amount) add(o amount, f float64) {
+	a.CPUCoreHours += o.CPUCoreHours * f
+	a.MemoryGiBHours += o.MemoryGiBHours * f
+	a.CPUCost += o.CPUCost * f
+	a.MemoryCost += o.MemoryCost * f
+}
+
+func (a amount) cost() float64 {
+	return a.CPUCost + a.MemoryCost
+}
+
+func (a amount) public() model.CostAmount {
+	return model.CostAmount{
+		CPUCoreHours:   a.CPUCoreHours,
+		MemoryGiBHours: a.MemoryGiBHours,
+		CPUCost:        a.CPUCost,
+		MemoryCost:     a.MemoryCost,
+		Cost:           a.cost(),
+	}
+}
+
+// bucket 一个小时或一天内的累计值
+type bucket struct {
+	start    time.Time
+	width    time.Duration
+	series   map[Key]*amount
+	capacity map[string]*amount
+}
+
+type bucketKey struct {
+	start int64
+	width time.Duration
+}
+
+// Config 成本核算配置
+type Config struct {
+	Model Model
+	// Interval 采样间隔
+	Interval time.Duration
+	// HourlyRetention 小时桶保留时长，更早的按 UTC 日合并为天桶
+	HourlyRetention time.Duration
+	// Retention 数据保留时长
+	Retention time.Duration
+	// StateFile 持久化文件，为空时只保存在内存中
+	StateFile string
+	// SaveInterval 写入持久化文件的间隔
+	SaveInterval time.Duration
+}
+
+// Sampler 返回当前各 Pod 的占用与各节点类型的可分配容量
+type Sampler func() ([]Sample, map[string]Quantities)
+
+// Ledger 并发安全的成本账本
+type Ledger struct {
+	cfg Config
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*bucket
+	// last 上一次采样时间，两次采样之间的时长按本次采样的占用计费
+	last time.Time
+	now  func() time.Time
+}
+
+// New 创建成本账本
+func New(cfg Config) *Ledger {
+	return &Ledger{
+		cfg:     cfg,
+		buckets: make(map[bucketKey]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Model 返回成本模型
+func (l *Ledger) Model() Model {
+	return l.cfg.Model
+}
+
+// Start 从持久化文件恢复累计值，并按 Interval 采样、按 SaveInterval 合并过期数据并持久化，stopCh 关闭时保存一次
+func (l *Ledger) Start(stopCh <-chan struct{}, sample Sampler) {
+	if err := l.load(); err != nil {
+		log.Errorf("恢复成本账本失败: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(l.cfg.Interval)
+		defer ticker.Stop()
+		save := time.NewTicker(l.cfg.SaveInterval)
+		defer save.Stop()
+		l.Record(l.now(), nil, nil)
+		for {
+			select {
+			case <-stopCh:
+				l.compactAndSave()
+				return
+			case <-ticker.C:
+				samples, capacity := sample()
+				l.Record(l.now(), samples, capacity)
+			case <-save.C:
+				l.compactAndSave()
+			}
+		}
+	}()
+}
+
+// Record 按本次采样的占用为上一次采样以来的时长计费，跨整点时按各小时内的时长拆分到对应的小时桶；
+// 首次调用只记录时间，采样中断（如进程暂停）时最多补记本次采样前两个采样间隔
+func (l *Ledger) Record(at time.Time, samples []Sample, capacity map[string]Quantities) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.last.IsZero() || !at.After(l.last) {
+		if l.last.IsZero() {
+			l.last = at
+		}
+		return
+	}
+	dt := at.Sub(l.last)
+	if max := 2 * l.cfg.Interval; l.cfg.Interval > 0 && dt > max {
+		dt = max
+	}
+	l.last = at
+	hours := dt.Hours()
+	series := make(map[Key]amount, len(samples))
+	for _, s := range samples {
+		a := l.cfg.Model.amount(s.Class, l.cfg.Model.chargeable(s), hours)
+		cur := series[s.Key]
+		cur.add(a, 1)
+		series[s.Key] = cur
+		chargebackCost.WithLabelValues(s.Dept, s.Class).Add(a.cost())
+	}
+	capacityAmounts := make(map[string]amount, len(capacity))
+	for class, q := range capacity {
+		capacityAmounts[class] = l.cfg.Model.amount(class, q, hours)
+	}
+	for from := at.Add(-dt); from.Before(at); {
+		start := from.Truncate(time.Hour)
+		to := start.Add(time.Hour)
+		if to.After(at) {
+			to = at
+		}
+		f := float64(to.Sub(from)) / float64(dt)
+		b := l.bucketLocked(start, time.Hour)
+		for k, a := range series {
+			addTo(b.series, k, a, f)
+		}
+		for class, a := range capacityAmounts {
+			addTo(b.capacity, class, a, f)
+		}
+		from = to
+	}
+}
+
+func (l *Ledger) bucketLocked(start time.Time, width time.Duration) *bucket {
+	key := bucketKey{start: start.Unix(), width: width}
+	b := l.buckets[key]
+	if b == nil {
+		b = &bucket{start: start, width: width, series: make(map[Key]*amount), capacity: make(map[string]*amount)}
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// compactLocked 删除超过 Retention 的桶，并把早于 HourlyRetention 所在 UTC 日的小时桶合并为天桶
+func (l *Ledger) compactLocked(now time.Time) {
+	cutoff := now.Add(-l.cfg.Retention)
+	hourlyCutoff := now.Add(-l.cfg.HourlyRetention).Truncate(24 * time.Hour)
+	for key, b := range l.buckets {
+		switch {
+		case l.cfg.Retention > 0 && !b.start.Add(b.width).After(cutoff):
+			delete(l.buckets, key)
+		case b.width == time.Hour && b.start.Before(hourlyCutoff):
+			day := l.bucketLocked(b.start.Truncate(24*time.Hour), 24*time.Hour)
+			for k, a := range b.series {
+				if day.series[k] == nil {
+					day.series[k] = &amount{}
+				}
+				day.series[k].add(*a, 1)
+			}
+			for class, a := range b.capacity {
+				if day.capacity[class] == nil {
+					day.capacity[class] = &amount{}
+				}
+				day.capacity[class].add(*a, 1)
+			}
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *Ledger) compactAndSave() {
+	l.mu.Lock()
+	l.compactLocked(l.now())
+	l.mu.Unlock()
+	if err := l.save(); err != nil {
+		log.Errorf("持久化成本账本失败: %v", err)
+	}
+}
+
+// Query 报表条件
+type Query struct {
+	From, To time.Time
+	// GroupBy dept、env 或 namespace，env/namespace 同时按部门区分
+	GroupBy string
+	// Dept 只返回该部门，为空时不限
+	Dept string
+	// Idle none、separate 或 split
+	Idle string
+	// Allow 返回 false 的部门不返回，为 nil 时不限
+	Allow func(dept string) bool
+	// IncludeIdle 为 false 时 idle=separate 也不返回闲置成本明细
+	IncludeIdle bool
+}
+
+type costGroup struct {
+	key     Key
+	classes map[string]*amount
+}
+
+// Report 汇总 [From, To) 内的成本；与时间段部分重叠的桶按重叠时长占桶宽的比例计入。
+// 闲置成本为各节点类型可分配容量的成本减去已归属成本（不小于 0），split 时按各项在该节点类型上的成本占全部归属成本的比例分摊，
+// 比例按全部部门计算，不受 Dept 与 Allow 过滤影响
+func (l *Ledger) Report(q Query) model.CostReport {
+	report := model.CostReport{
+		From:     q.From,
+		To:       q.To,
+		Currency: l.cfg.Model.Currency,
+		Basis:    l.cfg.Model.Basis,
+		GroupBy:  q.GroupBy,
+		IdleMode: q.Idle,
+		Items:    make([]model.CostItem, 0),
+	}
+	groups := make(map[Key]*costGroup)
+	allocated := make(map[string]*amount)
+	capacity := make(map[string]*amount)
+	l.mu.Lock()
+	for _, b := range l.buckets {
+		end := b.start.Add(b.width)
+		from, to := b.start, end
+		if q.From.After(from) {
+			from = q.From
+		}
+		if q.To.Before(to) {
+			to = q.To
+		}
+		if !to.After(from) {
+			continue
+		}
+		f := float64(to.Sub(from)) / float64(b.width)
+		for k, a := range b.series {
+			gk := Key{Dept: k.Dept}
+			switch q.GroupBy {
+			case GroupByEnv:
+				gk.Env = k.Env
+			case GroupByNamespace:
+				gk.Namespace = k.Namespace
+			}
+			g := groups[gk]
+			if g == nil {
+				g = &costGroup{key: gk, classes: make(map[string]*amount)}
+				groups[gk] = g
+			}
+			addTo(g.classes, k.Class, *a, f)
+			addTo(allocated, k.Class, *a, f)
+		}
+		for class, a := range b.capacity {
+			addTo(capacity, class, *a, f)
+		}
+	}
+	l.mu.Unlock()
+
+	idle := make(map[string]amount)
+	if q.Idle == IdleSeparate || q.Idle == IdleSplit {
+		for class, c := range capacity {
+			var a amount
+			if al := allocated[class]; al != nil {
+				a = amount{
+					CPUCoreHours:   nonNegative(c.CPUCoreHours - al.CPUCoreHours),
+					MemoryGiBHours: nonNegative(c.MemoryGiBHours - al.MemoryGiBHours),
+					CPUCost:        nonNegative(c.CPUCost - al.CPUCost),
+					MemoryCost:     nonNegative(c.MemoryCost - al.MemoryCost),
+				}
+			} else {
+				a = *c
+			}
+			idle[class] = a
+		}
+	}
+
+	for _, g := range groups {
+		if (q.Dept != "" && g.key.Dept != q.Dept) || (q.Allow != nil && !q.Allow(g.key.Dept)) {
+			continue
+		}
+		item := model.CostItem{
+			Dept:      g.key.Dept,
+			Env:       g.key.Env,
+			Namespace: g.key.Namespace,
+			Classes:   make(map[string]model.CostAmount, len(g.classes)),
+		}
+		for class, a := range g.classes {
+			item.Classes[class] = a.public()
+			item.Cost += a.cost()
+			if q.Idle == IdleSplit {
+				if total := allocated[class].cost(); total > 0 {
+					item.IdleCost += idle[class].cost() * a.cost() / total
+				}
+			}
+		}
+		item.TotalCost = item.Cost + item.IdleCost
+		report.Total += item.TotalCost
+		report.Items = append(report.Items, item)
+	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.Dept != b.Dept {
+			return a.Dept < b.Dept
+		}
+		if a.Env != b.Env {
+			return a.Env < b.Env
+		}
+		return a.Namespace < b.Namespace
+	})
+	if q.Idle == IdleSeparate && q.IncludeIdle && len(idle) > 0 {
+		report.Idle = make(map[string]model.CostAmount, len(idle))
+		for class, a := range idle {
+			report.Idle[class] = a.public()
+			report.Total += a.cost()
+		}
+	}
+	return report
+}
+
+func addTo[K comparable](m map[K]*amount, k K, a amount, f float64) {
+	cur := m[k]
+	if cur == nil {
+		cur = &amount{}
+		m[k] = cur
+	}
+	cur.add(a, f)
+}
+
+func nonNegative(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
+// 持久化文件格式
+type state struct {
+	Buckets []stateBucket `json:"buckets"`
+}
+
+type stateBucket struct {
+	Start    time.Time       `json:"start"`
+	Hours    int             `json:"hours"`
+	Series   []stateSeries   `json:"series"`
+	Capacity []stateCapacity `json:"capacity,omitempty"`
+}
+
+type stateSeries struct {
+	Key
+	amount
+}
+
+type stateCapacity struct {
+	Class string `json:"class"`
+	amount
+}
+
+func (l *Ledger) snapshot() ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var s state
+	for _, b := range l.buckets {
+		sb := stateBucket{Start: b.start, Hours: int(b.width / time.Hour)}
+		for k, a := range b.series {
+			sb.Series = append(sb.Series, stateSeries{Key: k, amount: *a})
+		}
+		for class, a := range b.capacity {
+			sb.Capacity = append(sb.Capacity, stateCapacity{Class: class, amount: *a})
+		}
+		s.Buckets = append(s.Buckets, sb)
+	}
+	sort.Slice(s.Buckets, func(i, j int) bool {
+		if !s.Buckets[i].Start.Equal(s.Buckets[j].Start) {
+			return s.Buckets[i].Start.Before(s.Buckets[j].Start)
+		}
+		return s.Buckets[i].Hours < s.Buckets[j].Hours
+	})
+	return json.Marshal(s)
+}
+
+// save 先写临时文件再重命名，避免进程中途退出留下不完整的文件
+func (l *Ledger) save() error {
+	if l.cfg.StateFile == "" {
+		return nil
+	}
+	data, err := l.snapshot()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.cfg.StateFile), filepath.Base(l.cfg.StateFile)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), l.cfg.StateFile)
+}
+
+func (l *Ledger) load() error {
+	if l.cfg.StateFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(l.cfg.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, sb := range s.Buckets {
+		if sb.Hours <= 0 {
+			continue
+		}
+		b := l.bucketLocked(sb.Start, time.Duration(sb.Hours)*time.Hour)
+		for _, ss := range sb.Series {
+			a := ss.amount
+			b.series[ss.Key] = &a
+		}
+		for _, sc := range sb.Capacity {
+			a := sc.amount
+			b.capacity[sc.Class] = &a
+		}
+	}
+	l.compactLocked(l.now())
+	log.Infof("从 %s 恢复 %d 个成本账本时间桶", l.cfg.StateFile, len(l.buckets))
+	return nil
+}
@@ -0,0 +1,244 @@
+package chargeback
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// 价格：nonXc 每核时 1、每 GiB 时 0.5
+var testModel = Model{
+	Currency: "CNY",
+	Basis:    []string{BasisRequests},
+	Classes:  map[string]Price{"nonXc": {CPUCoreHour: 1, MemoryGiBHour: 0.5}},
+}
+
+var day = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+func newTestLedger(cfg Config) *Ledger {
+	cfg.Model = testModel
+	if cfg.Interval == 0 {
+		cfg.Interval = 10 * time.Minute
+	}
+	l := New(cfg)
+	l.now = func() time.Time { return day.Add(12 * time.Hour) }
+	return l
+}
+
+func sample(dept string, cpu float64) Sample {
+	return Sample{
+		Key:      Key{Dept: dept, Env: "prod", Namespace: dept + "-ns", Class: "nonXc"},
+		Requests: Quantities{CPUCores: cpu},
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// cpuHours 返回起点为 start、宽度为 width 的桶中 dept 的核时
+func cpuHours(l *Ledger, start time.Time, width time.Duration, dept string) float64 {
+	b := l.buckets[bucketKey{start: start.Unix(), width: width}]
+	if b == nil {
+		return 0
+	}
+	var sum float64
+	for k, a := range b.series {
+		if k.Dept == dept {
+			sum += a.CPUCoreHours
+		}
+	}
+	return sum
+}
+
+func TestRecordSplitsIntervalAcrossHours(t *testing.T) {
+	l := newTestLedger(Config{})
+	at := day.Add(10*time.Hour + 55*time.Minute)
+	l.Record(at, []Sample{sample("a", 1)}, nil)
+	if len(l.buckets) != 0 {
+		t.Fatalf("first record booked %d buckets, want none", len(l.buckets))
+	}
+
+	l.Record(at.Add(10*time.Minute), []Sample{sample("a", 6)}, map[string]Quantities{"nonXc": {CPUCores: 12}})
+	ten, eleven := day.Add(10*time.Hour), day.Add(11*time.Hour)
+	if got := cpuHours(l, ten, time.Hour, "a"); !near(got, 0.5) {
+		t.Fatalf("10:00 bucket = %v core-hours, want 0.5", got)
+	}
+	if got := cpuHours(l, eleven, time.Hour, "a"); !near(got, 0.5) {
+		t.Fatalf("11:00 bucket = %v core-hours, want 0.5", got)
+	}
+	if got := l.buckets[bucketKey{start: eleven.Unix(), width: time.Hour}].capacity["nonXc"].CPUCoreHours; !near(got, 1) {
+		t.Fatalf("11:00 capacity = %v core-hours, want 1", got)
+	}
+
+	// 乱序或重复的采样时间不计费
+	l.Record(at, []Sample{sample("a", 6)}, nil)
+	if got := cpuHours(l, ten, time.Hour, "a"); !near(got, 0.5) {
+		t.Fatalf("stale record changed the 10:00 bucket to %v", got)
+	}
+}
+
+func TestRecordCapsGapBeforeSample(t *testing.T) {
+	l := newTestLedger(Config{})
+	l.Record(day.Add(9*time.Hour), nil, nil)
+	// 中断两个多小时后只补记本次采样前的两个采样间隔 11:50-12:10
+	l.Record(day.Add(12*time.Hour+10*time.Minute), []Sample{sample("a", 3)}, nil)
+	for _, h := range []int{9, 10} {
+		if got := cpuHours(l, day.Add(time.Duration(h)*time.Hour), time.Hour, "a"); got != 0 {
+			t.Fatalf("%02d:00 bucket = %v core-hours, want 0", h, got)
+		}
+	}
+	if got := cpuHours(l, day.Add(11*time.Hour), time.Hour, "a"); !near(got, 0.5) {
+		t.Fatalf("11:00 bucket = %v core-hours, want 0.5", got)
+	}
+	if got := cpuHours(l, day.Add(12*time.Hour), time.Hour, "a"); !near(got, 0.5) {
+		t.Fatalf("12:00 bucket = %v core-hours, want 0.5", got)
+	}
+}
+
+func TestReportOnHourBoundaries(t *testing.T) {
+	l := newTestLedger(Config{})
+	l.Record(day.Add(10*time.Hour+50*time.Minute), nil, nil)
+	l.Record(day.Add(11*time.Hour+10*time.Minute), []Sample{sample("a", 3)}, nil)
+
+	r := l.Report(Query{From: day.Add(11 * time.Hour), To: day.Add(12 * time.Hour), GroupBy: GroupByDept, Idle: IdleNone})
+	if len(r.Items) != 1 || !near(r.Items[0].Cost, 0.5) {
+		t.Fatalf("report for 11:00-12:00 = %+v, want a single item costing 0.5", r.Items)
+	}
+	r = l.Report(Query{From: day.Add(10 * time.Hour), To: day.Add(12 * time.Hour), GroupBy: GroupByDept, Idle: IdleNone})
+	if !near(r.Total, 1) {
+		t.Fatalf("report for 10:00-12:00 total = %v, want 1", r.Total)
+	}
+}
+
+// recordHour 在 10:00-11:00 内按采样间隔记录 a 占 1 核、b 占 3 核，节点容量 8 核
+func recordHour(l *Ledger) {
+	samples := []Sample{sample("a", 1), sample("b", 3)}
+	capacity := map[string]Quantities{"nonXc": {CPUCores: 8}}
+	for at := day.Add(10 * time.Hour); !at.After(day.Add(11 * time.Hour)); at = at.Add(l.cfg.Interval) {
+		l.Record(at, samples, capacity)
+	}
+}
+
+func TestReportIdle(t *testing.T) {
+	l := newTestLedger(Config{})
+	recordHour(l)
+	window := Query{From: day, To: day.Add(24 * time.Hour), GroupBy: GroupByDept}
+
+	q := window
+	q.Idle = IdleSplit
+	r := l.Report(q)
+	want := map[string][2]float64{"a": {1, 1}, "b": {3, 3}}
+	if len(r.Items) != len(want) {
+		t.Fatalf("split items = %+v", r.Items)
+	}
+	for _, it := range r.Items {
+		w := want[it.Dept]
+		if !near(it.Cost, w[0]) || !near(it.IdleCost, w[1]) || !near(it.TotalCost, w[0]+w[1]) {
+			t.Fatalf("split item %s = cost %v idle %v total %v, want %v", it.Dept, it.Cost, it.IdleCost, it.TotalCost, w)
+		}
+	}
+	if !near(r.Total, 8) || r.Idle != nil {
+		t.Fatalf("split total = %v idle = %+v, want 8 and no idle section", r.Total, r.Idle)
+	}
+
+	// 按部门过滤时分摊比例仍按全部部门计算
+	q.Dept = "a"
+	r = l.Report(q)
+	if len(r.Items) != 1 || !near(r.Items[0].IdleCost, 1) || !near(r.Total, 2) {
+		t.Fatalf("split report for a = %+v total %v", r.Items, r.Total)
+	}
+
+	q = window
+	q.Idle = IdleSeparate
+	q.IncludeIdle = true
+	r = l.Report(q)
+	for _, it := range r.Items {
+		if it.IdleCost != 0 || !near(it.TotalCost, it.Cost) {
+			t.Fatalf("separate item %s carries idle cost %v", it.Dept, it.IdleCost)
+		}
+	}
+	if idle := r.Idle["nonXc"]; !near(idle.Cost, 4) || !near(idle.CPUCoreHours, 4) {
+		t.Fatalf("separate idle = %+v, want 4 core-hours costing 4", r.Idle)
+	}
+	if !near(r.Total, 8) {
+		t.Fatalf("separate total = %v, want 8", r.Total)
+	}
+
+	q.IncludeIdle = false
+	r = l.Report(q)
+	if r.Idle != nil || !near(r.Total, 4) {
+		t.Fatalf("separate without idle detail = idle %+v total %v, want none and 4", r.Idle, r.Total)
+	}
+
+	q.Idle = IdleNone
+	q.IncludeIdle = true
+	if r = l.Report(q); r.Idle != nil || !near(r.Total, 4) {
+		t.Fatalf("idle=none = idle %+v total %v, want none and 4", r.Idle, r.Total)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	l := newTestLedger(Config{HourlyRetention: 24 * time.Hour, Retention: 72 * time.Hour})
+	// 两天前的一个采样间隔，超过 Retention 后删除
+	l.Record(day.Add(-48*time.Hour), nil, nil)
+	l.Record(day.Add(-48*time.Hour+10*time.Minute), []Sample{sample("a", 6)}, nil)
+	l.last = time.Time{}
+	recordHour(l)
+	before := l.Report(Query{From: day, To: day.Add(24 * time.Hour), GroupBy: GroupByDept, Idle: IdleSplit}).Total
+
+	// 小时桶所在 UTC 日尚未早于 HourlyRetention 时保留
+	l.mu.Lock()
+	l.compactLocked(day.Add(36 * time.Hour))
+	l.mu.Unlock()
+	if got := cpuHours(l, day.Add(10*time.Hour), time.Hour, "a"); !near(got, 1) {
+		t.Fatalf("10:00 bucket = %v core-hours before its day is due, want 1", got)
+	}
+	if got := cpuHours(l, day.Add(-48*time.Hour), time.Hour, "a"); got != 0 {
+		t.Fatalf("bucket past retention kept %v core-hours", got)
+	}
+
+	l.mu.Lock()
+	l.compactLocked(day.Add(48 * time.Hour))
+	l.mu.Unlock()
+	for key := range l.buckets {
+		if key.width != time.Hour {
+			continue
+		}
+		t.Fatalf("hourly bucket %v survived compaction", time.Unix(key.start, 0).UTC())
+	}
+	if got := cpuHours(l, day, 24*time.Hour, "a"); !near(got, 1) {
+		t.Fatalf("day bucket = %v core-hours for a, want 1", got)
+	}
+	if got := l.Report(Query{From: day, To: day.Add(24 * time.Hour), GroupBy: GroupByDept, Idle: IdleSplit}).Total; !near(got, before) {
+		t.Fatalf("total after compaction = %v, want %v", got, before)
+	}
+	// 部分重叠的天桶按重叠时长比例计入
+	if got := l.Report(Query{From: day, To: day.Add(12 * time.Hour), GroupBy: GroupByDept, Idle: IdleNone}).Total; !near(got, 2) {
+		t.Fatalf("half-day report = %v, want 2", got)
+	}
+
+	l.mu.Lock()
+	l.compactLocked(day.Add(48 * time.Hour))
+	l.mu.Unlock()
+	if got := cpuHours(l, day, 24*time.Hour, "a"); !near(got, 1) {
+		t.Fatalf("second compaction changed the day bucket to %v", got)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := t.TempDir() + "/ledger.json"
+	l := newTestLedger(Config{StateFile: path})
+	recordHour(l)
+	if err := l.save(); err != nil {
+		t.Fatal(err)
+	}
+	restored := newTestLedger(Config{StateFile: path})
+	if err := restored.load(); err != nil {
+		t.Fatal(err)
+	}
+	q := Query{From: day, To: day.Add(24 * time.Hour), GroupBy: GroupByNamespace, Idle: IdleSplit}
+	if got, want := restored.Report(q).Total, l.Report(q).Total; !near(got, want) {
+		t.Fatalf("restored total = %v, want %v", got, want)
+	}
+}
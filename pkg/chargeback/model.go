@@ -0,0 +1,144 @@
+// Package chargeback 按节点类型的单价把部门占用的 cpu/memory 折算为成本：定时采样各 Pod 的 requests、limits 或实时用量
+// （可取多项的最大值），按小时桶累计到部门、环境与命名空间，较早的小时桶合并为天桶；
+// 报表可覆盖任意时间段，并可把节点闲置容量的成本按各部门成本占比分摊回部门。
+package chargeback
+
+import (
+	"fmt"
+	"os"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// 计费依据
+const (
+	BasisRequests = "requests"
+	BasisLimits   = "limits"
+	BasisUsage    = "usage"
+)
+
+// gib 1 GiB 的字节数
+const gib = 1 << 30
+
+// Price 某类节点的单价
+type Price struct {
+	CPUCoreHour   float64 `json:"cpuCoreHour"`
+	MemoryGiBHour float64 `json:"memoryGiBHour"`
+}
+
+// Model 成本模型
+type Model struct {
+	Currency string `json:"currency,omitempty"`
+	// Basis 计费依据，取值 requests、limits、usage，多项时逐资源取最大值，如 [requests, usage]；缺省为 requests
+	Basis []string `json:"basis,omitempty"`
+	// Classes 以节点类型（nonXc、xcArm、xcX86）为键的单价，未配置的节点类型不计费
+	Classes map[string]Price `json:"classes"`
+}
+
+// LoadModel 读取 YAML 或 JSON 格式的成本模型文件
+func LoadModel(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Model
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, err
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &m, nil
+}
+
+// Validate 校验计费依据与单价，Basis 为空时补为 requests
+func (m *Model) Validate() error {
+	if len(m.Basis) == 0 {
+		m.Basis = []string{BasisRequests}
+	}
+	for _, b := range m.Basis {
+		switch b {
+		case BasisRequests, BasisLimits, BasisUsage:
+		default:
+			return fmt.Errorf("unknown basis %q, use requests, limits or usage", b)
+		}
+	}
+	for class, p := range m.Classes {
+		if p.CPUCoreHour < 0 || p.MemoryGiBHour < 0 {
+			return fmt.Errorf("class %s: prices must not be negative", class)
+		}
+	}
+	return nil
+}
+
+// Quantities 一组 Pod 或节点的 cpu 核数与内存 GiB 数
+type Quantities struct {
+	CPUCores  float64
+	MemoryGiB float64
+}
+
+// QuantitiesOf 取资源列表中的 cpu 与内存
+func QuantitiesOf(list v1.ResourceList) Quantities {
+	var q Quantities
+	if cpu, ok := list[v1.ResourceCPU]; ok {
+		q.CPUCores = float64(cpu.MilliValue()) / 1000
+	}
+	if mem, ok := list[v1.ResourceMemory]; ok {
+		q.MemoryGiB = float64(mem.Value()) / gib
+	}
+	return q
+}
+
+func (q *Quantities) add(o Quantities) {
+	q.CPUCores += o.CPUCores
+	q.MemoryGiB += o.MemoryGiB
+}
+
+// Key 成本归属
+type Key struct {
+	Dept      string `json:"dept"`
+	Env       string `json:"env,omitempty"`
+	Namespace string `json:"namespace"`
+	Class     string `json:"class"`
+}
+
+// Sample 一次采样中单个 Pod 的占用
+type Sample struct {
+	Key
+	Requests Quantities
+	Limits   Quantities
+	Usage    Quantities
+}
+
+// chargeable 按计费依据逐资源取最大值
+func (m *Model) chargeable(s Sample) Quantities {
+	var q Quantities
+	for _, b := range m.Basis {
+		v := s.Requests
+		switch b {
+		case BasisLimits:
+			v = s.Limits
+		case BasisUsage:
+			v = s.Usage
+		}
+		if v.CPUCores > q.CPUCores {
+			q.CPUCores = v.CPUCores
+		}
+		if v.MemoryGiB > q.MemoryGiB {
+			q.MemoryGiB = v.MemoryGiB
+		}
+	}
+	return q
+}
+
+// amount 按 class 的单价把持续 hours 小时的占用折算为计费量与成本
+func (m *Model) amount(class string, q Quantities, hours float64) amount {
+	p := m.Classes[class]
+	return amount{
+		CPUCoreHours:   q.CPUCores * hours,
+		MemoryGiBHours: q.MemoryGiB * hours,
+		CPUCost:        q.CPUCores * hours * p.CPUCoreHour,
+		MemoryCost:     q.MemoryGiB * hours * p.MemoryGiBHour,
+	}
+}
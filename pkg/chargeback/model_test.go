@@ -0,0 +1,40 @@
+package chargeback
+
+import "testing"
+
+func TestChargeableTakesMaxPerResource(t *testing.T) {
+	s := Sample{
+		Requests: Quantities{CPUCores: 2, MemoryGiB: 1},
+		Limits:   Quantities{CPUCores: 4, MemoryGiB: 8},
+		Usage:    Quantities{CPUCores: 1, MemoryGiB: 3},
+	}
+	cases := []struct {
+		name  string
+		basis []string
+		want  Quantities
+	}{
+		{name: "default", want: Quantities{CPUCores: 2, MemoryGiB: 1}},
+		{name: "usage", basis: []string{BasisUsage}, want: Quantities{CPUCores: 1, MemoryGiB: 3}},
+		{name: "requests and usage", basis: []string{BasisRequests, BasisUsage}, want: Quantities{CPUCores: 2, MemoryGiB: 3}},
+		{name: "usage and requests", basis: []string{BasisUsage, BasisRequests}, want: Quantities{CPUCores: 2, MemoryGiB: 3}},
+		{name: "all", basis: []string{BasisRequests, BasisLimits, BasisUsage}, want: Quantities{CPUCores: 4, MemoryGiB: 8}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := Model{Basis: tc.basis}
+			if err := m.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if got := m.chargeable(s); got != tc.want {
+				t.Fatalf("chargeable = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsUnknownBasis(t *testing.T) {
+	m := Model{Basis: []string{BasisRequests, "peak"}}
+	if err := m.Validate(); err == nil {
+		t.Fatalf("unknown basis accepted")
+	}
+}
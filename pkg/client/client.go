@@ -0,0 +1,256 @@
+// Package client 提供 k8s-admin-informer /informer/v1 与 /informer/v2 接口的 Go 客户端，
+// 请求与响应结构直接复用 pkg/model，与服务端及 /openapi.json 保持一致。
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	defaultBackoff    = 200 * time.Millisecond
+	maxBackoff        = 5 * time.Second
+)
+
+// Client 是并发安全的，可在多个 goroutine 间共享
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+	header     http.Header
+}
+
+// Option 用于定制 Client
+type Option func(*Client)
+
+// WithHTTPClient 使用自定义 http.Client（如需要自定义 TLS、代理）
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithRetry 设置最大重试次数与初始退避时间，退避按指数增长，maxRetries 为 0 表示不重试
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithHeader 为每个请求附加固定请求头
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Set(key, value)
+	}
+}
+
+// WithBearerToken 以 Authorization: Bearer 携带 ServiceAccount token 或静态 API key
+func WithBearerToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// New 创建客户端，baseURL 形如 http://k8s-admin-informer:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url %q: %w", baseURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q: scheme and host are required", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+		header:     make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// APIError 服务端返回非 2xx 时的错误
+type APIError struct {
+	StatusCode int
+	Message    string
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("k8s-admin-informer: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+	}
+	return fmt.Sprintf("k8s-admin-informer: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// IsNotFound 判断错误是否为 404
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// do 发送请求并在网络错误、429 与 5xx 时按指数退避重试，只用于重复发送无副作用的请求
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in interface{}) (*http.Response, []byte, error) {
+	return c.doRetry(ctx, method, path, query, in, c.maxRetries)
+}
+
+// doOnce 只发送一次，用于会在服务端创建状态的请求（如携带 reserve 的配额检查），
+// 避免请求已生效但响应丢失时重试产生重复的预留
+func (c *Client) doOnce(ctx context.Context, method, path string, query url.Values, in interface{}) (*http.Response, []byte, error) {
+	return c.doRetry(ctx, method, path, query, in, 0)
+}
+
+func (c *Client) doRetry(ctx context.Context, method, path string, query url.Values, in interface{}, maxRetries int) (*http.Response, []byte, error) {
+	var body []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshal request: %w", err)
+		}
+		body = b
+	}
+
+	u := *c.baseURL
+	u.Path = c.baseURL.Path + path
+	u.RawQuery = query.Encode()
+
+	backoff := c.backoff
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		resp, data, err := c.once(ctx, method, u.String(), body)
+		if err == nil && !retryable(resp.StatusCode) {
+			return resp, data, nil
+		}
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = newAPIError(resp.StatusCode, data)
+		}
+		if attempt >= maxRetries || ctx.Err() != nil || !retryableErr(err) {
+			return nil, nil, lastErr
+		}
+
+		wait := backoff
+		if err == nil {
+			if d := retryAfter(resp); d > 0 {
+				wait = d
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (c *Client) once(ctx context.Context, method, u string, body []byte) (*http.Response, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, data, nil
+}
+
+func retryable(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// retryableErr 上下文取消不重试，其余网络错误与可重试状态码都重试
+func retryableErr(err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	var urlErr *url.Error
+	return errors.As(err, &netErr) || errors.As(err, &urlErr)
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	var secs int
+	if _, err := fmt.Sscanf(v, "%d", &secs); err != nil || secs <= 0 {
+		return 0
+	}
+	d := time.Duration(secs) * time.Second
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+func newAPIError(code int, data []byte) *APIError {
+	e := &APIError{StatusCode: code, Body: data}
+	var payload struct {
+		Error  string `json:"error"`
+		Reason string `json:"reason"`
+	}
+	if json.Unmarshal(data, &payload) == nil {
+		e.Message = payload.Error
+		if e.Message == "" {
+			e.Message = payload.Reason
+		}
+	}
+	return e
+}
+
+// decode 将 2xx 响应解码到 out，其余状态码转换为 APIError
+func decode(resp *http.Response, data []byte, out interface{}) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp.StatusCode, data)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s-admin-informer/pkg/app"
+	"k8s-admin-informer/pkg/client"
+	"k8s-admin-informer/pkg/model"
+	"k8s-admin-informer/pkg/openapi"
+)
+
+// notInClient 运维接口，不由 Go 客户端封装
+var notInClient = map[string]bool{"healthz": true, "readyz": true, "metrics": true}
+
+// genericMethods 不对应单个接口的客户端方法
+var genericMethods = map[string]bool{"Export": true}
+
+// clientCase 一个接口对应的客户端方法及其调用方式
+type clientCase struct {
+	operationID string
+	method      string
+	call        func(ctx context.Context, c *client.Client) error
+}
+
+var clientCases = []clientCase{
+	{"getWorkloadInstance", "GetWorkloadInstance", func(ctx context.Context, c *client.Client) error {
+		_, err := c.GetWorkloadInstance(ctx, &model.GetWorkloadInstanceRequest{Apps: []model.App{{}}})
+		return err
+	}},
+	{"checkDeptLimit", "CheckDeptLimit", func(ctx context.Context, c *client.Client) error {
+		_, err := c.CheckDeptLimit(ctx, &model.DeptResourceQuotaRequest{Dept: "dept"})
+		return err
+	}},
+	{"checkDeptLimitByManifest", "CheckDeptLimitByManifest", func(ctx context.Context, c *client.Client) error {
+		_, err := c.CheckDeptLimitByManifest(ctx, &model.ManifestQuotaRequest{Dept: "dept"})
+		return err
+	}},
+	{"listDeptReservations", "ListReservations", func(ctx context.Context, c *client.Client) error {
+		_, err := c.ListReservations(ctx, "dept")
+		return err
+	}},
+	{"releaseDeptReservation", "ReleaseReservation", func(ctx context.Context, c *client.Client) error {
+		return c.ReleaseReservation(ctx, "r-1")
+	}},
+	{"listAuditRecords", "AuditRecords", func(ctx context.Context, c *client.Client) error {
+		_, err := c.AuditRecords(ctx, &client.AuditQuery{Dept: "dept"})
+		return err
+	}},
+	{"listNodeResources", "NodeResources", func(ctx context.Context, c *client.Client) error {
+		_, err := c.NodeResources(ctx, &client.CacheOptions{Refresh: true})
+		return err
+	}},
+	{"listDeptResources", "DeptResources", func(ctx context.Context, c *client.Client) error {
+		_, err := c.DeptResources(ctx, nil)
+		return err
+	}},
+	{"getClusterResources", "ClusterResources", func(ctx context.Context, c *client.Client) error {
+		_, err := c.ClusterResources(ctx)
+		return err
+	}},
+	{"listEnvResources", "EnvResources", func(ctx context.Context, c *client.Client) error {
+		_, err := c.EnvResources(ctx, "dept")
+		return err
+	}},
+	{"listNodeResourcesV2", "NodeResourcesV2", func(ctx context.Context, c *client.Client) error {
+		_, err := c.NodeResourcesV2(ctx)
+		return err
+	}},
+	{"listDeptResourcesV2", "DeptResourcesV2", func(ctx context.Context, c *client.Client) error {
+		_, err := c.DeptResourcesV2(ctx, "dept")
+		return err
+	}},
+	{"getClusterResourcesV2", "ClusterResourcesV2", func(ctx context.Context, c *client.Client) error {
+		_, err := c.ClusterResourcesV2(ctx)
+		return err
+	}},
+	{"listEnvResourcesV2", "EnvResourcesV2", func(ctx context.Context, c *client.Client) error {
+		_, err := c.EnvResourcesV2(ctx, "dept")
+		return err
+	}},
+	{"getCostReport", "CostReport", func(ctx context.Context, c *client.Client) error {
+		_, err := c.CostReport(ctx, &client.CostQuery{GroupBy: "dept"})
+		return err
+	}},
+	{"getLogLevel", "LogLevel", func(ctx context.Context, c *client.Client) error {
+		_, err := c.LogLevel(ctx)
+		return err
+	}},
+	{"setLogLevel", "SetLogLevel", func(ctx context.Context, c *client.Client) error {
+		_, err := c.SetLogLevel(ctx, "debug")
+		return err
+	}},
+}
+
+// specOperation 文档中的一个操作
+type specOperation struct {
+	method, path string
+	op           *openapi.Operation
+}
+
+func operations(doc *openapi.Document) map[string]specOperation {
+	ops := make(map[string]specOperation)
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			ops[op.OperationID] = specOperation{method: strings.ToUpper(method), path: path, op: op}
+		}
+	}
+	return ops
+}
+
+// matchPath 判断请求路径是否匹配文档中带 {param} 的路径
+func matchPath(template, path string) bool {
+	ts, ps := strings.Split(template, "/"), strings.Split(path, "/")
+	if len(ts) != len(ps) {
+		return false
+	}
+	for i := range ts {
+		if strings.HasPrefix(ts[i], "{") || ts[i] == ps[i] {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// successResponse 返回操作最小的 2xx 状态码及其 JSON schema
+func successResponse(op *openapi.Operation) (int, *openapi.Schema) {
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	if len(codes) == 0 {
+		return 0, nil
+	}
+	var status int
+	for _, ch := range codes[0] {
+		status = status*10 + int(ch-'0')
+	}
+	return status, op.Responses[codes[0]].Content["application/json"].Schema
+}
+
+// probe 用与文档相同的反射规则生成类型 v 的 schema 与其引用的 components
+func probe(v interface{}) (*openapi.Schema, map[string]*openapi.Schema) {
+	doc := openapi.Build(openapi.Info{}, []openapi.Route{{
+		Method:    http.MethodGet,
+		Path:      "/probe",
+		Responses: map[int]interface{}{http.StatusOK: v},
+	}})
+	return doc.Paths["/probe"]["get"].Responses["200"].Content["application/json"].Schema, doc.Components.Schemas
+}
+
+// assertSameModel 断言 Go 类型 typ 生成的 schema 与文档中的 schema 一致，包括引用到的全部 components
+func assertSameModel(t *testing.T, what string, typ reflect.Type, want *openapi.Schema, spec *openapi.Document) {
+	t.Helper()
+	got, components := probe(reflect.Zero(typ).Interface())
+	if g, w := jsonString(got), jsonString(want); g != w {
+		t.Errorf("%s: client uses %s (%s), spec has %s", what, typ, g, w)
+		return
+	}
+	for name, schema := range components {
+		if g, w := jsonString(schema), jsonString(spec.Components.Schemas[name]); g != w {
+			t.Errorf("%s: schema %s differs between client model and spec:\nclient %s\nspec   %s", what, name, g, w)
+		}
+	}
+}
+
+func jsonString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// modelParam 返回方法参数中 pkg/model 的类型
+func modelParam(m reflect.Method) reflect.Type {
+	modelPkg := reflect.TypeOf(model.ErrorResponse{}).PkgPath()
+	for i := 1; i < m.Type.NumIn(); i++ {
+		in := m.Type.In(i)
+		for in.Kind() == reflect.Ptr {
+			in = in.Elem()
+		}
+		if in.PkgPath() == modelPkg {
+			return in
+		}
+	}
+	return nil
+}
+
+// resolve 展开 $ref
+func resolve(spec *openapi.Document, s *openapi.Schema) *openapi.Schema {
+	if s != nil && s.Ref != "" {
+		return spec.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+type recorded struct {
+	method, path string
+	body         []byte
+}
+
+func TestClientMatchesSpec(t *testing.T) {
+	spec := app.Spec()
+	ops := operations(spec)
+
+	var mu sync.Mutex
+	var last recorded
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		last = recorded{method: r.Method, path: r.URL.Path, body: body}
+		mu.Unlock()
+		for _, o := range ops {
+			if o.method != r.Method || !matchPath(o.path, r.URL.Path) {
+				continue
+			}
+			status, schema := successResponse(o.op)
+			if status == http.StatusNoContent {
+				w.WriteHeader(status)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			if schema != nil && schema.Type == "array" {
+				io.WriteString(w, "[]")
+			} else {
+				io.WriteString(w, "{}")
+			}
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	c, err := client.New(srv.URL, client.WithRetry(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientType := reflect.TypeOf(c)
+
+	covered := make(map[string]bool)
+	methods := make(map[string]bool)
+	for _, tc := range clientCases {
+		covered[tc.operationID] = true
+		methods[tc.method] = true
+		o, ok := ops[tc.operationID]
+		if !ok {
+			t.Errorf("client method %s maps to operation %s which is not in the spec", tc.method, tc.operationID)
+			continue
+		}
+		m, ok := clientType.MethodByName(tc.method)
+		if !ok {
+			t.Errorf("operation %s: client has no method %s", tc.operationID, tc.method)
+			continue
+		}
+
+		if err := tc.call(context.Background(), c); err != nil {
+			t.Errorf("%s: %v", tc.method, err)
+			continue
+		}
+		mu.Lock()
+		got := last
+		mu.Unlock()
+		if got.method != o.method || !matchPath(o.path, got.path) {
+			t.Errorf("%s sent %s %s, spec operation %s is %s %s", tc.method, got.method, got.path, tc.operationID, o.method, o.path)
+		}
+
+		// 请求体：方法参数中的模型类型与文档一致，实际发送的字段都在文档中
+		if o.op.RequestBody == nil {
+			if len(got.body) != 0 {
+				t.Errorf("%s sent a body but %s has no request body", tc.method, tc.operationID)
+			}
+		} else {
+			want := o.op.RequestBody.Content["application/json"].Schema
+			if in := modelParam(m); in != nil {
+				assertSameModel(t, tc.method+" request", in, want, spec)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(got.body, &fields); err != nil {
+				t.Errorf("%s request body is not a JSON object: %v", tc.method, err)
+			}
+			props := resolve(spec, want).Properties
+			for name := range fields {
+				if _, ok := props[name]; !ok {
+					t.Errorf("%s sends field %q not described by %s", tc.method, name, tc.operationID)
+				}
+			}
+		}
+
+		// 响应体：方法的首个返回值与文档中 2xx 响应的模型一致
+		_, want := successResponse(o.op)
+		if m.Type.NumOut() == 1 {
+			if want != nil {
+				t.Errorf("%s returns no body but %s responds with %s", tc.method, tc.operationID, jsonString(want))
+			}
+		} else {
+			assertSameModel(t, tc.method+" response", m.Type.Out(0), want, spec)
+		}
+	}
+
+	for id, o := range ops {
+		if !notInClient[id] && !covered[id] {
+			t.Errorf("spec operation %s (%s %s) has no client method", id, o.method, o.path)
+		}
+	}
+	for i := 0; i < clientType.NumMethod(); i++ {
+		name := clientType.Method(i).Name
+		if !methods[name] && !genericMethods[name] {
+			t.Errorf("client method %s has no spec operation", name)
+		}
+	}
+}
+
+func TestExportPathsAcceptFormat(t *testing.T) {
+	spec := app.Spec()
+	for _, path := range []string{model.DeptResourcePath, model.NodeResourcePath, model.EnvResourcePath, model.CostReportPath} {
+		op := spec.Paths[path]["get"]
+		if op == nil {
+			t.Errorf("export path %s is not in the spec", path)
+			continue
+		}
+		found := false
+		for _, p := range op.Parameters {
+			found = found || (p.In == "query" && p.Name == "format")
+		}
+		if !found {
+			t.Errorf("export path %s does not document the format parameter", path)
+		}
+	}
+}
+
+func TestReserveIsNotRetried(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c, err := client.New(srv.URL, client.WithRetry(2, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(send func() error) int {
+		mu.Lock()
+		calls = 0
+		mu.Unlock()
+		if err := send(); err == nil {
+			t.Error("expected an error for 503")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+	for _, tc := range []struct {
+		name    string
+		reserve *model.ReserveOptions
+		want    int
+	}{
+		{"plain check", nil, 3},
+		{"reserving check", &model.ReserveOptions{TTL: "1m"}, 1},
+	} {
+		if n := count(func() error {
+			_, err := c.CheckDeptLimit(context.Background(), &model.DeptResourceQuotaRequest{Dept: "dept", Reserve: tc.reserve})
+			return err
+		}); n != tc.want {
+			t.Errorf("CheckDeptLimit %s: sent %d requests, want %d", tc.name, n, tc.want)
+		}
+		if n := count(func() error {
+			_, err := c.CheckDeptLimitByManifest(context.Background(), &model.ManifestQuotaRequest{Dept: "dept", Reserve: tc.reserve})
+			return err
+		}); n != tc.want {
+			t.Errorf("CheckDeptLimitByManifest %s: sent %d requests, want %d", tc.name, n, tc.want)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"k8s-admin-informer/pkg/model"
+)
+
+// CacheOptions 对应资源查询接口的 refresh/maxAge/minVersion 参数
+type CacheOptions struct {
+	// Refresh 强制服务端同步重算
+	Refresh bool
+	// MaxAge 可接受的缓存最大时长，0 表示使用服务端默认 TTL
+	MaxAge time.Duration
+	// MinVersion 最低快照版本，服务端最多等待 SNAPSHOT_MAX_WAIT
+	MinVersion uint64
+	// Snapshot 非 nil 时写入响应的快照信息
+	Snapshot *SnapshotInfo
+}
+
+// SnapshotInfo 资源查询响应的快照信息
+type SnapshotInfo struct {
+	Version   uint64
+	Generated time.Time
+	// Cached 为 true 表示响应来自缓存
+	Cached bool
+}
+
+func (o *CacheOptions) values() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+	if o.Refresh {
+		q.Set("refresh", "true")
+	}
+	if o.MaxAge > 0 {
+		q.Set("maxAge", o.MaxAge.String())
+	}
+	if o.MinVersion > 0 {
+		q.Set("minVersion", strconv.FormatUint(o.MinVersion, 10))
+	}
+	return q
+}
+
+// readSnapshot 从响应头读取快照信息
+func (o *CacheOptions) readSnapshot(resp *http.Response) {
+	if o == nil || o.Snapshot == nil {
+		return
+	}
+	o.Snapshot.Version, _ = strconv.ParseUint(resp.Header.Get(model.SnapshotVersionHeader), 10, 64)
+	o.Snapshot.Generated, _ = time.Parse(time.RFC3339, resp.Header.Get(model.GeneratedAtHeader))
+	o.Snapshot.Cached = resp.Header.Get(model.CacheHeader) == "HIT"
+}
+
+// GetWorkloadInstance 查询工作负载及其 pod、event、service
+func (c *Client) GetWorkloadInstance(ctx context.Context, req *model.GetWorkloadInstanceRequest) (*model.GetWorkloadInstanceResponse, error) {
+	resp, data, err := c.do(ctx, http.MethodPost, model.GetWorkloadInstancePath, nil, req)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.GetWorkloadInstanceResponse{}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CheckDeptLimit 检查请求资源是否超过部门配额。
+// 超出配额属于正常的校验结果：返回 Success=false 的响应且 error 为 nil；携带 Reserve 时不重试
+func (c *Client) CheckDeptLimit(ctx context.Context, req *model.DeptResourceQuotaRequest) (*model.DeptResourceQuotaResponse, error) {
+	send := c.do
+	if req != nil && req.Reserve != nil {
+		send = c.doOnce
+	}
+	resp, data, err := send(ctx, http.MethodPost, model.DeptCheckLimitPath, nil, req)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.DeptResourceQuotaResponse{}
+	if resp.StatusCode == http.StatusBadRequest {
+		// 400 既可能是校验未通过，也可能是请求体非法，通过 reason 字段区分
+		if json.Unmarshal(data, out) == nil && out.Reason != "" {
+			return out, nil
+		}
+		return nil, newAPIError(resp.StatusCode, data)
+	}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NodeResources 获取节点资源
+func (c *Client) NodeResources(ctx context.Context, opts *CacheOptions) (*model.NodeList, error) {
+	resp, data, err := c.do(ctx, http.MethodGet, model.NodeResourcePath, opts.values(), nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.NodeList{}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	opts.readSnapshot(resp)
+	return out, nil
+}
+
+// DeptResources 获取全部部门资源
+func (c *Client) DeptResources(ctx context.Context, opts *CacheOptions) ([]model.DeptResource, error) {
+	resp, data, err := c.do(ctx, http.MethodGet, model.DeptResourcePath, opts.values(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var out []model.DeptResource
+	if err := decode(resp, data, &out); err != nil {
+		return nil, err
+	}
+	opts.readSnapshot(resp)
+	return out, nil
+}
+
+// Export 以 format（json、yaml、csv 或 xlsx）导出报表，path 为 model.DeptResourcePath、model.CostReportPath、
+// model.NodeResourcePath 或 model.EnvResourcePath，query 为接口的其他查询参数，返回响应体原文
+func (c *Client) Export(ctx context.Context, path, format string, query url.Values) ([]byte, error) {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("format", format)
+	resp, data, err := c.do(ctx, http.MethodGet, path, q, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newAPIError(resp.StatusCode, data)
+	}
+	return data, nil
+}
+
+// ClusterResources 获取集群资源
+func (c *Client) ClusterResources(ctx context.Context) (*model.ClusterResource, error) {
+	resp, data, err := c.do(ctx, http.MethodGet, model.ClusterResourcePath, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.ClusterResource{}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EnvResources 获取部门下各环境资源，键为 namespaceGroup
+func (c *Client) EnvResources(ctx context.Context, dept string) (map[string]model.EnvResource, error) {
+	q := url.Values{}
+	q.Set("dept", dept)
+	resp, data, err := c.do(ctx, http.MethodGet, model.EnvResourcePath, q, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]model.EnvResource)
+	if err := decode(resp, data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CheckDeptLimitByManifest 根据工作负载清单或副本增量检查部门配额，超出配额时返回 Success=false 且 error 为 nil；
+// 携带 Reserve 时不重试
+func (c *Client) CheckDeptLimitByManifest(ctx context.Context, req *model.ManifestQuotaRequest) (*model.ManifestQuotaResponse, error) {
+	send := c.do
+	if req != nil && req.Reserve != nil {
+		send = c.doOnce
+	}
+	resp, data, err := send(ctx, http.MethodPost, model.DeptCheckManifestPath, nil, req)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.ManifestQuotaResponse{}
+	if resp.StatusCode == http.StatusBadRequest {
+		if json.Unmarshal(data, out) == nil && out.Reason != "" {
+			return out, nil
+		}
+		return nil, newAPIError(resp.StatusCode, data)
+	}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListReservations 列出未过期的配额预留，dept 为空时返回全部
+func (c *Client) ListReservations(ctx context.Context, dept string) (*model.ReservationList, error) {
+	q := url.Values{}
+	if dept != "" {
+		q.Set("dept", dept)
+	}
+	resp, data, err := c.do(ctx, http.MethodGet, model.DeptReservationsPath, q, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.ReservationList{}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LogLevel 查询服务当前日志级别
+func (c *Client) LogLevel(ctx context.Context) (*model.LogLevel, error) {
+	resp, data, err := c.do(ctx, http.MethodGet, model.AdminLogLevelPath, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.LogLevel{}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetLogLevel 调整服务日志级别，重启后恢复
+func (c *Client) SetLogLevel(ctx context.Context, level string) (*model.LogLevel, error) {
+	resp, data, err := c.do(ctx, http.MethodPut, model.AdminLogLevelPath, nil, &model.LogLevel{Level: level})
+	if err != nil {
+		return nil, err
+	}
+	out := &model.LogLevel{}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReleaseReservation 释放配额预留，预留不存在时返回的错误满足 IsNotFound
+func (c *Client) ReleaseReservation(ctx context.Context, id string) error {
+	resp, data, err := c.do(ctx, http.MethodDelete, model.DeptReservationsPath+"/"+url.PathEscape(id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, data, nil)
+}
+
+// AuditQuery 对应审计查询接口的过滤参数，零值字段不限
+type AuditQuery struct {
+	Dept   string
+	Action string
+	// Since 只返回该时间之后的记录
+	Since time.Time
+	Limit int
+}
+
+func (o *AuditQuery) values() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+	if o.Dept != "" {
+		q.Set("dept", o.Dept)
+	}
+	if o.Action != "" {
+		q.Set("action", o.Action)
+	}
+	if !o.Since.IsZero() {
+		q.Set("since", o.Since.Format(time.RFC3339))
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return q
+}
+
+// AuditRecords 按时间倒序查询服务端内存中最近的审计记录
+func (c *Client) AuditRecords(ctx context.Context, opts *AuditQuery) (*model.AuditRecordList, error) {
+	resp, data, err := c.do(ctx, http.MethodGet, model.AuditRecordsPath, opts.values(), nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.AuditRecordList{}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CostQuery 对应成本报表接口的参数，零值字段使用服务端默认值
+type CostQuery struct {
+	From, To time.Time
+	// GroupBy dept、env 或 namespace
+	GroupBy string
+	Dept    string
+	// Idle none、separate 或 split
+	Idle string
+}
+
+func (o *CostQuery) values() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+	if !o.From.IsZero() {
+		q.Set("from", o.From.Format(time.RFC3339))
+	}
+	if !o.To.IsZero() {
+		q.Set("to", o.To.Format(time.RFC3339))
+	}
+	if o.GroupBy != "" {
+		q.Set("groupBy", o.GroupBy)
+	}
+	if o.Dept != "" {
+		q.Set("dept", o.Dept)
+	}
+	if o.Idle != "" {
+		q.Set("idle", o.Idle)
+	}
+	return q
+}
+
+// CostReport 查询一段时间内的部门成本，服务端未配置成本模型时返回 404
+func (c *Client) CostReport(ctx context.Context, opts *CostQuery) (*model.CostReport, error) {
+	resp, data, err := c.do(ctx, http.MethodGet, model.CostReportPath, opts.values(), nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.CostReport{}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"k8s-admin-informer/pkg/model"
+)
+
+// NodeResourcesV2 获取节点资源（v2，数值化）
+func (c *Client) NodeResourcesV2(ctx context.Context) (*model.NodeListV2, error) {
+	resp, data, err := c.do(ctx, http.MethodGet, model.NodeResourceV2Path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.NodeListV2{}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeptResourcesV2 获取部门资源（v2），dept 为空时返回全部部门
+func (c *Client) DeptResourcesV2(ctx context.Context, dept string) ([]model.DeptResourceV2, error) {
+	q := url.Values{}
+	if dept != "" {
+		q.Set("dept", dept)
+	}
+	resp, data, err := c.do(ctx, http.MethodGet, model.DeptResourceV2Path, q, nil)
+	if err != nil {
+		return nil, err
+	}
+	var out []model.DeptResourceV2
+	if err := decode(resp, data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ClusterResourcesV2 获取按节点类型汇总的集群资源（v2）
+func (c *Client) ClusterResourcesV2(ctx context.Context) (*model.ClusterResourceV2, error) {
+	resp, data, err := c.do(ctx, http.MethodGet, model.ClusterResourceV2Path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &model.ClusterResourceV2{}
+	if err := decode(resp, data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EnvResourcesV2 获取部门下各环境资源（v2）
+func (c *Client) EnvResourcesV2(ctx context.Context, dept string) ([]model.EnvResourceV2, error) {
+	q := url.Values{}
+	q.Set("dept", dept)
+	resp, data, err := c.do(ctx, http.MethodGet, model.EnvResourceV2Path, q, nil)
+	if err != nil {
+		return nil, err
+	}
+	var out []model.EnvResourceV2
+	if err := decode(resp, data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
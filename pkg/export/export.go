@@ -0,0 +1,162 @@
+// Package export 将资源报表输出为 JSON 之外的格式：按 format 参数或 Accept 请求头协商格式，
+// 把扁平化后的表格写为 CSV 或最小化的 XLSX 工作簿。
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format 输出格式
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+// formats 可协商的格式，顺序即 Accept 中 q 值相同时的优先级
+var formats = []struct {
+	format Format
+	types  []string
+}{
+	{FormatJSON, []string{"application/json"}},
+	{FormatYAML, []string{"application/yaml", "application/x-yaml", "text/yaml"}},
+	{FormatCSV, []string{"text/csv"}},
+	{FormatXLSX, []string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}},
+}
+
+// ContentType 格式对应的响应 Content-Type
+func (f Format) ContentType() string {
+	switch f {
+	case FormatYAML:
+		return "application/yaml; charset=utf-8"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/json; charset=utf-8"
+}
+
+// ErrNotAcceptable Accept 中没有可用的格式
+type ErrNotAcceptable struct {
+	Accept string
+}
+
+func (e *ErrNotAcceptable) Error() string {
+	return fmt.Sprintf("none of the accepted media types %q is supported, use json, yaml, csv or xlsx", e.Accept)
+}
+
+// Negotiate 选择输出格式：format 参数优先，取值 json、yaml、csv 或 xlsx；
+// 否则按 Accept 中 q 值最高的可用类型选择，Accept 为空或含 */* 时为 JSON
+func Negotiate(format, accept string) (Format, error) {
+	if format != "" {
+		f := Format(strings.ToLower(format))
+		if f == "yml" {
+			f = FormatYAML
+		}
+		for _, known := range formats {
+			if known.format == f {
+				return f, nil
+			}
+		}
+		return "", fmt.Errorf("unsupported format %q, use json, yaml, csv or xlsx", format)
+	}
+	if strings.TrimSpace(accept) == "" {
+		return FormatJSON, nil
+	}
+
+	type candidate struct {
+		format Format
+		q      float64
+		order  int
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		for i, known := range formats {
+			for _, t := range known.types {
+				if mediaType == t || mediaType == "*/*" || mediaType == "application/*" && strings.HasPrefix(t, "application/") ||
+					mediaType == "text/*" && strings.HasPrefix(t, "text/") {
+					candidates = append(candidates, candidate{format: known.format, q: q, order: i})
+				}
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return "", &ErrNotAcceptable{Accept: accept}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].q != candidates[j].q {
+			return candidates[i].q > candidates[j].q
+		}
+		return candidates[i].order < candidates[j].order
+	})
+	return candidates[0].format, nil
+}
+
+// Table 扁平化的报表，Rows 中的单元格为 string、int64、float64 或 nil（空单元格）
+type Table struct {
+	// Sheet XLSX 工作表名
+	Sheet   string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// WriteCSV 以表头加数据行写出 CSV；以 = + - @ 开头的文本单元格前加单引号，避免被表格软件当作公式执行
+func WriteCSV(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Columns); err != nil {
+		return err
+	}
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i := range record {
+			record[i] = ""
+			if i < len(row) {
+				record[i] = csvCell(row[i])
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if v != "" && strings.ContainsRune("=+-@", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
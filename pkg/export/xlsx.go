@@ -0,0 +1,125 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 最小化 XLSX 工作簿的固定部件：一个工作簿、一个工作表，字符串以 inlineStr 内联，不含样式表
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbookHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`
+	xlsxWorkbookTail  = `</sheets></workbook>`
+	xlsxWorksheetHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxWorksheetTail = `</sheetData></worksheet>`
+)
+
+// WriteXLSX 将表格写为只含一个工作表的 XLSX 工作簿，首行为表头，数值单元格保留为数字
+func WriteXLSX(w io.Writer, t Table) error {
+	zw := zip.NewWriter(w)
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/workbook.xml", xlsxWorkbookHead + `<sheet name="` + escapeXML(sheetName(t.Sheet)) + `" sheetId="1" r:id="rId1"/>` + xlsxWorkbookTail},
+		{"xl/worksheets/sheet1.xml", worksheet(t)},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func worksheet(t Table) string {
+	var b strings.Builder
+	b.WriteString(xlsxWorksheetHead)
+	header := make([]interface{}, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c
+	}
+	writeRow(&b, 1, header)
+	for i, row := range t.Rows {
+		writeRow(&b, i+2, row)
+	}
+	b.WriteString(xlsxWorksheetTail)
+	return b.String()
+}
+
+func writeRow(b *strings.Builder, n int, cells []interface{}) {
+	row := strconv.Itoa(n)
+	b.WriteString(`<row r="` + row + `">`)
+	for i, v := range cells {
+		ref := columnName(i) + row
+		switch v := v.(type) {
+		case nil:
+		case int64:
+			b.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatInt(v, 10) + `</v></c>`)
+		case float64:
+			b.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatFloat(v, 'g', -1, 64) + `</v></c>`)
+		case string:
+			b.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">` + escapeXML(v) + `</t></is></c>`)
+		}
+	}
+	b.WriteString(`</row>`)
+}
+
+// columnName 返回从 0 开始的列序号对应的列名：A…Z、AA…
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName 工作表名最长 31 个字符，且不能包含 : \ / ? * [ ]
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:\/?*[]`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if name == "" {
+		name = "Sheet1"
+	}
+	return name
+}
+
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
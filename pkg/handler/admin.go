@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"k8s-admin-informer/pkg/model"
+)
+
+// GetLogLevel 返回当前日志级别
+func GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, model.LogLevel{Level: log.GetLevel().String()})
+}
+
+// SetLogLevel 在运行时调整日志级别，重启后恢复为 LOG_LEVEL
+func SetLogLevel(c *gin.Context) {
+	var req model.LogLevel
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	level, err := log.ParseLevel(req.Level)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	previous := log.GetLevel()
+	log.SetLevel(level)
+	log.WithContext(c.Request.Context()).Warnf("日志级别由 %s 调整为 %s", previous, level)
+	c.JSON(http.StatusOK, model.LogLevel{Level: level.String()})
+}
+
+// Healthz 存活检查，进程能处理请求即返回 200
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, model.HealthStatus{Status: "ok"})
+}
+
+// Readyz 就绪检查，全部 informer 完成同步时返回 200，否则返回 503
+func (h *Handler) Readyz(c *gin.Context) {
+	status := model.HealthStatus{Status: "ok", Informers: make(map[string]bool, len(h.Informers))}
+	code := http.StatusOK
+	for name, inf := range h.Informers {
+		synced := inf.HasSynced()
+		status.Informers[name] = synced
+		if !synced {
+			status.Status = "not ready"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(code, status)
+}
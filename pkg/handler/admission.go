@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	admissionV1 "k8s.io/api/admission/v1"
+	autoscalingV1 "k8s.io/api/autoscaling/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s-admin-informer/pkg/audit"
+	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/logging"
+	"k8s-admin-informer/pkg/model"
+	"k8s-admin-informer/pkg/reservation"
+)
+
+// 准入校验模式
+const (
+	// AdmissionModeEnforce 超出配额时拒绝
+	AdmissionModeEnforce = "enforce"
+	// AdmissionModeWarn 超出配额时放行并返回警告
+	AdmissionModeWarn = "warn"
+)
+
+// AdmissionHandler 以 validating admission webhook 的方式对 Pod、Deployment、StatefulSet 的创建与更新
+// 以及 Deployment、StatefulSet 的 scale 子资源执行部门配额校验，校验逻辑与 checkLimit 相同（计入未过期的预留，
+// 为被校验对象本身预留的额度除外）
+type AdmissionHandler struct {
+	rsc *ResourceHandler
+	// mode 全局模式，enforce 或 warn
+	mode string
+	// exempt 豁免的命名空间，直接放行
+	exempt map[string]bool
+	// warnOnly 在 enforce 模式下仍只警告的命名空间
+	warnOnly map[string]bool
+	// checkOwnedPods 是否校验由控制器创建的 Pod，默认不校验，避免与工作负载校验重复计算
+	checkOwnedPods bool
+}
+
+// NewAdmissionHandler 从环境变量读取准入配置：
+// - ADMISSION_MODE enforce（默认）或 warn
+// - ADMISSION_EXEMPT_NAMESPACES 逗号分隔的豁免命名空间，默认 kube-system
+// - ADMISSION_WARN_NAMESPACES 逗号分隔的只警告命名空间
+// - ADMISSION_CHECK_OWNED_PODS 为 true 时也校验带 controller ownerReference 的 Pod
+func NewAdmissionHandler(rsc *ResourceHandler) *AdmissionHandler {
+	mode := strings.ToLower(os.Getenv("ADMISSION_MODE"))
+	switch mode {
+	case "":
+		mode = AdmissionModeEnforce
+	case AdmissionModeEnforce, AdmissionModeWarn:
+	default:
+		log.Warnf("未知的 ADMISSION_MODE %q，使用默认值 %s", mode, AdmissionModeEnforce)
+		mode = AdmissionModeEnforce
+	}
+	exempt := "kube-system"
+	if v, ok := os.LookupEnv("ADMISSION_EXEMPT_NAMESPACES"); ok {
+		exempt = v
+	}
+	return &AdmissionHandler{
+		rsc:            rsc,
+		mode:           mode,
+		exempt:         namespaceSet(exempt),
+		warnOnly:       namespaceSet(os.Getenv("ADMISSION_WARN_NAMESPACES")),
+		checkOwnedPods: os.Getenv("ADMISSION_CHECK_OWNED_PODS") == "true",
+	}
+}
+
+func namespaceSet(list string) map[string]bool {
+	out := make(map[string]bool)
+	for _, ns := range strings.Split(list, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			out[ns] = true
+		}
+	}
+	return out
+}
+
+// Validate 处理 AdmissionReview 请求
+func (h *AdmissionHandler) Validate(c *gin.Context) {
+	var review admissionV1.AdmissionReview
+	if err := c.BindJSON(&review); err != nil {
+		return
+	}
+	if review.Request == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "admission review without request"})
+		return
+	}
+	resp := h.review(c.Request.Context(), review.Request)
+	resp.UID = review.Request.UID
+	review.Response = resp
+	review.Request = nil
+	c.JSON(http.StatusOK, review)
+}
+
+func (h *AdmissionHandler) review(ctx context.Context, req *admissionV1.AdmissionRequest) *admissionV1.AdmissionResponse {
+	allow := &admissionV1.AdmissionResponse{Allowed: true}
+	if req.Operation != admissionV1.Create && req.Operation != admissionV1.Update {
+		return allow
+	}
+	if h.exempt[req.Namespace] {
+		return allow
+	}
+
+	target, err := h.requested(ctx, req)
+	if err != nil {
+		return &admissionV1.AdmissionResponse{Result: &metaV1.Status{
+			Status:  metaV1.StatusFailure,
+			Code:    http.StatusBadRequest,
+			Reason:  metaV1.StatusReasonBadRequest,
+			Message: err.Error(),
+		}}
+	}
+	if target == nil || target.dept == "" || !hasPositive(target.requested) {
+		return allow
+	}
+	dept, class, requested := target.dept, target.class, target.requested
+	quota := h.rsc.Handler.Informers[DeptResourceQuotaInformer].(*informer.DeptResourceQuotaInformer).GetDeptResourceQuotaByName(dept)
+	if quota == nil {
+		return allow
+	}
+
+	// 通过 checkLimit 为该对象预留的额度在其 Pod 出现前不会释放，此时不再计入，否则会与本次申请重复计算
+	result := h.rsc.checkAndReserve(quota, dept, classRequests{class: requested}, nil, 0, target.owner)
+	rec := decisionRecord(audit.ActionAdmission, dept, fmt.Sprintf("%s %s/%s", req.Kind.Kind, req.Namespace, req.Name),
+		model.NewResourceAmounts(requested), result)
+	rec.Method = string(req.Operation)
+	rec.Caller = model.AuditCaller{Name: req.UserInfo.Username, Groups: req.UserInfo.Groups, Source: audit.ActionAdmission}
+	h.rsc.audit.Log(rec)
+	if result.Success {
+		return allow
+	}
+	message := fmt.Sprintf("%s %s/%s denied by department %s quota: %s",
+		req.Kind.Kind, req.Namespace, req.Name, dept, strings.TrimPrefix(result.Reason, "After adding request, dept quota exceeded: "))
+	entry := logging.Object(req.Kind.Kind, req.Namespace, req.Name).WithContext(ctx).WithFields(log.Fields{logging.FieldDept: dept, logging.FieldClass: class})
+	if h.mode == AdmissionModeWarn || h.warnOnly[req.Namespace] {
+		entry.Warnf("准入校验（仅警告）: %s", message)
+		allow.Warnings = []string{message}
+		return allow
+	}
+	entry.Infof("准入校验拒绝: %s", message)
+	return &admissionV1.AdmissionResponse{Result: &metaV1.Status{
+		Status:  metaV1.StatusFailure,
+		Code:    http.StatusForbidden,
+		Reason:  metaV1.StatusReasonForbidden,
+		Message: message,
+	}}
+}
+
+// admissionTarget 准入对象带来的新增申请量
+type admissionTarget struct {
+	dept      string
+	class     NodeType
+	requested v1.ResourceList
+	// owner 对象本身，为它预留的额度不计入校验
+	owner *reservation.Owner
+}
+
+// requested 推算对象带来的新增申请量：创建时为全部副本，更新时为新旧差值中增加的部分，
+// 更新改变了节点类型时新类型按全部副本计算；scale 子资源（kubectl scale、HPA）按副本增量与缓存中的 Pod 模板计算。
+// 返回 nil 表示不需要校验
+func (h *AdmissionHandler) requested(ctx context.Context, req *admissionV1.AdmissionRequest) (*admissionTarget, error) {
+	switch req.Kind.Kind {
+	case "Pod":
+		var pod v1.Pod
+		if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+			return nil, fmt.Errorf("decode pod: %v", err)
+		}
+		if !h.checkOwnedPods && metaV1.GetControllerOf(&pod) != nil {
+			return nil, nil
+		}
+		class, _ := classOfPodSpec(&pod.Spec)
+		requested := podAdmissionTotal(&pod)
+		if req.Operation == admissionV1.Update && len(req.OldObject.Raw) > 0 {
+			var old v1.Pod
+			if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+				return nil, fmt.Errorf("decode old pod: %v", err)
+			}
+			if oldClass, _ := classOfPodSpec(&old.Spec); oldClass == class {
+				requested = positiveDelta(requested, podAdmissionTotal(&old))
+			}
+		}
+		return &admissionTarget{
+			dept:      pod.Labels["department"],
+			class:     class,
+			requested: requested,
+			owner:     &reservation.Owner{Namespace: req.Namespace, Releases: []string{pod.Labels["release"]}},
+		}, nil
+	case "Deployment", "StatefulSet":
+		w, err := decodeWorkloadManifest(req.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+		if w.namespace == "" {
+			w.namespace = req.Namespace
+		}
+		class, _ := classOfPodSpec(&w.template.Spec)
+		requested := workloadTotal(w, w.replicas)
+		if req.Operation == admissionV1.Update && len(req.OldObject.Raw) > 0 {
+			old, err := decodeWorkloadManifest(req.OldObject.Raw)
+			if err != nil {
+				return nil, err
+			}
+			if oldClass, _ := classOfPodSpec(&old.template.Spec); oldClass == class {
+				requested = positiveDelta(requested, workloadTotal(old, old.replicas))
+			}
+		}
+		return &admissionTarget{dept: w.dept(), class: class, requested: requested, owner: w.owner()}, nil
+	case "Scale":
+		if req.SubResource != "scale" {
+			return nil, nil
+		}
+		var scale autoscalingV1.Scale
+		if err := json.Unmarshal(req.Object.Raw, &scale); err != nil {
+			return nil, fmt.Errorf("decode scale: %v", err)
+		}
+		w := h.rsc.cachedWorkload(ctx, strings.TrimSuffix(req.Resource.Resource, "s"), req.Namespace, req.Name)
+		if w == nil {
+			// 缓存中还没有该工作负载，无法得知 Pod 模板，交由工作负载自身的校验
+			return nil, nil
+		}
+		before := w.replicas
+		if req.Operation == admissionV1.Update && len(req.OldObject.Raw) > 0 {
+			var old autoscalingV1.Scale
+			if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+				return nil, fmt.Errorf("decode old scale: %v", err)
+			}
+			before = old.Spec.Replicas
+		}
+		class, _ := classOfPodSpec(&w.template.Spec)
+		return &admissionTarget{
+			dept:      w.dept(),
+			class:     class,
+			requested: workloadTotal(w, scale.Spec.Replicas-before),
+			owner:     w.owner(),
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// podAdmissionTotal 单个 Pod 的有效 limits，pods 记为 1
+func podAdmissionTotal(pod *v1.Pod) v1.ResourceList {
+	total := podSpecLimits(&pod.Spec)
+	total[v1.ResourcePods] = *resource.NewQuantity(1, resource.DecimalSI)
+	return total
+}
+
+func hasPositive(list v1.ResourceList) bool {
+	for _, q := range list {
+		if q.Sign() > 0 {
+			return true
+		}
+	}
+	return false
+}
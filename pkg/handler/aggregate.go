@@ -0,0 +1,218 @@
+package handler
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+
+	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/model"
+)
+
+// deptAggItem 部门增量聚合项：按节点类型记录已调度 Pod 的实时用量（cpu/memory）、有效 requests/limits 之和与 Pod 数，
+// 以及未调度 Pod 的个数与 requests 之和（待调度需求）
+type deptAggItem struct {
+	usage           map[NodeType]v1.ResourceList
+	requests        map[NodeType]v1.ResourceList
+	limits          map[NodeType]v1.ResourceList
+	pods            int
+	pendingPods     map[NodeType]int
+	pendingRequests map[NodeType]v1.ResourceList
+}
+
+// podRecord 记录单个 Pod 已计入部门聚合的值，便于更新、删除时扣减；按 Pod UID 保存，
+// 同名重建的 Pod（如 StatefulSet）是新的记录
+type podRecord struct {
+	// key 为 namespace/name，用于查找指标
+	key  string
+	dept string
+	// env 为 namespaceGroup 标签，用于按环境核算成本
+	env   string
+	arch  NodeType
+	usage v1.ResourceList
+	// requests/limits 为 Pod 的有效 requests/limits
+	requests v1.ResourceList
+	limits   v1.ResourceList
+	// pending 为 true 表示 Pod 尚未调度，arch 为推断的节点类型，requests 计入待调度需求
+	pending bool
+}
+
+// nodeRecord 节点增量聚合项
+type nodeRecord struct {
+	nodeType    NodeType
+	capacity    v1.ResourceList
+	allocatable v1.ResourceList
+	usage       v1.ResourceList
+}
+
+func newDeptAggItem() *deptAggItem {
+	return &deptAggItem{
+		usage:           make(map[NodeType]v1.ResourceList),
+		requests:        make(map[NodeType]v1.ResourceList),
+		limits:          make(map[NodeType]v1.ResourceList),
+		pendingPods:     make(map[NodeType]int),
+		pendingRequests: make(map[NodeType]v1.ResourceList),
+	}
+}
+
+// add 计入一条 Pod 记录：已调度的计入用量与 Pod 数，未调度的计入待调度需求
+func (a *deptAggItem) add(rec podRecord) {
+	if rec.pending {
+		a.pendingPods[rec.arch]++
+		a.pendingRequests[rec.arch] = addResourceList(a.pendingRequests[rec.arch], rec.requests)
+		return
+	}
+	a.usage[rec.arch] = addResourceList(a.usage[rec.arch], rec.usage)
+	a.requests[rec.arch] = addResourceList(a.requests[rec.arch], rec.requests)
+	a.limits[rec.arch] = addResourceList(a.limits[rec.arch], rec.limits)
+	a.pods++
+}
+
+// sub 扣减一条 Pod 记录，结果不小于 0
+func (a *deptAggItem) sub(rec podRecord) {
+	if rec.pending {
+		if a.pendingPods[rec.arch] > 0 {
+			a.pendingPods[rec.arch]--
+		}
+		if list := a.pendingRequests[rec.arch]; list != nil {
+			subResourceList(list, rec.requests)
+		}
+		return
+	}
+	if list := a.usage[rec.arch]; list != nil {
+		subResourceList(list, rec.usage)
+	}
+	if list := a.requests[rec.arch]; list != nil {
+		subResourceList(list, rec.requests)
+	}
+	if list := a.limits[rec.arch]; list != nil {
+		subResourceList(list, rec.limits)
+	}
+	if a.pods > 0 {
+		a.pods--
+	}
+}
+
+// pendingDemand 返回某类节点上的待调度 Pod 数与内存 requests
+func (a *deptAggItem) pendingDemand(t NodeType) model.PendingDemand {
+	return model.PendingDemand{Pods: a.pendingPods[t], Memory: memoryString(a.pendingRequests[t])}
+}
+
+// podRecordOf 生成 Pod 的聚合记录（不含用量），不带部门标签或已结束的 Pod 不计入
+func (h *ResourceHandler) podRecordOf(key string, pod *v1.Pod) (podRecord, bool) {
+	dept := pod.Labels["department"]
+	if dept == "" || podTerminated(pod) {
+		return podRecord{}, false
+	}
+	requests, limits := podRequestsAndLimits(pod)
+	if podPending(pod) {
+		class, _ := classOfPodSpec(&pod.Spec)
+		return podRecord{key: key, dept: dept, env: pod.Labels["namespaceGroup"], arch: class, usage: zeroUsage(), requests: requests, limits: limits, pending: true}, true
+	}
+	return podRecord{key: key, dept: dept, env: pod.Labels["namespaceGroup"], arch: h.archOf(pod.Spec.NodeName), requests: requests, limits: limits}, true
+}
+
+// syncPod 按缓存中 Pod 的当前状态更新部门聚合：先扣减该名称下旧 UID 的记录，Pod 仍存在、带部门标签且未结束时再按 UID 计入新记录。
+// 用量只读取指标缓存，缓存中没有时沿用同一 UID 旧记录的用量；未调度的 Pod 没有用量
+func (h *ResourceHandler) syncPod(key string) {
+	pod, exists := h.Handler.Informers[PodInformer].(*informer.PodInformer).Get(key)
+	h.recomputeMu.Lock()
+	defer h.recomputeMu.Unlock()
+	oldUID, had := h.podUIDs[key]
+	old, hadRecord := h.podRecords[oldUID]
+	if had {
+		h.removePodRecordLocked(oldUID)
+	}
+	rec, ok := podRecord{}, false
+	if exists {
+		rec, ok = h.podRecordOf(key, pod)
+	}
+	if ok {
+		if !rec.pending {
+			usage, found := h.metrics.podUsage(key)
+			if !found {
+				if hadRecord && oldUID == pod.UID && !old.pending {
+					usage = old.usage
+				} else {
+					usage = zeroUsage()
+				}
+			}
+			rec.usage = usage
+		}
+		h.addPodRecordLocked(pod.UID, rec)
+	} else if !had {
+		return
+	}
+	h.scheduleRebuild(rebuildDept)
+}
+
+// addPodRecordLocked 计入 Pod 记录，调用方需持有 recomputeMu
+func (h *ResourceHandler) addPodRecordLocked(uid types.UID, rec podRecord) {
+	a := h.deptAgg[rec.dept]
+	if a == nil {
+		a = newDeptAggItem()
+		h.deptAgg[rec.dept] = a
+	}
+	a.add(rec)
+	h.podRecords[uid] = rec
+	h.podUIDs[rec.key] = uid
+}
+
+// removePodRecordLocked 扣减并删除 Pod 记录，调用方需持有 recomputeMu
+func (h *ResourceHandler) removePodRecordLocked(uid types.UID) {
+	rec, ok := h.podRecords[uid]
+	if !ok {
+		return
+	}
+	if a := h.deptAgg[rec.dept]; a != nil {
+		a.sub(rec)
+	}
+	delete(h.podRecords, uid)
+	if h.podUIDs[rec.key] == uid {
+		delete(h.podUIDs, rec.key)
+	}
+}
+
+// podRecordLocked 返回 namespace/name 当前对应的 Pod 记录，调用方需持有 recomputeMu
+func (h *ResourceHandler) podRecordLocked(key string) (podRecord, bool) {
+	uid, ok := h.podUIDs[key]
+	if !ok {
+		return podRecord{}, false
+	}
+	rec, ok := h.podRecords[uid]
+	return rec, ok
+}
+
+// syncNode 按缓存中节点的当前状态更新节点聚合，用量只读取指标缓存
+func (h *ResourceHandler) syncNode(name string) {
+	node, exists := h.Handler.Informers[NodeInformer].(*informer.NodeInformer).Get(name)
+	h.recomputeMu.Lock()
+	defer h.recomputeMu.Unlock()
+	if !exists {
+		delete(h.nodeAgg, name)
+		h.scheduleRebuild(rebuildNode)
+		return
+	}
+	usage, ok := h.metrics.nodeUsage(name)
+	if !ok {
+		usage = h.nodeAgg[name].usage
+	}
+	if usage == nil {
+		usage = v1.ResourceList{}
+	}
+	h.nodeAgg[name] = nodeRecord{
+		nodeType:    h.archOf(name),
+		capacity:    node.Status.Capacity.DeepCopy(),
+		allocatable: node.Status.Allocatable.DeepCopy(),
+		usage:       usage,
+	}
+	h.scheduleRebuild(rebuildNode)
+}
+
+// zeroUsage 返回 cpu/memory 均为 0 的用量
+func zeroUsage() v1.ResourceList {
+	return v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("0"),
+		v1.ResourceMemory: resource.MustParse("0Mi"),
+	}
+}
@@ -0,0 +1,479 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"k8s-admin-informer/pkg/kubernetes/informer"
+)
+
+// newTestResourceHandler 创建不连接集群的 ResourceHandler：informer 不启动，测试直接写入其缓存
+func newTestResourceHandler(t *testing.T) *ResourceHandler {
+	t.Helper()
+	t.Setenv("AUDIT_LOG_FILE", "none")
+	t.Setenv("USAGE_PROVIDER", "")
+	t.Setenv("COST_MODEL_FILE", "")
+	h := NewResourceHandler(&Handler{
+		Informers: map[string]informer.Informer{
+			PodInformer:         informer.NewPodInformer(nil),
+			NodeInformer:        informer.NewNodeInformer(nil),
+			DeploymentInformer:  informer.NewDeploymentInformer(nil),
+			StatefulSetInformer: informer.NewStatefulSetInformer(nil),
+		},
+		stopCh: make(chan struct{}),
+	})
+	t.Cleanup(func() {
+		close(h.Handler.stopCh)
+		h.podQueue.ShutDown()
+		h.nodeQueue.ShutDown()
+		h.rebuildQueue.ShutDown()
+	})
+	return h
+}
+
+// withDeptQuotas 为 depts 各创建一个部门配额对象，由 fake dynamic client 提供给 DeptResourceQuotaInformer
+func withDeptQuotas(h *ResourceHandler, depts ...string) {
+	var objs []runtime.Object
+	for _, dept := range depts {
+		objs = append(objs, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "resource.wukong.io/v1alpha1",
+			"kind":       "DeptResourceQuota",
+			"metadata":   map[string]interface{}{"name": dept},
+			"spec": map[string]interface{}{
+				"deptName": dept,
+				"resources": map[string]interface{}{
+					"nonXc": map[string]interface{}{"limits": map[string]interface{}{"memory": "10Gi"}},
+				},
+			},
+		}})
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Group: "resource.wukong.io", Version: "v1alpha1", Resource: "deptresourcequotas"}: "DeptResourceQuotaList",
+	}, objs...)
+	h.Handler.Informers[DeptResourceQuotaInformer] = informer.NewDeptResourceQuotaInformer(client)
+}
+
+func podStore(h *ResourceHandler) cache.Store {
+	return h.Handler.Informers[PodInformer].(*informer.PodInformer).Store()
+}
+
+// testPodSpec 测试 Pod 的可变部分
+type testPodSpec struct {
+	dept     string
+	node     string
+	phase    v1.PodPhase
+	cpu      string
+	memory   string
+	initMem  string
+	overhead string
+}
+
+func newTestPod(namespace, name string, uid types.UID, s testPodSpec) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			UID:       uid,
+			Labels:    map[string]string{"namespaceGroup": namespace + "-env"},
+		},
+		Spec: v1.PodSpec{
+			NodeName: s.node,
+			Containers: []v1.Container{{
+				Name: "app",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(s.cpu), v1.ResourceMemory: resource.MustParse(s.memory)},
+					Limits:   v1.ResourceList{v1.ResourceCPU: resource.MustParse(s.cpu), v1.ResourceMemory: resource.MustParse(s.memory)},
+				},
+			}},
+		},
+		Status: v1.PodStatus{Phase: s.phase},
+	}
+	if s.dept != "" {
+		pod.Labels["department"] = s.dept
+	}
+	if s.initMem != "" {
+		pod.Spec.InitContainers = []v1.Container{{
+			Name: "init",
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse(s.initMem)},
+				Limits:   v1.ResourceList{v1.ResourceMemory: resource.MustParse(s.initMem)},
+			},
+		}}
+	}
+	if s.overhead != "" {
+		pod.Spec.Overhead = v1.ResourceList{v1.ResourceMemory: resource.MustParse(s.overhead)}
+	}
+	return pod
+}
+
+// recomputeFromStore 不经事件，直接从 PodInformer 缓存从头计算部门聚合，用量取指标缓存
+func recomputeFromStore(h *ResourceHandler) map[string]*deptAggItem {
+	agg := make(map[string]*deptAggItem)
+	usage := h.metrics.podUsages()
+	for _, obj := range podStore(h).List() {
+		pod := obj.(*v1.Pod)
+		key := pod.Namespace + "/" + pod.Name
+		rec, ok := h.podRecordOf(key, pod)
+		if !ok {
+			continue
+		}
+		if !rec.pending {
+			rec.usage = zeroUsage()
+			if u, ok := usage[key]; ok {
+				rec.usage = u
+			}
+		}
+		if agg[rec.dept] == nil {
+			agg[rec.dept] = newDeptAggItem()
+		}
+		agg[rec.dept].add(rec)
+	}
+	return agg
+}
+
+// assertMatchesRecompute 断言增量聚合与从头计算一致，且记录与索引互相对应
+func assertMatchesRecompute(t *testing.T, h *ResourceHandler, step string) {
+	t.Helper()
+	h.recomputeMu.Lock()
+	defer h.recomputeMu.Unlock()
+	if drift := deptAggDiff(h.deptAgg, recomputeFromStore(h)); len(drift) > 0 {
+		sort.Slice(drift, func(i, j int) bool {
+			return fmt.Sprint(drift[i]) < fmt.Sprint(drift[j])
+		})
+		t.Fatalf("%s: incremental aggregation differs from full recompute: %+v", step, drift)
+	}
+	if len(h.podUIDs) != len(h.podRecords) {
+		t.Fatalf("%s: %d name index entries for %d pod records", step, len(h.podUIDs), len(h.podRecords))
+	}
+	for key, uid := range h.podUIDs {
+		rec, ok := h.podRecords[uid]
+		if !ok || rec.key != key {
+			t.Fatalf("%s: name index %s -> %s has no matching record", step, key, uid)
+		}
+		obj, exists, _ := podStore(h).GetByKey(key)
+		if !exists || obj.(*v1.Pod).UID != uid {
+			t.Fatalf("%s: record %s (%s) is not the pod in the cache", step, key, uid)
+		}
+	}
+}
+
+// podEvents 模拟 informer 把事件交给 EnableEventDrivenInvalidation 的处理函数：事件对象经 enqueueKey 入队，再由 syncPod 处理
+type podEvents struct {
+	h     *ResourceHandler
+	queue workqueue.Interface
+}
+
+func newPodEvents(h *ResourceHandler) *podEvents {
+	return &podEvents{h: h, queue: workqueue.New()}
+}
+
+func (e *podEvents) add(pod *v1.Pod) {
+	if err := podStore(e.h).Add(pod); err != nil {
+		panic(err)
+	}
+	enqueueKey(e.queue, pod)
+}
+
+func (e *podEvents) update(pod *v1.Pod) {
+	if err := podStore(e.h).Update(pod); err != nil {
+		panic(err)
+	}
+	enqueueKey(e.queue, pod)
+}
+
+func (e *podEvents) delete(pod *v1.Pod) {
+	if err := podStore(e.h).Delete(pod); err != nil {
+		panic(err)
+	}
+	enqueueKey(e.queue, pod)
+}
+
+// deleteTombstone 漏掉删除事件后 relist 时，informer 以 DeletedFinalStateUnknown 通知删除
+func (e *podEvents) deleteTombstone(pod *v1.Pod) {
+	if err := podStore(e.h).Delete(pod); err != nil {
+		panic(err)
+	}
+	key, _ := cache.MetaNamespaceKeyFunc(pod)
+	enqueueKey(e.queue, cache.DeletedFinalStateUnknown{Key: key, Obj: pod})
+}
+
+// relist 用 pods 整体替换缓存，并像 informer 一样为新增、变化与消失的对象分发事件
+func (e *podEvents) relist(pods []*v1.Pod) {
+	before := make(map[string]*v1.Pod)
+	for _, obj := range podStore(e.h).List() {
+		pod := obj.(*v1.Pod)
+		before[pod.Namespace+"/"+pod.Name] = pod
+	}
+	list := make([]interface{}, 0, len(pods))
+	after := make(map[string]bool)
+	for _, pod := range pods {
+		list = append(list, pod)
+		after[pod.Namespace+"/"+pod.Name] = true
+	}
+	if err := podStore(e.h).Replace(list, ""); err != nil {
+		panic(err)
+	}
+	for _, pod := range pods {
+		enqueueKey(e.queue, pod)
+	}
+	for key, pod := range before {
+		if !after[key] {
+			enqueueKey(e.queue, cache.DeletedFinalStateUnknown{Key: key, Obj: pod})
+		}
+	}
+}
+
+// drain 处理队列中的全部 key
+func (e *podEvents) drain() {
+	for e.queue.Len() > 0 {
+		item, _ := e.queue.Get()
+		e.h.syncPod(item.(string))
+		e.queue.Done(item)
+	}
+}
+
+func TestPodAccountingTombstone(t *testing.T) {
+	h := newTestResourceHandler(t)
+	e := newPodEvents(h)
+	pod := newTestPod("ns", "app-1", "uid-1", testPodSpec{dept: "a", node: "b-node-1", cpu: "1", memory: "1Gi"})
+	e.add(pod)
+	e.drain()
+	assertMatchesRecompute(t, h, "add")
+
+	e.deleteTombstone(pod)
+	e.drain()
+	assertMatchesRecompute(t, h, "tombstone delete")
+	if len(h.podRecords) != 0 || h.deptAgg["a"].pods != 0 {
+		t.Fatalf("tombstone delete left %d records and %d pods", len(h.podRecords), h.deptAgg["a"].pods)
+	}
+
+	// 事件处理函数收到 tombstone 时同样能取出 Pod
+	if got, ok := informer.PodFromObject(cache.DeletedFinalStateUnknown{Key: "ns/app-1", Obj: pod}); !ok || got.UID != pod.UID {
+		t.Fatalf("PodFromObject did not unwrap the tombstone")
+	}
+	if _, ok := informer.PodFromObject(cache.DeletedFinalStateUnknown{Key: "ns/app-1", Obj: "garbage"}); ok {
+		t.Fatalf("PodFromObject accepted a tombstone without a pod")
+	}
+}
+
+func TestPodAccountingStatefulSetRecreate(t *testing.T) {
+	h := newTestResourceHandler(t)
+	e := newPodEvents(h)
+	h.metrics.pods = map[string]v1.ResourceList{
+		"db/web-0": {v1.ResourceCPU: resource.MustParse("200m"), v1.ResourceMemory: resource.MustParse("300Mi")},
+	}
+
+	old := newTestPod("db", "web-0", "uid-old", testPodSpec{dept: "a", node: "b-node-1", cpu: "1", memory: "1Gi"})
+	e.add(old)
+	e.drain()
+	assertMatchesRecompute(t, h, "first incarnation")
+
+	// 新 Pod 与旧 Pod 同名、换了节点类型和规格；旧 Pod 的删除事件晚于新 Pod 的创建事件到达
+	recreated := newTestPod("db", "web-0", "uid-new", testPodSpec{dept: "a", node: "kk-node-1", cpu: "2", memory: "4Gi"})
+	e.update(recreated)
+	e.drain()
+	assertMatchesRecompute(t, h, "recreated under the same name")
+	enqueueKey(e.queue, cache.DeletedFinalStateUnknown{Key: "db/web-0", Obj: old})
+	e.drain()
+	assertMatchesRecompute(t, h, "late delete of the old incarnation")
+
+	a := h.deptAgg["a"]
+	if a.pods != 1 {
+		t.Fatalf("pods = %d, want 1", a.pods)
+	}
+	if q := a.limits[NonXcNodeType][v1.ResourceMemory]; !q.IsZero() {
+		t.Fatalf("old incarnation still counted on nonXc: %s", q.String())
+	}
+	if q := a.limits[XcArmNodeType][v1.ResourceMemory]; q.Cmp(resource.MustParse("4Gi")) != 0 {
+		t.Fatalf("xcArm limits memory = %s, want 4Gi", q.String())
+	}
+	if uid := h.podUIDs["db/web-0"]; uid != "uid-new" {
+		t.Fatalf("name index points at %s, want uid-new", uid)
+	}
+
+	// 直接扣减已不在索引中的旧 UID 不影响新记录
+	h.recomputeMu.Lock()
+	h.addPodRecordLocked("uid-old", podRecord{key: "db/web-0", dept: "a", arch: NonXcNodeType, usage: zeroUsage(),
+		requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")}, limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")}})
+	h.podUIDs["db/web-0"] = "uid-new"
+	h.removePodRecordLocked("uid-old")
+	h.removePodRecordLocked("uid-missing")
+	h.recomputeMu.Unlock()
+	assertMatchesRecompute(t, h, "removing a stale UID")
+}
+
+func TestPodAccountingMissedDeleteAndRelist(t *testing.T) {
+	h := newTestResourceHandler(t)
+	e := newPodEvents(h)
+	p1 := newTestPod("ns", "p1", "uid-1", testPodSpec{dept: "a", node: "b-node-1", cpu: "1", memory: "1Gi"})
+	p2 := newTestPod("ns", "p2", "uid-2", testPodSpec{dept: "b", node: "hk-node-1", cpu: "500m", memory: "512Mi", initMem: "2Gi"})
+	p3 := newTestPod("ns", "p3", "uid-3", testPodSpec{dept: "a", cpu: "1", memory: "1Gi"})
+	for _, p := range []*v1.Pod{p1, p2, p3} {
+		e.add(p)
+	}
+	e.drain()
+	assertMatchesRecompute(t, h, "initial list")
+
+	// watch 断开期间 p1 被删除、p2 被同名重建、p3 被调度，relist 时一并补齐
+	p2b := newTestPod("ns", "p2", "uid-2b", testPodSpec{dept: "b", node: "b-node-2", cpu: "1", memory: "1Gi", overhead: "128Mi"})
+	p3b := newTestPod("ns", "p3", "uid-3", testPodSpec{dept: "a", node: "kk-node-1", cpu: "1", memory: "1Gi"})
+	e.relist([]*v1.Pod{p2b, p3b})
+	e.drain()
+	assertMatchesRecompute(t, h, "relist")
+	if _, ok := h.podRecords["uid-1"]; ok {
+		t.Fatalf("missed delete of p1 was not applied on relist")
+	}
+	if _, ok := h.podRecords["uid-2"]; ok {
+		t.Fatalf("old incarnation of p2 survived the relist")
+	}
+}
+
+// TestPodAccountingReplay 随机回放增删改序列（含同名重建、tombstone 删除、漏掉的事件与 relist），
+// 每次事件全部送达后增量聚合都应与从头计算一致
+func TestPodAccountingReplay(t *testing.T) {
+	depts := []string{"a", "b", ""}
+	nodes := []string{"b-node-1", "hk-node-1", "kk-node-1", ""}
+	cpus := []string{"100m", "1", "2"}
+	mems := []string{"128Mi", "1Gi", "3Gi"}
+	phases := []v1.PodPhase{v1.PodRunning, v1.PodRunning, v1.PodRunning, v1.PodPending, v1.PodSucceeded, v1.PodFailed}
+
+	for seed := int64(1); seed <= 20; seed++ {
+		t.Run(fmt.Sprintf("seed-%d", seed), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(seed))
+			h := newTestResourceHandler(t)
+			e := newPodEvents(h)
+			h.metrics.pods = map[string]v1.ResourceList{}
+			for i := 0; i < 8; i++ {
+				if rnd.Intn(2) == 0 {
+					h.metrics.pods[fmt.Sprintf("ns/pod-%d", i)] = v1.ResourceList{
+						v1.ResourceCPU:    *resource.NewMilliQuantity(int64(rnd.Intn(2000)), resource.DecimalSI),
+						v1.ResourceMemory: *resource.NewQuantity(int64(rnd.Intn(1<<30)), resource.BinarySI),
+					}
+				}
+			}
+
+			uidSeq := 0
+			randomPod := func(name string, uid types.UID) *v1.Pod {
+				s := testPodSpec{
+					dept:   depts[rnd.Intn(len(depts))],
+					node:   nodes[rnd.Intn(len(nodes))],
+					phase:  phases[rnd.Intn(len(phases))],
+					cpu:    cpus[rnd.Intn(len(cpus))],
+					memory: mems[rnd.Intn(len(mems))],
+				}
+				if rnd.Intn(4) == 0 {
+					s.initMem = mems[rnd.Intn(len(mems))]
+				}
+				if rnd.Intn(4) == 0 {
+					s.overhead = "64Mi"
+				}
+				if uid == "" {
+					uidSeq++
+					uid = types.UID(fmt.Sprintf("uid-%d", uidSeq))
+				}
+				return newTestPod("ns", name, uid, s)
+			}
+			// server 是 API server 上的真实状态；漏掉的事件只改 server 不改 informer 缓存，直到 relist
+			server := make(map[string]*v1.Pod)
+
+			// missed 为 true 表示有事件未送达，此时增量结果允许落后，直到下一次 relist
+			missed := false
+			for step := 0; step < 300; step++ {
+				name := fmt.Sprintf("pod-%d", rnd.Intn(8))
+				existing := server[name]
+				var what string
+				switch op := rnd.Intn(10); {
+				case existing == nil:
+					what = "create " + name
+					server[name] = randomPod(name, "")
+					e.add(server[name])
+				case op < 3:
+					what = "update " + name
+					server[name] = randomPod(name, existing.UID)
+					e.update(server[name])
+				case op < 5:
+					what = "recreate " + name
+					server[name] = randomPod(name, "")
+					e.update(server[name])
+				case op == 5:
+					what = "delete " + name
+					delete(server, name)
+					e.delete(existing)
+				case op == 6:
+					what = "tombstone delete " + name
+					delete(server, name)
+					e.deleteTombstone(existing)
+				case op == 7:
+					what = "missed delete " + name
+					delete(server, name)
+					missed = true
+				case op == 8:
+					what = "missed recreate " + name
+					server[name] = randomPod(name, "")
+					missed = true
+				default:
+					what = "relist"
+					pods := make([]*v1.Pod, 0, len(server))
+					for _, pod := range server {
+						pods = append(pods, pod)
+					}
+					e.relist(pods)
+					missed = false
+				}
+				e.drain()
+				if !missed {
+					assertMatchesRecompute(t, h, fmt.Sprintf("step %d (%s)", step, what))
+				}
+			}
+		})
+	}
+}
+
+// TestRecomputeDeptResourceMatchesIncremental 强制重算与增量路径输出相同的字段与总量
+func TestRecomputeDeptResourceMatchesIncremental(t *testing.T) {
+	h := newTestResourceHandler(t)
+	withDeptQuotas(h, "a", "b", "c")
+	e := newPodEvents(h)
+	h.metrics.pods = map[string]v1.ResourceList{
+		"ns/run-1": {v1.ResourceCPU: resource.MustParse("300m"), v1.ResourceMemory: resource.MustParse("700Mi")},
+		"ns/run-2": {v1.ResourceCPU: resource.MustParse("1200m"), v1.ResourceMemory: resource.MustParse("2Gi")},
+	}
+	e.add(newTestPod("ns", "run-1", "uid-1", testPodSpec{dept: "a", node: "b-node-1", phase: v1.PodRunning, cpu: "1", memory: "1Gi", initMem: "2Gi"}))
+	e.add(newTestPod("ns", "run-2", "uid-2", testPodSpec{dept: "a", node: "kk-node-1", phase: v1.PodRunning, cpu: "2", memory: "4Gi", overhead: "128Mi"}))
+	e.add(newTestPod("ns", "run-3", "uid-3", testPodSpec{dept: "b", node: "hk-node-1", phase: v1.PodRunning, cpu: "500m", memory: "512Mi"}))
+	e.add(newTestPod("ns", "wait-1", "uid-4", testPodSpec{dept: "b", phase: v1.PodPending, cpu: "1", memory: "3Gi"}))
+	e.add(newTestPod("ns", "done-1", "uid-5", testPodSpec{dept: "a", node: "b-node-1", phase: v1.PodSucceeded, cpu: "1", memory: "1Gi"}))
+	e.drain()
+
+	h.recomputeMu.Lock()
+	incremental := h.buildDeptResourceFromAgg(context.Background())
+	h.recomputeMu.Unlock()
+	recomputed := h.RecomputeDeptResource()
+	if len(incremental) != 3 {
+		t.Fatalf("got %d departments, want 3", len(incremental))
+	}
+	if !reflect.DeepEqual(incremental, recomputed) {
+		t.Fatalf("recompute differs from incremental path:\nincremental: %+v\nrecomputed:  %+v", incremental, recomputed)
+	}
+	if a := recomputed[0]; a.Requests.XC.Arm["cpu"] != "2" || a.Used.NonXc.Cpu != "300m" || a.Pods != 2 {
+		t.Fatalf("recompute is missing requests/cpu/pods: %+v", a)
+	}
+	if b := recomputed[1]; b.Pending.NonXc.Pods != 1 {
+		t.Fatalf("recompute is missing pending demand: %+v", b.Pending)
+	}
+}
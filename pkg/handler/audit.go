@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"k8s-admin-informer/pkg/audit"
+	"k8s-admin-informer/pkg/authz"
+	"k8s-admin-informer/pkg/model"
+)
+
+// newAuditLogger 按环境变量创建审计记录器：
+// - AUDIT_LOG_FILE JSON Lines 审计文件，默认 audit.jsonl，设为 none 表示不写文件
+// - AUDIT_LOG_MAX_SIZE 单个文件大小上限（Quantity，如 100Mi），默认 100Mi，超过时轮转
+// - AUDIT_LOG_MAX_BACKUPS 保留的历史文件数，默认 5
+// - AUDIT_SINK_URL 可选的 HTTP 接收端，记录按批以 JSON 数组 POST
+// - AUDIT_SINK_BUFFER 等待发送的记录上限，默认 1000；AUDIT_SINK_TIMEOUT 单次发送超时，默认 5s
+// - AUDIT_MEMORY_RECORDS 内存中保留供查询的最近记录数，默认 1000
+func newAuditLogger() *audit.Logger {
+	cfg := audit.Config{
+		File:        os.Getenv("AUDIT_LOG_FILE"),
+		MaxSize:     100 << 20,
+		MaxBackups:  5,
+		SinkURL:     os.Getenv("AUDIT_SINK_URL"),
+		SinkBuffer:  1000,
+		SinkTimeout: 5 * time.Second,
+		Memory:      1000,
+	}
+	switch cfg.File {
+	case "":
+		cfg.File = "audit.jsonl"
+	case "none":
+		cfg.File = ""
+	}
+	if v := os.Getenv("AUDIT_LOG_MAX_SIZE"); v != "" {
+		if q, err := resource.ParseQuantity(v); err == nil && q.Value() > 0 {
+			cfg.MaxSize = q.Value()
+		} else {
+			log.Warnf("解析 AUDIT_LOG_MAX_SIZE 失败，使用默认值 %d，错误: %v", cfg.MaxSize, err)
+		}
+	}
+	if v := os.Getenv("AUDIT_LOG_MAX_BACKUPS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxBackups = n
+		} else {
+			log.Warnf("解析 AUDIT_LOG_MAX_BACKUPS 失败，使用默认值 %d，错误: %v", cfg.MaxBackups, err)
+		}
+	}
+	if v := os.Getenv("AUDIT_SINK_BUFFER"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.SinkBuffer = n
+		} else {
+			log.Warnf("解析 AUDIT_SINK_BUFFER 失败，使用默认值 %d，错误: %v", cfg.SinkBuffer, err)
+		}
+	}
+	if v := os.Getenv("AUDIT_SINK_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.SinkTimeout = d
+		} else {
+			log.Warnf("解析 AUDIT_SINK_TIMEOUT 失败，使用默认值 %s，错误: %v", cfg.SinkTimeout.String(), err)
+		}
+	}
+	if v := os.Getenv("AUDIT_MEMORY_RECORDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.Memory = n
+		} else {
+			log.Warnf("解析 AUDIT_MEMORY_RECORDS 失败，使用默认值 %d，错误: %v", cfg.Memory, err)
+		}
+	}
+	return audit.New(cfg)
+}
+
+// AuditMiddleware 记录未被处理器单独审计的写请求
+func (h *ResourceHandler) AuditMiddleware() gin.HandlerFunc {
+	return h.audit.Middleware()
+}
+
+// ListAuditRecords 按时间倒序返回内存中最近的审计记录，支持查询参数：
+// - dept 部门，action 动作（checkLimit、checkManifest、admission、write）
+// - since 起始时间（RFC3339）或回溯时长（如 24h）
+// - limit 最多返回条数，默认 100
+// 启用部门授权时只返回调用方可见部门的记录
+func (h *ResourceHandler) ListAuditRecords(c *gin.Context) {
+	q := audit.Query{Dept: c.Query("dept"), Action: c.Query("action"), Limit: 100}
+	if v := c.Query("since"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			q.Since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			q.Since = t
+		} else {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "since must be RFC3339 time or duration"})
+			return
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "limit must be a positive integer"})
+			return
+		}
+		q.Limit = n
+	}
+	scope := authz.ScopeFrom(c.Request.Context())
+	if q.Dept != "" && !scope.Allows(q.Dept) {
+		authz.Forbidden(c, "department "+q.Dept+" is not visible to the caller")
+		return
+	}
+	if !scope.Admin() {
+		q.Allow = scope.Allows
+	}
+	c.JSON(http.StatusOK, model.AuditRecordList{Items: h.audit.Query(q)})
+}
+
+// decisionRecord 由配额校验的输入与结果生成审计记录，Checks 即校验时的配额、已用与已预留量
+func decisionRecord(action, dept, object string, input interface{}, resp model.DeptResourceQuotaResponse) model.AuditRecord {
+	allowed := resp.Success
+	rec := model.AuditRecord{
+		Action:  action,
+		Dept:    dept,
+		Object:  object,
+		Allowed: &allowed,
+		Reason:  resp.Reason,
+		Request: audit.Input(input),
+		Checks:  resp.Checks,
+	}
+	if resp.Reservation != nil {
+		rec.ReservationID = resp.Reservation.ID
+	}
+	return rec
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"k8s-admin-informer/pkg/auth"
+	"k8s-admin-informer/pkg/authz"
+)
+
+// NewAuthenticator 按环境变量创建接口认证器：
+// - AUTH_ENABLED 为 true 时启用认证，默认不认证
+// - AUTH_TOKEN_REVIEW 是否通过 TokenReview 校验 bearer token，默认 true（需 create tokenreviews 权限）
+// - AUTH_TOKEN_AUDIENCES 逗号分隔的 TokenReview audiences
+// - AUTH_CACHE_TTL 认证成功结果缓存时长，默认 1m；失败结果缓存 AUTH_FAILURE_CACHE_TTL，默认 10s
+// - AUTH_API_KEYS_FILE 静态 API key 文件，每行 "key,name[,group...]"
+// - AUTH_PUBLIC_PATHS 逗号分隔的免认证路径，默认 /metrics,/healthz,/readyz，以 / 结尾时按前缀匹配，设为 none 表示全部需要认证
+// - AUTH_PROXY_USER_HEADER 可信代理写入用户名的请求头（如 X-Remote-User），只接受来自 AUTH_TRUSTED_PROXIES（逗号分隔的 CIDR）的请求
+// - AUTH_PROXY_GROUP_HEADER 可信代理写入用户组的请求头，默认 X-Remote-Group
+func NewAuthenticator(handler *Handler) *auth.Authenticator {
+	cfg := auth.Config{
+		Enabled:         os.Getenv("AUTH_ENABLED") == "true",
+		TokenReview:     os.Getenv("AUTH_TOKEN_REVIEW") != "false",
+		Audiences:       splitList(os.Getenv("AUTH_TOKEN_AUDIENCES")),
+		CacheTTL:        time.Minute,
+		FailureCacheTTL: 10 * time.Second,
+		PublicPaths:     []string{"/metrics", "/healthz", "/readyz"},
+	}
+	if v := os.Getenv("AUTH_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.CacheTTL = d
+		} else {
+			log.Warnf("解析 AUTH_CACHE_TTL 失败，使用默认值 %s，错误: %v", cfg.CacheTTL.String(), err)
+		}
+	}
+	if v := os.Getenv("AUTH_FAILURE_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.FailureCacheTTL = d
+		} else {
+			log.Warnf("解析 AUTH_FAILURE_CACHE_TTL 失败，使用默认值 %s，错误: %v", cfg.FailureCacheTTL.String(), err)
+		}
+	}
+	if v, ok := os.LookupEnv("AUTH_PUBLIC_PATHS"); ok {
+		cfg.PublicPaths = nil
+		if v != "none" {
+			cfg.PublicPaths = splitList(v)
+		}
+	}
+	if header := os.Getenv("AUTH_PROXY_USER_HEADER"); header != "" {
+		for _, v := range splitList(os.Getenv("AUTH_TRUSTED_PROXIES")) {
+			if !strings.Contains(v, "/") {
+				if strings.Contains(v, ":") {
+					v += "/128"
+				} else {
+					v += "/32"
+				}
+			}
+			_, cidr, err := net.ParseCIDR(v)
+			if err != nil {
+				log.Warnf("解析 AUTH_TRUSTED_PROXIES 中的 %q 失败，忽略: %v", v, err)
+				continue
+			}
+			cfg.TrustedProxies = append(cfg.TrustedProxies, cidr)
+		}
+		if len(cfg.TrustedProxies) == 0 {
+			log.Warnf("设置了 AUTH_PROXY_USER_HEADER 但 AUTH_TRUSTED_PROXIES 为空，不接受代理身份")
+		} else {
+			cfg.ProxyUserHeader = header
+			cfg.ProxyGroupHeader = os.Getenv("AUTH_PROXY_GROUP_HEADER")
+			if cfg.ProxyGroupHeader == "" {
+				cfg.ProxyGroupHeader = "X-Remote-Group"
+			}
+		}
+	}
+	if file := os.Getenv("AUTH_API_KEYS_FILE"); file != "" {
+		keys, err := auth.LoadAPIKeys(file)
+		if err != nil {
+			log.Errorf("读取 AUTH_API_KEYS_FILE 失败，不启用静态 API key: %v", err)
+		} else {
+			cfg.APIKeys = keys
+		}
+	}
+	if cfg.Enabled {
+		log.Infof("已启用接口认证：TokenReview=%t，静态 API key %d 个，免认证路径 %v", cfg.TokenReview, len(cfg.APIKeys), cfg.PublicPaths)
+	}
+	if handler == nil || handler.client == nil {
+		return auth.NewAuthenticator(nil, cfg)
+	}
+	return auth.NewAuthenticator(handler.client, cfg)
+}
+
+// NewAuthorizer 按环境变量创建部门授权器：
+// - AUTHZ_ENABLED 为 true 时按调用方身份限定可见部门，默认不限定
+// - AUTHZ_POLICY_FILE YAML/JSON 策略文件，admins 可见全部部门，rules 将用户/用户组映射到部门；读取失败时不授予任何部门
+// - AUTHZ_SUBJECT_ACCESS_REVIEW 为 true 时，调用方在 namespace 中有 get pods 权限也可查看其中的工作负载（需 create subjectaccessreviews 权限）
+// - AUTHZ_CACHE_TTL SubjectAccessReview 结果缓存时长，默认 1m
+func NewAuthorizer(handler *Handler) *authz.Authorizer {
+	cfg := authz.Config{
+		Enabled:             os.Getenv("AUTHZ_ENABLED") == "true",
+		SubjectAccessReview: os.Getenv("AUTHZ_SUBJECT_ACCESS_REVIEW") == "true",
+		CacheTTL:            time.Minute,
+	}
+	if v := os.Getenv("AUTHZ_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.CacheTTL = d
+		} else {
+			log.Warnf("解析 AUTHZ_CACHE_TTL 失败，使用默认值 %s，错误: %v", cfg.CacheTTL.String(), err)
+		}
+	}
+	if file := os.Getenv("AUTHZ_POLICY_FILE"); file != "" {
+		policy, err := authz.LoadPolicy(file)
+		if err != nil {
+			log.Errorf("读取 AUTHZ_POLICY_FILE 失败，不授予任何部门: %v", err)
+		} else {
+			cfg.Policy = *policy
+		}
+	}
+	if cfg.Enabled {
+		log.Infof("已启用部门授权：规则 %d 条，SubjectAccessReview=%t", len(cfg.Policy.Rules), cfg.SubjectAccessReview)
+		if os.Getenv("AUTH_ENABLED") != "true" {
+			log.Warnf("启用了 AUTHZ_ENABLED 但未启用 AUTH_ENABLED，调用方没有身份，只能看到空结果")
+		}
+	}
+	if handler == nil || handler.client == nil {
+		return authz.NewAuthorizer(nil, cfg)
+	}
+	return authz.NewAuthorizer(handler.client, cfg)
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
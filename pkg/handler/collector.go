@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"k8s-admin-informer/pkg/kubernetes/informer"
+)
+
+// DeptCollector 在每次抓取时从部门配额对象与增量聚合快照生成部门指标，不访问 apiserver 或 metrics 来源；
+// 部门被删除后对应序列在下一次抓取时自然消失。cpu 单位为核，内存单位为字节
+type DeptCollector struct {
+	rsc *ResourceHandler
+
+	quota     *prometheus.Desc
+	announced *prometheus.Desc
+	usage     *prometheus.Desc
+	requests  *prometheus.Desc
+	limits    *prometheus.Desc
+	pods      *prometheus.Desc
+	pending   *prometheus.Desc
+
+	// 兼容旧版本的内存指标
+	legacyQuota *prometheus.Desc
+	legacyUsed  *prometheus.Desc
+	legacyPods  *prometheus.Desc
+}
+
+// NewDeptCollector 创建部门指标采集器，需注册到 prometheus Registry
+func NewDeptCollector(rsc *ResourceHandler) *DeptCollector {
+	classLabels := []string{"department", "class", "os", "arch", "resource"}
+	return &DeptCollector{
+		rsc: rsc,
+		quota: prometheus.NewDesc("dept_quota",
+			"Department quota from DeptResourceQuota spec by node class (type is requests or limits; cpu in cores, memory in bytes).",
+			append(classLabels, "type"), nil),
+		announced: prometheus.NewDesc("dept_quota_announced",
+			"Department usage announced in DeptResourceQuota status by node class (type is requests or limits).",
+			append(classLabels, "type"), nil),
+		usage: prometheus.NewDesc("dept_usage",
+			"Department live usage of scheduled pods by node class.",
+			classLabels, nil),
+		requests: prometheus.NewDesc("dept_requests",
+			"Sum of effective requests of department scheduled pods by node class.",
+			classLabels, nil),
+		limits: prometheus.NewDesc("dept_limits",
+			"Sum of effective limits of department scheduled pods by node class.",
+			classLabels, nil),
+		pods: prometheus.NewDesc("dept_pods",
+			"Number of department scheduled, non-terminated pods.",
+			[]string{"department"}, nil),
+		pending: prometheus.NewDesc("dept_pending_pods",
+			"Number of department unscheduled pods by inferred node class.",
+			[]string{"department", "class", "os", "arch"}, nil),
+		legacyQuota: prometheus.NewDesc("dept_memory_resource_quota_bytes",
+			"Department current resource quota bytes.",
+			[]string{"department", "os", "arch"}, nil),
+		legacyUsed: prometheus.NewDesc("dept_used_memory_quota_bytes",
+			"Department used memory resource quota bytes.",
+			[]string{"department", "os", "arch"}, nil),
+		legacyPods: prometheus.NewDesc("dept_current_pods_num_total",
+			"Department current pod counts.",
+			[]string{"department"}, nil),
+	}
+}
+
+// Describe 实现 prometheus.Collector
+func (c *DeptCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.quota, c.announced, c.usage, c.requests, c.limits, c.pods, c.pending,
+		c.legacyQuota, c.legacyUsed, c.legacyPods} {
+		ch <- d
+	}
+}
+
+// deptSnapshot 某个部门在采集时刻的聚合副本
+type deptSnapshot struct {
+	usage, requests, limits map[NodeType]v1.ResourceList
+	pods                    int
+	pendingPods             map[NodeType]int
+}
+
+// snapshot 在锁内复制增量聚合，采集在锁外进行
+func (c *DeptCollector) snapshot() map[string]deptSnapshot {
+	copyLists := func(in map[NodeType]v1.ResourceList) map[NodeType]v1.ResourceList {
+		out := make(map[NodeType]v1.ResourceList, len(in))
+		for t, list := range in {
+			out[t] = list.DeepCopy()
+		}
+		return out
+	}
+	c.rsc.recomputeMu.Lock()
+	defer c.rsc.recomputeMu.Unlock()
+	out := make(map[string]deptSnapshot, len(c.rsc.deptAgg))
+	for dept, a := range c.rsc.deptAgg {
+		pending := make(map[NodeType]int, len(a.pendingPods))
+		for t, n := range a.pendingPods {
+			pending[t] = n
+		}
+		out[dept] = deptSnapshot{
+			usage:       copyLists(a.usage),
+			requests:    copyLists(a.requests),
+			limits:      copyLists(a.limits),
+			pods:        a.pods,
+			pendingPods: pending,
+		}
+	}
+	return out
+}
+
+// Collect 实现 prometheus.Collector，只输出存在 DeptResourceQuota 的部门，同名部门只取第一个配额对象
+func (c *DeptCollector) Collect(ch chan<- prometheus.Metric) {
+	snap := c.snapshot()
+	seen := make(map[string]bool)
+	for _, quota := range c.rsc.Handler.Informers[DeptResourceQuotaInformer].(*informer.DeptResourceQuotaInformer).List() {
+		dept := quota.Spec.DeptName
+		if seen[dept] {
+			continue
+		}
+		seen[dept] = true
+		s := snap[dept]
+		for _, nc := range nodeClasses {
+			spec := nc.quota(&quota.Spec.Resources)
+			announced := nc.announced(&quota.Status.UsedResources)
+			for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+				labels := []string{dept, string(nc.Type), nc.OS, nc.Arch, string(name)}
+				if q, ok := spec.Requests[name]; ok {
+					ch <- prometheus.MustNewConstMetric(c.quota, prometheus.GaugeValue, quantityValue(q), append(labels, "requests")...)
+				}
+				if q, ok := spec.Limits[name]; ok {
+					ch <- prometheus.MustNewConstMetric(c.quota, prometheus.GaugeValue, quantityValue(q), append(labels, "limits")...)
+				}
+				if q, ok := announced.Requests[name]; ok {
+					ch <- prometheus.MustNewConstMetric(c.announced, prometheus.GaugeValue, quantityValue(q), append(labels, "requests")...)
+				}
+				if q, ok := announced.Limits[name]; ok {
+					ch <- prometheus.MustNewConstMetric(c.announced, prometheus.GaugeValue, quantityValue(q), append(labels, "limits")...)
+				}
+				ch <- prometheus.MustNewConstMetric(c.usage, prometheus.GaugeValue, quantityValue(s.usage[nc.Type][name]), labels...)
+				ch <- prometheus.MustNewConstMetric(c.requests, prometheus.GaugeValue, quantityValue(s.requests[nc.Type][name]), labels...)
+				ch <- prometheus.MustNewConstMetric(c.limits, prometheus.GaugeValue, quantityValue(s.limits[nc.Type][name]), labels...)
+			}
+			ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(s.pendingPods[nc.Type]), dept, string(nc.Type), nc.OS, nc.Arch)
+
+			ch <- prometheus.MustNewConstMetric(c.legacyQuota, prometheus.GaugeValue, quantityValue(spec.Limits[v1.ResourceMemory]), dept, nc.OS, nc.Arch)
+			ch <- prometheus.MustNewConstMetric(c.legacyUsed, prometheus.GaugeValue, quantityValue(announced.Limits[v1.ResourceMemory]), dept, nc.OS, nc.Arch)
+		}
+		ch <- prometheus.MustNewConstMetric(c.pods, prometheus.GaugeValue, float64(s.pods), dept)
+		ch <- prometheus.MustNewConstMetric(c.legacyPods, prometheus.GaugeValue, float64(s.pods), dept)
+	}
+}
+
+// quantityValue 返回数值，零值 Quantity 为 0
+func quantityValue(q resource.Quantity) float64 {
+	return q.AsApproximateFloat64()
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compressMinSize 小于该字节数的响应体不压缩
+const compressMinSize = 1024
+
+// compressor gzip.Writer 与 zlib.Writer 的公共方法
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var compressorPools = map[string]*sync.Pool{
+	"gzip": {New: func() interface{} { return gzip.NewWriter(io.Discard) }},
+	// HTTP 的 deflate 编码为 zlib 格式（RFC 1950）
+	"deflate": {New: func() interface{} { return zlib.NewWriter(io.Discard) }},
+}
+
+// Compress 按 Accept-Encoding 以 gzip 或 deflate 压缩响应体；HEAD 请求、204/304 与小于 compressMinSize 的响应不压缩
+func Compress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+		c.Writer = w
+		defer w.close()
+		c.Next()
+	}
+}
+
+// negotiateEncoding 返回 q 值最高的可用编码，q 相同时优先 gzip，均不可用时返回空串
+func negotiateEncoding(header string) string {
+	q := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		weight := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					weight = f
+				}
+			}
+		}
+		switch name {
+		case "gzip", "x-gzip":
+			q["gzip"] = weight
+		case "deflate":
+			q["deflate"] = weight
+		case "*":
+			wildcard = weight
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, name := range []string{"gzip", "deflate"} {
+		weight, ok := q[name]
+		if !ok {
+			weight = wildcard
+		}
+		if weight > bestQ {
+			best, bestQ = name, weight
+		}
+	}
+	return best
+}
+
+// compressWriter 在第一次写入响应体时决定是否压缩：状态码不带响应体、已设置 Content-Encoding 或首块小于 compressMinSize 时原样写出
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	decided  bool
+	w        compressor
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		status := w.Status()
+		if status != http.StatusNoContent && status != http.StatusNotModified &&
+			w.Header().Get("Content-Encoding") == "" && len(p) >= compressMinSize {
+			w.Header().Set("Content-Encoding", w.encoding)
+			w.Header().Del("Content-Length")
+			w.w = compressorPools[w.encoding].Get().(compressor)
+			w.w.Reset(w.ResponseWriter)
+		}
+	}
+	if w.w == nil {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.w.Write(p)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Flush() {
+	if w.w != nil {
+		_ = w.w.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close 写出压缩尾部并归还压缩器
+func (w *compressWriter) close() {
+	if w.w == nil {
+		return
+	}
+	_ = w.w.Close()
+	w.w.Reset(io.Discard)
+	compressorPools[w.encoding].Put(w.w)
+	w.w = nil
+}
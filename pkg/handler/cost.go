@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"k8s-admin-informer/pkg/authz"
+	"k8s-admin-informer/pkg/chargeback"
+	"k8s-admin-informer/pkg/export"
+	"k8s-admin-informer/pkg/model"
+)
+
+// newCostLedger 按环境变量创建成本账本，未配置成本模型时不做成本核算：
+// - COST_MODEL_FILE 成本模型文件（YAML/JSON），包含币种、计费依据与各节点类型单价
+// - COST_SAMPLE_INTERVAL 采样间隔，默认 1m
+// - COST_HOURLY_RETENTION 小时粒度数据保留时长，更早的按天合并，默认 168h
+// - COST_RETENTION 数据保留时长，默认 8760h
+// - COST_STATE_FILE 累计值持久化文件，为空时只保存在内存中，重启后丢失
+// - COST_SAVE_INTERVAL 持久化间隔，默认 5m
+func newCostLedger() *chargeback.Ledger {
+	path := os.Getenv("COST_MODEL_FILE")
+	if path == "" {
+		return nil
+	}
+	m, err := chargeback.LoadModel(path)
+	if err != nil {
+		log.Errorf("加载成本模型 %s 失败，不做成本核算: %v", path, err)
+		return nil
+	}
+	cfg := chargeback.Config{
+		Model:           *m,
+		Interval:        time.Minute,
+		HourlyRetention: 7 * 24 * time.Hour,
+		Retention:       365 * 24 * time.Hour,
+		StateFile:       os.Getenv("COST_STATE_FILE"),
+		SaveInterval:    5 * time.Minute,
+	}
+	for _, d := range []struct {
+		env string
+		val *time.Duration
+	}{
+		{"COST_SAMPLE_INTERVAL", &cfg.Interval},
+		{"COST_HOURLY_RETENTION", &cfg.HourlyRetention},
+		{"COST_RETENTION", &cfg.Retention},
+		{"COST_SAVE_INTERVAL", &cfg.SaveInterval},
+	} {
+		if v := os.Getenv(d.env); v != "" {
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				*d.val = parsed
+			} else {
+				log.Warnf("解析 %s 失败，使用默认值 %s，错误: %v", d.env, d.val.String(), err)
+			}
+		}
+	}
+	return chargeback.New(cfg)
+}
+
+// StartCostAccounting 启动成本采样；需在 informer 启动并完成聚合预热后调用，未配置成本模型时不做任何事
+func (h *ResourceHandler) StartCostAccounting() {
+	if h.costs == nil {
+		return
+	}
+	h.costs.Start(h.Handler.stopCh, h.costSamples)
+}
+
+// costSamples 读取已调度 Pod 的 requests/limits/用量与各节点类型的可分配容量；
+// 成本按 Pod 的 department 与 namespaceGroup 标签归属，未带 department 标签的 Pod 不计入部门，其占用体现为闲置成本
+func (h *ResourceHandler) costSamples() ([]chargeback.Sample, map[string]chargeback.Quantities) {
+	h.recomputeMu.Lock()
+	defer h.recomputeMu.Unlock()
+	samples := make([]chargeback.Sample, 0, len(h.podRecords))
+	for _, rec := range h.podRecords {
+		if rec.pending {
+			continue
+		}
+		namespace, _, _ := strings.Cut(rec.key, "/")
+		samples = append(samples, chargeback.Sample{
+			Key:      chargeback.Key{Dept: rec.dept, Env: rec.env, Namespace: namespace, Class: string(rec.arch)},
+			Requests: chargeback.QuantitiesOf(rec.requests),
+			Limits:   chargeback.QuantitiesOf(rec.limits),
+			Usage:    chargeback.QuantitiesOf(rec.usage),
+		})
+	}
+	capacity := make(map[string]chargeback.Quantities)
+	for _, rec := range h.nodeAgg {
+		q := capacity[string(rec.nodeType)]
+		allocatable := chargeback.QuantitiesOf(rec.allocatable)
+		q.CPUCores += allocatable.CPUCores
+		q.MemoryGiB += allocatable.MemoryGiB
+		capacity[string(rec.nodeType)] = q
+	}
+	return samples, capacity
+}
+
+// CostReport 返回一段时间内按部门、环境或命名空间汇总的成本，查询参数：
+// - from 起始时间（RFC3339）或回溯时长（如 720h），默认 24h
+// - to 结束时间（RFC3339），默认当前时间
+// - groupBy dept（默认）、env 或 namespace
+// - dept 只返回该部门
+// - idle none、separate（默认，单独列出闲置成本，仅管理员可见）或 split（按成本占比分摊到各项）
+// - format=json|yaml|csv|xlsx 或 Accept 请求头选择输出格式
+func (h *ResourceHandler) CostReport(c *gin.Context) {
+	if h.costs == nil {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "cost accounting is disabled, set COST_MODEL_FILE to enable it"})
+		return
+	}
+	now := time.Now()
+	q := chargeback.Query{
+		From:    now.Add(-24 * time.Hour),
+		To:      now,
+		GroupBy: c.DefaultQuery("groupBy", chargeback.GroupByDept),
+		Dept:    c.Query("dept"),
+		Idle:    c.DefaultQuery("idle", chargeback.IdleSeparate),
+	}
+	if v := c.Query("from"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			q.From = now.Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			q.From = t
+		} else {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "from must be RFC3339 time or duration"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "to must be RFC3339 time"})
+			return
+		}
+		q.To = t
+	}
+	if !q.To.After(q.From) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "to must be after from"})
+		return
+	}
+	switch q.GroupBy {
+	case chargeback.GroupByDept, chargeback.GroupByEnv, chargeback.GroupByNamespace:
+	default:
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "groupBy must be dept, env or namespace"})
+		return
+	}
+	switch q.Idle {
+	case chargeback.IdleNone, chargeback.IdleSeparate, chargeback.IdleSplit:
+	default:
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "idle must be none, separate or split"})
+		return
+	}
+	format, ok := negotiateFormat(c)
+	if !ok {
+		return
+	}
+	scope := authz.ScopeFrom(c.Request.Context())
+	if q.Dept != "" && !scope.Allows(q.Dept) {
+		authz.Forbidden(c, "department "+q.Dept+" is not visible to the caller")
+		return
+	}
+	if !scope.Admin() {
+		q.Allow = scope.Allows
+	}
+	q.IncludeIdle = scope.Admin()
+
+	report := h.costs.Report(q)
+	renderReport(c, format, "cost-"+q.GroupBy, report, func() export.Table { return costTable(report) })
+}
+
+// costTable 成本报表：每项一行，节点类型展开为 <class>.<项> 列，闲置成本单独列出时追加 dept 为 (idle) 的一行
+func costTable(report model.CostReport) export.Table {
+	classes := make(map[string]bool)
+	for _, item := range report.Items {
+		for class := range item.Classes {
+			classes[class] = true
+		}
+	}
+	for class := range report.Idle {
+		classes[class] = true
+	}
+	ordered := make([]string, 0, len(classes))
+	for _, class := range exportClasses {
+		if classes[string(class)] {
+			ordered = append(ordered, string(class))
+			delete(classes, string(class))
+		}
+	}
+	extra := make([]string, 0, len(classes))
+	for class := range classes {
+		extra = append(extra, class)
+	}
+	sort.Strings(extra)
+	ordered = append(ordered, extra...)
+
+	t := export.Table{Sheet: "cost", Columns: []string{"dept", "env", "namespace"}}
+	for _, class := range ordered {
+		t.Columns = append(t.Columns, class+".cpuCoreHours", class+".memoryGiBHours", class+".cpuCost", class+".memoryCost", class+".cost")
+	}
+	t.Columns = append(t.Columns, "cost", "idleCost", "totalCost", "currency", "from", "to")
+	row := func(dept, env, namespace string, amounts map[string]model.CostAmount, cost, idleCost, total float64) []interface{} {
+		r := []interface{}{dept, env, namespace}
+		for _, class := range ordered {
+			a := amounts[class]
+			r = append(r, a.CPUCoreHours, a.MemoryGiBHours, a.CPUCost, a.MemoryCost, a.Cost)
+		}
+		return append(r, cost, idleCost, total, report.Currency,
+			report.From.Format(time.RFC3339), report.To.Format(time.RFC3339))
+	}
+	for _, item := range report.Items {
+		t.Rows = append(t.Rows, row(item.Dept, item.Env, item.Namespace, item.Classes, item.Cost, item.IdleCost, item.TotalCost))
+	}
+	if len(report.Idle) > 0 {
+		var idle float64
+		for _, a := range report.Idle {
+			idle += a.Cost
+		}
+		t.Rows = append(t.Rows, row("(idle)", "", "", report.Idle, 0, idle, idle))
+	}
+	return t
+}
@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+
+	"k8s-admin-informer/pkg/export"
+	"k8s-admin-informer/pkg/model"
+)
+
+// exportClasses 报表中节点类型列的顺序
+var exportClasses = []NodeType{NonXcNodeType, XcArmNodeType, XcX86NodeType}
+
+// negotiateFormat 按 format 参数或 Accept 请求头协商输出格式，参数非法时返回 400，Accept 无可用类型时返回 406
+func negotiateFormat(c *gin.Context) (export.Format, bool) {
+	c.Writer.Header().Add("Vary", "Accept")
+	format, err := export.Negotiate(c.Query("format"), c.GetHeader("Accept"))
+	if err != nil {
+		status := http.StatusBadRequest
+		var notAcceptable *export.ErrNotAcceptable
+		if errors.As(err, &notAcceptable) {
+			status = http.StatusNotAcceptable
+		}
+		c.AbortWithStatusJSON(status, model.ErrorResponse{Error: err.Error()})
+		return "", false
+	}
+	return format, true
+}
+
+// formatTag 非 JSON 格式时附加到 ETag 种类上，不同格式的响应不共用 ETag
+func formatTag(kind string, format export.Format) string {
+	if format == export.FormatJSON {
+		return kind
+	}
+	return kind + "." + string(format)
+}
+
+// renderReport 按格式写出报表：JSON/YAML 输出 data 原样结构，CSV/XLSX 输出 table 扁平化的表格并作为附件 name.<format> 下载
+func renderReport(c *gin.Context, format export.Format, name string, data interface{}, table func() export.Table) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case export.FormatJSON:
+		c.JSON(http.StatusOK, data)
+		return
+	case export.FormatYAML:
+		var out []byte
+		if out, err = yaml.Marshal(data); err == nil {
+			buf.Write(out)
+		}
+	case export.FormatCSV:
+		err = export.WriteCSV(&buf, table())
+	case export.FormatXLSX:
+		err = export.WriteXLSX(&buf, table())
+	}
+	if err != nil {
+		log.WithContext(c.Request.Context()).Errorf("导出 %s 为 %s 失败: %v", name, format, err)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if format == export.FormatCSV || format == export.FormatXLSX {
+		c.Header("Content-Disposition", `attachment; filename="`+name+"."+string(format)+`"`)
+	}
+	c.Data(http.StatusOK, format.ContentType(), buf.Bytes())
+}
+
+// columnKind 报表列的类型
+type columnKind int
+
+const (
+	// textColumn 原样输出
+	textColumn columnKind = iota
+	// countColumn 整数
+	countColumn
+	// cpuColumn 同时输出原始数量与核数两列
+	cpuColumn
+	// memoryColumn 同时输出原始数量与字节数两列
+	memoryColumn
+)
+
+// exportColumn 报表列，get 返回单元格的字符串形式
+type exportColumn[T any] struct {
+	name string
+	kind columnKind
+	get  func(T) string
+}
+
+// buildTable 按列定义扁平化 items；cpu/memory 列展开为 name 与 name.cores/name.bytes 两列，无法解析的数量数值列留空
+func buildTable[T any](sheet string, cols []exportColumn[T], items []T) export.Table {
+	t := export.Table{Sheet: sheet}
+	for _, col := range cols {
+		t.Columns = append(t.Columns, col.name)
+		switch col.kind {
+		case cpuColumn:
+			t.Columns = append(t.Columns, col.name+".cores")
+		case memoryColumn:
+			t.Columns = append(t.Columns, col.name+".bytes")
+		}
+	}
+	for _, item := range items {
+		row := make([]interface{}, 0, len(t.Columns))
+		for _, col := range cols {
+			v := col.get(item)
+			switch col.kind {
+			case textColumn:
+				row = append(row, v)
+			case countColumn:
+				if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+					row = append(row, n)
+				} else {
+					row = append(row, nil)
+				}
+			case cpuColumn, memoryColumn:
+				row = append(row, v)
+				q, err := resource.ParseQuantity(v)
+				switch {
+				case v == "" || err != nil:
+					row = append(row, nil)
+				case col.kind == cpuColumn:
+					row = append(row, float64(q.MilliValue())/1000)
+				default:
+					row = append(row, q.Value())
+				}
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+// deptClassValues 部门在某类节点上的配额、已宣布、用量、requests/limits 与待调度需求
+type deptClassValues struct {
+	quota, announced            string
+	usedCPU, usedMemory         string
+	requestsCPU, requestsMemory string
+	limitsCPU, limitsMemory     string
+	pendingPods, pendingMemory  string
+}
+
+func deptClass(d model.DeptResource, class NodeType) deptClassValues {
+	var quota, announced model.ResourceQuotas
+	var usedCPU, usedMemory string
+	var requests, limits map[string]string
+	var pending model.PendingDemand
+	switch class {
+	case XcArmNodeType:
+		quota, announced = d.Resources.XC.Arm, d.Announced.XC.Arm
+		usedCPU, usedMemory = d.Used.XC.Arm.Cpu, d.Used.XC.Arm.Memory
+		requests, limits, pending = d.Requests.XC.Arm, d.Limits.XC.Arm, d.Pending.XC.Arm
+	case XcX86NodeType:
+		quota, announced = d.Resources.XC.X86, d.Announced.XC.X86
+		usedCPU, usedMemory = d.Used.XC.X86.Cpu, d.Used.XC.X86.Memory
+		requests, limits, pending = d.Requests.XC.X86, d.Limits.XC.X86, d.Pending.XC.X86
+	default:
+		quota, announced = d.Resources.NonXc, d.Announced.NonXc
+		usedCPU, usedMemory = d.Used.NonXc.Cpu, d.Used.NonXc.Memory
+		requests, limits, pending = d.Requests.NonXc, d.Limits.NonXc, d.Pending.NonXc
+	}
+	return deptClassValues{
+		quota:          quota.Limits.Memory,
+		announced:      announced.Limits.Memory,
+		usedCPU:        usedCPU,
+		usedMemory:     usedMemory,
+		requestsCPU:    requests["cpu"],
+		requestsMemory: requests["memory"],
+		limitsCPU:      limits["cpu"],
+		limitsMemory:   limits["memory"],
+		pendingPods:    strconv.Itoa(pending.Pods),
+		pendingMemory:  pending.Memory,
+	}
+}
+
+// deptTable 部门资源报表：每个部门一行，节点类型展开为 <class>.<项>.<资源> 列
+func deptTable(data []model.DeptResource) export.Table {
+	cols := []exportColumn[model.DeptResource]{
+		{name: "name", get: func(d model.DeptResource) string { return d.Name }},
+		{name: "pods", kind: countColumn, get: func(d model.DeptResource) string { return strconv.Itoa(d.Pods) }},
+	}
+	for _, class := range exportClasses {
+		class := class
+		field := func(name string, kind columnKind, get func(deptClassValues) string) exportColumn[model.DeptResource] {
+			return exportColumn[model.DeptResource]{
+				name: string(class) + "." + name,
+				kind: kind,
+				get:  func(d model.DeptResource) string { return get(deptClass(d, class)) },
+			}
+		}
+		cols = append(cols,
+			field("quota.memory", memoryColumn, func(v deptClassValues) string { return v.quota }),
+			field("announced.memory", memoryColumn, func(v deptClassValues) string { return v.announced }),
+			field("used.cpu", cpuColumn, func(v deptClassValues) string { return v.usedCPU }),
+			field("used.memory", memoryColumn, func(v deptClassValues) string { return v.usedMemory }),
+			field("requests.cpu", cpuColumn, func(v deptClassValues) string { return v.requestsCPU }),
+			field("requests.memory", memoryColumn, func(v deptClassValues) string { return v.requestsMemory }),
+			field("limits.cpu", cpuColumn, func(v deptClassValues) string { return v.limitsCPU }),
+			field("limits.memory", memoryColumn, func(v deptClassValues) string { return v.limitsMemory }),
+			field("pending.pods", countColumn, func(v deptClassValues) string { return v.pendingPods }),
+			field("pending.memory", memoryColumn, func(v deptClassValues) string { return v.pendingMemory }),
+		)
+	}
+	return buildTable("dept resources", cols, data)
+}
+
+// nodeTable 节点资源报表：每个节点一行
+func nodeTable(data model.NodeList) export.Table {
+	cols := []exportColumn[model.Node]{
+		{name: "name", get: func(n model.Node) string { return n.Name }},
+		{name: "type", get: func(n model.Node) string { return n.Type }},
+	}
+	for _, group := range []struct {
+		name string
+		get  func(model.Node) map[string]string
+	}{
+		{"allocatable", func(n model.Node) map[string]string { return n.Allocatable }},
+		{"used", func(n model.Node) map[string]string { return n.Used }},
+		{"requests", func(n model.Node) map[string]string { return n.Requests }},
+		{"limits", func(n model.Node) map[string]string { return n.Limits }},
+	} {
+		get := group.get
+		cols = append(cols,
+			exportColumn[model.Node]{name: group.name + ".cpu", kind: cpuColumn, get: func(n model.Node) string { return get(n)["cpu"] }},
+			exportColumn[model.Node]{name: group.name + ".memory", kind: memoryColumn, get: func(n model.Node) string { return get(n)["memory"] }},
+		)
+	}
+	return buildTable("node resources", cols, data.Items)
+}
+
+// envTable 部门各环境资源报表：每个环境一行，按环境名排序
+func envTable(data map[string]model.EnvResource) export.Table {
+	envs := make([]model.EnvResource, 0, len(data))
+	for _, env := range data {
+		envs = append(envs, env)
+	}
+	sort.Slice(envs, func(i, j int) bool { return envs[i].EnvName < envs[j].EnvName })
+
+	cols := []exportColumn[model.EnvResource]{
+		{name: "dept", get: func(e model.EnvResource) string { return e.Dept }},
+		{name: "env", get: func(e model.EnvResource) string { return e.EnvName }},
+	}
+	for _, class := range exportClasses {
+		class := class
+		common := func(e model.EnvResource) model.CommonResource {
+			switch class {
+			case XcArmNodeType:
+				return e.XcResource.Arm
+			case XcX86NodeType:
+				return e.XcResource.X86
+			}
+			return e.NonXcResource.CommonResource
+		}
+		for _, group := range []struct {
+			name string
+			get  func(model.CommonResource) model.ComputationResources
+		}{
+			{"used", func(r model.CommonResource) model.ComputationResources { return r.Used }},
+			{"requests", func(r model.CommonResource) model.ComputationResources { return r.Requests }},
+			{"limits", func(r model.CommonResource) model.ComputationResources { return r.Limits }},
+		} {
+			get := group.get
+			prefix := string(class) + "." + group.name
+			cols = append(cols,
+				exportColumn[model.EnvResource]{name: prefix + ".cpu", kind: cpuColumn, get: func(e model.EnvResource) string {
+					return quantityString(get(common(e)).Cpu)
+				}},
+				exportColumn[model.EnvResource]{name: prefix + ".memory", kind: memoryColumn, get: func(e model.EnvResource) string {
+					return quantityString(get(common(e)).Memory)
+				}},
+			)
+		}
+	}
+	return buildTable("env resources", cols, envs)
+}
+
+func quantityString(q *resource.Quantity) string {
+	if q == nil {
+		return ""
+	}
+	return q.String()
+}
@@ -17,6 +17,7 @@ import (
 
 	k8s "k8s-admin-informer/pkg/kubernetes"
 	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/logging"
 )
 
 const (
@@ -94,6 +95,7 @@ func (h *Handler) Start() error {
 	h.Informers[EventInformer] = informer.NewEventInformer(h.client)
 	h.Informers[NodeInformer] = informer.NewNodeInformer(h.client)
 	h.Informers[DeptResourceQuotaInformer] = informer.NewDeptResourceQuotaInformer(h.dynamicClient)
+	h.instrumentInformers()
 
 	// 启动informer
 	stopCh := make(chan struct{})
@@ -101,15 +103,15 @@ func (h *Handler) Start() error {
 
 	for name, inf := range h.Informers {
 		go func(name string, inf informer.Informer) {
-			log.Infof("启动informer: %s", name)
+			logging.Informer(name).Infof("启动informer: %s", name)
 			inf.Start(stopCh)
-			log.Infof("informer：%s 已停止", name)
+			logging.Informer(name).Infof("informer：%s 已停止", name)
 		}(name, inf)
 	}
 
 	synced := make([]cache.InformerSynced, 0, len(h.Informers))
 	for name, inf := range h.Informers {
-		log.Infof("等待:%s同步", name)
+		logging.Informer(name).Infof("等待:%s同步", name)
 		synced = append(synced, inf.HasSynced)
 	}
 	if !cache.WaitForCacheSync(stopCh, synced...) {
@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	appsV1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"k8s-admin-informer/pkg/audit"
+	"k8s-admin-informer/pkg/authz"
+	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/model"
+	"k8s-admin-informer/pkg/reservation"
+)
+
+// workloadSpec 从 Deployment/StatefulSet 中抽取配额计算所需的信息
+type workloadSpec struct {
+	kind      string
+	namespace string
+	name      string
+	replicas  int32
+	labels    map[string]string
+	template  *v1.PodTemplateSpec
+}
+
+func workloadOfDeployment(d *appsV1.Deployment) *workloadSpec {
+	return &workloadSpec{
+		kind:      "deployment",
+		namespace: d.Namespace,
+		name:      d.Name,
+		replicas:  replicasOrDefault(d.Spec.Replicas),
+		labels:    d.Labels,
+		template:  &d.Spec.Template,
+	}
+}
+
+func workloadOfStatefulSet(s *appsV1.StatefulSet) *workloadSpec {
+	return &workloadSpec{
+		kind:      "statefulset",
+		namespace: s.Namespace,
+		name:      s.Name,
+		replicas:  replicasOrDefault(s.Spec.Replicas),
+		labels:    s.Labels,
+		template:  &s.Spec.Template,
+	}
+}
+
+// replicasOrDefault 与 apiserver 默认值一致，未设置副本数时为 1
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// dept 依次取 Pod 模板与工作负载上的 department 标签
+func (w *workloadSpec) dept() string {
+	if d := w.template.Labels["department"]; d != "" {
+		return d
+	}
+	return w.labels["department"]
+}
+
+// owner 工作负载对应的预留归属：checkLimit/manifest 缺省以工作负载名作为 release，也可能使用 Pod 模板上的 release 标签
+func (w *workloadSpec) owner() *reservation.Owner {
+	return &reservation.Owner{Namespace: w.namespace, Releases: []string{w.name, w.template.Labels["release"]}}
+}
+
+// decodeWorkloadManifest 解析 JSON 或 YAML 格式的 Deployment/StatefulSet，
+// 清单也可以是包含 JSON/YAML 文本的 JSON 字符串
+func decodeWorkloadManifest(raw []byte) (*workloadSpec, error) {
+	data := bytes.TrimSpace(raw)
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return nil, fmt.Errorf("manifest: %v", err)
+		}
+		data = []byte(text)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("manifest is empty")
+	}
+	obj, gvk, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %v", err)
+	}
+	switch o := obj.(type) {
+	case *appsV1.Deployment:
+		return workloadOfDeployment(o), nil
+	case *appsV1.StatefulSet:
+		return workloadOfStatefulSet(o), nil
+	default:
+		return nil, fmt.Errorf("manifest: unsupported kind %q, expected apps/v1 Deployment or StatefulSet", gvk.Kind)
+	}
+}
+
+// cachedWorkload 从 informer 缓存中查找工作负载，不存在时返回 nil
+func (h *ResourceHandler) cachedWorkload(ctx context.Context, kind, ns, name string) *workloadSpec {
+	if ns == "" || name == "" {
+		return nil
+	}
+	switch strings.ToLower(kind) {
+	case "deployment":
+		if list := h.Handler.Informers[DeploymentInformer].(*informer.DeploymentInformer).GetDeployments(ctx, ns, name); len(list) > 0 {
+			return workloadOfDeployment(list[0])
+		}
+	case "statefulset":
+		if list := h.Handler.Informers[StatefulSetInformer].(*informer.StatefulSetInformer).GetStatefulSets(ctx, ns, name); len(list) > 0 {
+			return workloadOfStatefulSet(list[0])
+		}
+	}
+	return nil
+}
+
+// scaleResourceList 返回 list 的 n 倍
+func scaleResourceList(list v1.ResourceList, n int64) v1.ResourceList {
+	out := v1.ResourceList{}
+	for name, q := range list {
+		if name == v1.ResourceCPU {
+			out[name] = *resource.NewMilliQuantity(q.MilliValue()*n, q.Format)
+		} else {
+			out[name] = *resource.NewQuantity(q.Value()*n, q.Format)
+		}
+	}
+	return out
+}
+
+// workloadTotal 工作负载全部副本的 limits 之和，pods 为副本数
+func workloadTotal(w *workloadSpec, replicas int32) v1.ResourceList {
+	if replicas < 0 {
+		replicas = 0
+	}
+	total := scaleResourceList(podSpecLimits(&w.template.Spec), int64(replicas))
+	total[v1.ResourcePods] = *resource.NewQuantity(int64(replicas), resource.DecimalSI)
+	return total
+}
+
+// positiveDelta 逐项计算 after - before，小于 0 的项记为 0
+func positiveDelta(after, before v1.ResourceList) v1.ResourceList {
+	out := v1.ResourceList{}
+	for name, q := range after {
+		d := q.DeepCopy()
+		if b, ok := before[name]; ok {
+			d.Sub(b)
+		}
+		if d.Sign() < 0 {
+			d.Set(0)
+		}
+		out[name] = d
+	}
+	return out
+}
+
+// CheckDeptLimitByManifest 根据 Deployment/StatefulSet 清单或已有工作负载的副本增量推算申请量，
+// 节点类型由 nodeSelector / nodeAffinity 推断，随后与 checkLimit 走同样的配额校验。
+// 请求体可以是 ManifestQuotaRequest JSON，也可以是 Content-Type 为 YAML 的清单本身（此时部门通过 dept 查询参数指定）；
+// 启用部门授权时部门对调用方不可见返回 403
+func (h *ResourceHandler) CheckDeptLimitByManifest(c *gin.Context) {
+	var req model.ManifestQuotaRequest
+	if strings.Contains(c.ContentType(), "yaml") {
+		body, err := c.GetRawData()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+			return
+		}
+		req.Manifest = body
+		req.Dept = c.Query("dept")
+	} else if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	ttl, err := parseReserveOptions(req.Reserve)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var (
+		target    *workloadSpec
+		existing  *workloadSpec
+		requested v1.ResourceList
+		replicas  int32
+	)
+	switch {
+	case len(req.Manifest) > 0 && req.Workload != nil:
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "manifest and workload are mutually exclusive"})
+		return
+	case len(req.Manifest) > 0:
+		w, err := decodeWorkloadManifest(req.Manifest)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
+			return
+		}
+		target = w
+		replicas = w.replicas
+		existing = h.cachedWorkload(c.Request.Context(), w.kind, w.namespace, w.name)
+		requested = workloadTotal(w, w.replicas)
+		if existing != nil {
+			requested = positiveDelta(requested, workloadTotal(existing, existing.replicas))
+		}
+	case req.Workload != nil:
+		if req.ReplicaDelta == nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "replicaDelta is required with workload"})
+			return
+		}
+		existing = h.cachedWorkload(c.Request.Context(), req.Workload.Kind, req.Workload.Namespace, req.Workload.Name)
+		if existing == nil {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{Error: fmt.Sprintf("%s %s/%s not found",
+				req.Workload.Kind, req.Workload.Namespace, req.Workload.Name)})
+			return
+		}
+		target = existing
+		replicas = existing.replicas + *req.ReplicaDelta
+		requested = workloadTotal(existing, *req.ReplicaDelta)
+	default:
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "either manifest or workload with replicaDelta is required"})
+		return
+	}
+
+	dept := req.Dept
+	if dept == "" {
+		dept = target.dept()
+	}
+	if dept == "" {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "dept is required: set dept or the department label on the pod template"})
+		return
+	}
+	if !authz.ScopeFrom(c.Request.Context()).Allows(dept) {
+		authz.Forbidden(c, "department "+dept+" is not visible to the caller")
+		return
+	}
+	quota := h.Handler.Informers[DeptResourceQuotaInformer].(*informer.DeptResourceQuotaInformer).GetDeptResourceQuotaByName(dept)
+	if quota == nil {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "DeptResourceQuota not found"})
+		return
+	}
+
+	class, source := classOfPodSpec(&target.template.Spec)
+	reserve := req.Reserve
+	if reserve != nil {
+		// 自动释放条件缺省为该工作负载新增的 Pod
+		opts := *reserve
+		if opts.Namespace == "" && opts.Release == "" && opts.Pods == 0 {
+			opts.Namespace = target.namespace
+			opts.Release = target.name
+			pods := requested[v1.ResourcePods]
+			opts.Pods = int32(pods.Value())
+		}
+		reserve = &opts
+	}
+	resp := model.ManifestQuotaResponse{
+		DeptResourceQuotaResponse: h.checkAndReserve(quota, dept, classRequests{class: requested}, reserve, ttl, nil),
+		Dept:                      dept,
+		Workload: model.ManifestWorkload{
+			Kind:        target.kind,
+			Namespace:   target.namespace,
+			Name:        target.name,
+			Replicas:    replicas,
+			Existing:    existing != nil,
+			Class:       string(class),
+			ClassSource: source,
+			PodLimits:   model.NewResourceAmounts(podSpecLimits(&target.template.Spec)),
+			Requested:   model.NewResourceAmounts(requested),
+		},
+	}
+	if existing != nil {
+		resp.Workload.ExistingReplicas = existing.replicas
+	}
+	status := http.StatusOK
+	if !resp.Success {
+		status = http.StatusBadRequest
+	}
+	object := fmt.Sprintf("%s %s/%s", target.kind, target.namespace, target.name)
+	h.audit.Record(c, status, decisionRecord(audit.ActionCheckManifest, dept, object, resp.Workload, resp.DeptResourceQuotaResponse))
+	c.JSON(status, resp)
+}
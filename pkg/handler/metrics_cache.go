@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
+
+	"k8s-admin-informer/pkg/tracing"
+)
+
+// metricsCache 缓存 UsageProvider 提供的 Pod/Node 用量，由批量抓取统一写入，事件处理与接口只读。
+// 每次抓取生成新的 map 整体替换，读取方拿到的 map 之后不会再被修改；
+// 来源不可用时保留最近一次成功抓取的值，并通过 status 报告数据时间与是否过期
+type metricsCache struct {
+	provider UsageProvider
+	limiter  *rate.Limiter
+	// timeout 单次抓取超时
+	timeout time.Duration
+	// staleAfter 数据超过该时长未更新视为过期
+	staleAfter time.Duration
+
+	mu      sync.RWMutex
+	pods    map[string]v1.ResourceList
+	nodes   map[string]v1.ResourceList
+	podsAt  time.Time
+	nodesAt time.Time
+	lastErr error
+}
+
+// usageStatus 用量数据的来源与新鲜度
+type usageStatus struct {
+	source    string
+	updatedAt time.Time
+	stale     bool
+	err       error
+}
+
+// newMetricsCache 创建指标缓存，两次抓取至少间隔 minInterval
+func newMetricsCache(provider UsageProvider, minInterval, staleAfter time.Duration) *metricsCache {
+	return &metricsCache{
+		provider:   provider,
+		limiter:    rate.NewLimiter(rate.Every(minInterval), 1),
+		timeout:    30 * time.Second,
+		staleAfter: staleAfter,
+		pods:       map[string]v1.ResourceList{},
+		nodes:      map[string]v1.ResourceList{},
+	}
+}
+
+// scrape 批量拉取全部 Pod 与节点用量，距上次抓取不足最小间隔时直接返回 false；
+// 某一类拉取失败时保留该类的旧值
+func (m *metricsCache) scrape() bool {
+	if m.provider == nil || !m.limiter.Allow() {
+		return false
+	}
+	source := m.provider.Name()
+	ctx, span := tracing.Start(context.Background(), "usage.scrape", attribute.String("usage.source", source))
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	start := time.Now()
+	pods, podErr := m.provider.PodUsage(ctx)
+	usageSourceDuration.WithLabelValues(source, "pods").Observe(time.Since(start).Seconds())
+	if podErr != nil {
+		usageSourceErrors.WithLabelValues(source, "pods").Inc()
+		log.Errorf("从 %s 批量获取pod资源信息失败，沿用上次结果: %v", source, podErr)
+	}
+	start = time.Now()
+	nodes, nodeErr := m.provider.NodeUsage(ctx)
+	usageSourceDuration.WithLabelValues(source, "nodes").Observe(time.Since(start).Seconds())
+	if nodeErr != nil {
+		usageSourceErrors.WithLabelValues(source, "nodes").Inc()
+		log.Errorf("从 %s 批量获取node资源信息失败，沿用上次结果: %v", source, nodeErr)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if podErr == nil {
+		m.pods = pods
+		m.podsAt = now
+	}
+	if nodeErr == nil {
+		m.nodes = nodes
+		m.nodesAt = now
+	}
+	m.lastErr = podErr
+	if m.lastErr == nil {
+		m.lastErr = nodeErr
+	}
+	return podErr == nil || nodeErr == nil
+}
+
+// status 返回用量数据的来源与新鲜度，updatedAt 取 Pod 与节点两类中较早的一次成功抓取
+func (m *metricsCache) status() usageStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st := usageStatus{source: "none", err: m.lastErr}
+	if m.provider == nil {
+		st.stale = true
+		return st
+	}
+	st.source = m.provider.Name()
+	st.updatedAt = m.podsAt
+	if m.nodesAt.Before(st.updatedAt) {
+		st.updatedAt = m.nodesAt
+	}
+	st.stale = st.updatedAt.IsZero() || time.Since(st.updatedAt) > m.staleAfter
+	return st
+}
+
+// setUsageHeaders 在响应头中报告本次响应所用用量的来源、时间、是否过期与最近一次抓取的错误
+func (m *metricsCache) setUsageHeaders(c *gin.Context) {
+	st := m.status()
+	c.Header("X-Usage-Source", st.source)
+	if !st.updatedAt.IsZero() {
+		c.Header("X-Usage-Updated-At", st.updatedAt.Format(time.RFC3339))
+	}
+	if st.err != nil {
+		c.Header("X-Usage-Error", st.err.Error())
+	}
+	if st.stale {
+		c.Header("X-Usage-Stale", "true")
+	} else {
+		c.Header("X-Usage-Stale", "false")
+	}
+}
+
+// podUsage 返回 namespace/name 对应 Pod 的 cpu/memory 用量
+func (m *metricsCache) podUsage(key string) (v1.ResourceList, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.pods[key]
+	return u, ok
+}
+
+// nodeUsage 返回节点用量
+func (m *metricsCache) nodeUsage(name string) (v1.ResourceList, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.nodes[name]
+	return u, ok
+}
+
+// podUsages 返回最近一次成功抓取的全部 Pod 用量，调用方不得修改
+func (m *metricsCache) podUsages() map[string]v1.ResourceList {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.pods
+}
+
+// nodeUsages 返回最近一次成功抓取的全部节点用量，调用方不得修改
+func (m *metricsCache) nodeUsages() map[string]v1.ResourceList {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.nodes
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"k8s-admin-informer/api/v1alpha1"
+)
+
+// 节点分类标签，Pod 通过 nodeSelector / nodeAffinity 约束这些标签来选择节点类型
+const (
+	NodeOSLabel   = "nodetype.cks.io/os"
+	NodeArchLabel = "nodetype.cks.io/arch"
+)
+
+// nodeClass 描述一类节点：节点名前缀、操作系统与架构、节点标签，以及它在 DeptResourceQuota 中对应的配额与已宣布用量字段
+type nodeClass struct {
+	Type   NodeType
+	Prefix NodePrefix
+	OS     string
+	Arch   string
+	// Labels 该类节点上的分类标签
+	Labels map[string]string
+	// Section 配额中对应的字段名，用于提示信息
+	Section string
+	// quota 取出 spec 中该类节点的配额
+	quota func(spec *v1alpha1.WkResources) v1alpha1.ComputationResources
+	// announced 取出 status 中该类节点已宣布的用量，返回指针以便状态控制器回写
+	announced func(status *v1alpha1.UsedResources) *v1alpha1.UsedComputationResource
+}
+
+// nodeClasses 按固定顺序列出全部节点类型，接口输出与遍历均以此为准
+var nodeClasses = []nodeClass{
+	{
+		Type:    NonXcNodeType,
+		Prefix:  RedHatX86NodePrefix,
+		OS:      "rhel",
+		Arch:    "amd64",
+		Labels:  map[string]string{NodeOSLabel: "rhel", NodeArchLabel: "amd64", v1.LabelArchStable: "amd64"},
+		Section: "nonXc",
+		quota: func(spec *v1alpha1.WkResources) v1alpha1.ComputationResources {
+			return spec.NonXcResources
+		},
+		announced: func(status *v1alpha1.UsedResources) *v1alpha1.UsedComputationResource {
+			return &status.UsedNonXcResource
+		},
+	},
+	{
+		Type:    XcX86NodeType,
+		Prefix:  KylinX86NodePrefix,
+		OS:      "kylin",
+		Arch:    "amd64",
+		Labels:  map[string]string{NodeOSLabel: "kylin", NodeArchLabel: "amd64", v1.LabelArchStable: "amd64"},
+		Section: "xc.hg",
+		quota: func(spec *v1alpha1.WkResources) v1alpha1.ComputationResources {
+			return spec.XcResources.HgResource
+		},
+		announced: func(status *v1alpha1.UsedResources) *v1alpha1.UsedComputationResource {
+			return &status.UsedXcResource.HgResource
+		},
+	},
+	{
+		Type:    XcArmNodeType,
+		Prefix:  KylinArmNodePrefix,
+		OS:      "kylin",
+		Arch:    "arm64v8",
+		Labels:  map[string]string{NodeOSLabel: "kylin", NodeArchLabel: "arm64", v1.LabelArchStable: "arm64"},
+		Section: "xc.arm",
+		quota: func(spec *v1alpha1.WkResources) v1alpha1.ComputationResources {
+			return spec.XcResources.ArmResource
+		},
+		announced: func(status *v1alpha1.UsedResources) *v1alpha1.UsedComputationResource {
+			return &status.UsedXcResource.ArmResource
+		},
+	},
+}
+
+// classOf 返回节点类型对应的描述
+func classOf(t NodeType) *nodeClass {
+	for i := range nodeClasses {
+		if nodeClasses[i].Type == t {
+			return &nodeClasses[i]
+		}
+	}
+	return nil
+}
+
+// 节点类型的推断来源
+const (
+	ClassFromNodeName     = "nodeName"
+	ClassFromNodeSelector = "nodeSelector"
+	ClassFromAffinity     = "nodeAffinity"
+	ClassFromDefault      = "default"
+)
+
+// classOfPodSpec 根据 nodeName、nodeSelector 与必需的 nodeAffinity 推断 Pod 将落在哪类节点上，
+// 约束无法唯一确定节点类型时回退为非信创节点，第二个返回值说明推断来源
+func classOfPodSpec(spec *v1.PodSpec) (NodeType, string) {
+	if spec.NodeName != "" {
+		return archOfNodeName(spec.NodeName), ClassFromNodeName
+	}
+
+	terms := requiredNodeTerms(spec)
+	var candidates []NodeType
+	for _, nc := range nodeClasses {
+		if selectorMatches(nc.Labels, spec.NodeSelector) && (len(terms) == 0 || termsMatch(nc.Labels, terms)) {
+			candidates = append(candidates, nc.Type)
+		}
+	}
+	if len(candidates) != 1 {
+		return NonXcNodeType, ClassFromDefault
+	}
+	if len(terms) > 0 {
+		return candidates[0], ClassFromAffinity
+	}
+	return candidates[0], ClassFromNodeSelector
+}
+
+func requiredNodeTerms(spec *v1.PodSpec) []v1.NodeSelectorTerm {
+	if spec.Affinity == nil || spec.Affinity.NodeAffinity == nil ||
+		spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return nil
+	}
+	return spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+}
+
+// selectorMatches 只比较节点类型标签涉及的键，其他键（如业务自定义标签）不影响判断
+func selectorMatches(classLabels, selector map[string]string) bool {
+	for k, v := range selector {
+		if cv, ok := classLabels[k]; ok && cv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// termsMatch 多个 term 之间为或，term 内的表达式为与
+func termsMatch(classLabels map[string]string, terms []v1.NodeSelectorTerm) bool {
+	for _, term := range terms {
+		ok := true
+		for _, expr := range term.MatchExpressions {
+			cv, known := classLabels[expr.Key]
+			if !known {
+				continue
+			}
+			switch expr.Operator {
+			case v1.NodeSelectorOpIn:
+				ok = ok && containsString(expr.Values, cv)
+			case v1.NodeSelectorOpNotIn:
+				ok = ok && !containsString(expr.Values, cv)
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// addResourceList 将 src 累加到 dst，dst 为 nil 时新建
+func addResourceList(dst, src v1.ResourceList) v1.ResourceList {
+	if dst == nil {
+		dst = v1.ResourceList{}
+	}
+	for name, q := range src {
+		cur := dst[name]
+		cur.Add(q)
+		dst[name] = cur
+	}
+	return dst
+}
+
+// subResourceList 从 dst 中扣减 src，结果不小于 0
+func subResourceList(dst, src v1.ResourceList) {
+	for name, q := range src {
+		cur, ok := dst[name]
+		if !ok {
+			continue
+		}
+		if cur.Cmp(q) >= 0 {
+			cur.Sub(q)
+		} else {
+			cur.Set(0)
+		}
+		dst[name] = cur
+	}
+}
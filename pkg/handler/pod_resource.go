@@ -0,0 +1,175 @@
+package handler
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/model"
+)
+
+// podRequestsAndLimits 返回 Pod 的有效 requests/limits，见 podSpecRequestsAndLimits
+func podRequestsAndLimits(pod *v1.Pod) (requests, limits v1.ResourceList) {
+	return podSpecRequestsAndLimits(&pod.Spec)
+}
+
+// podSpecRequestsAndLimits 按调度器的算法计算 Pod 的有效 requests/limits：
+// 应用容器之和与任一 init 容器取大，再加上 overhead；limits 只对已设置的资源加 overhead
+func podSpecRequestsAndLimits(spec *v1.PodSpec) (requests, limits v1.ResourceList) {
+	requests, limits = v1.ResourceList{}, v1.ResourceList{}
+	for _, c := range spec.Containers {
+		requests = addResourceList(requests, c.Resources.Requests)
+		limits = addResourceList(limits, c.Resources.Limits)
+	}
+	for _, c := range spec.InitContainers {
+		maxResourceList(requests, c.Resources.Requests)
+		maxResourceList(limits, c.Resources.Limits)
+	}
+	requests = addResourceList(requests, spec.Overhead)
+	for name, q := range spec.Overhead {
+		if cur, ok := limits[name]; ok {
+			cur.Add(q)
+			limits[name] = cur
+		}
+	}
+	return requests, limits
+}
+
+// podSpecLimits 单个 Pod 的有效 limits
+func podSpecLimits(spec *v1.PodSpec) v1.ResourceList {
+	_, limits := podSpecRequestsAndLimits(spec)
+	return limits
+}
+
+// maxResourceList 逐项把 dst 提升到不小于 src
+func maxResourceList(dst, src v1.ResourceList) {
+	for name, q := range src {
+		if cur, ok := dst[name]; !ok || q.Cmp(cur) > 0 {
+			dst[name] = q.DeepCopy()
+		}
+	}
+}
+
+// podTerminated Pod 已结束（Succeeded/Failed），不再占用资源
+func podTerminated(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
+
+// podPending Pod 尚未调度到节点，作为待调度需求按 nodeSelector/亲和性推断的节点类型单独统计
+func podPending(pod *v1.Pod) bool {
+	return pod.Spec.NodeName == ""
+}
+
+// podTotals 一组 Pod 的 requests/limits 之和与 Pod 数
+type podTotals struct {
+	requests v1.ResourceList
+	limits   v1.ResourceList
+	pods     int64
+}
+
+func (t *podTotals) add(pod *v1.Pod) {
+	req, lim := podRequestsAndLimits(pod)
+	t.requests = addResourceList(t.requests, req)
+	t.limits = addResourceList(t.limits, lim)
+	t.pods++
+}
+
+// requested 返回 requests 之和，并以 pods 键给出 Pod 数
+func (t *podTotals) requested() model.ResourceAmounts {
+	list := withComputeDefaults(t.requests)
+	list[v1.ResourcePods] = *resource.NewQuantity(t.pods, resource.DecimalSI)
+	return model.NewResourceAmounts(list)
+}
+
+func (t *podTotals) limited() model.ResourceAmounts {
+	return model.NewResourceAmounts(withComputeDefaults(t.limits))
+}
+
+// withComputeDefaults 复制 list 并保证 cpu/memory 两项存在
+func withComputeDefaults(list v1.ResourceList) v1.ResourceList {
+	out := zeroUsage()
+	for name, q := range list {
+		out[name] = q.DeepCopy()
+	}
+	return out
+}
+
+// classTotals 以节点类型为键的 podTotals
+type classTotals map[NodeType]*podTotals
+
+func (c classTotals) get(t NodeType) *podTotals {
+	if c[t] == nil {
+		c[t] = &podTotals{}
+	}
+	return c[t]
+}
+
+// computeStrings 返回 cpu/memory 两项的字符串
+func computeStrings(list v1.ResourceList) map[string]string {
+	l := withComputeDefaults(list)
+	return map[string]string{
+		"cpu":    l.Cpu().String(),
+		"memory": l.Memory().String(),
+	}
+}
+
+// classComputeResources 按节点类型给出 cpu/memory
+func classComputeResources(lists map[NodeType]v1.ResourceList) model.ClassComputeResources {
+	var out model.ClassComputeResources
+	out.NonXc = computeStrings(lists[NonXcNodeType])
+	out.XC.Arm = computeStrings(lists[XcArmNodeType])
+	out.XC.X86 = computeStrings(lists[XcX86NodeType])
+	return out
+}
+
+// computationResources 返回 cpu/memory 两项
+func computationResources(list v1.ResourceList) model.ComputationResources {
+	l := withComputeDefaults(list)
+	cpu, memory := l[v1.ResourceCPU], l[v1.ResourceMemory]
+	return model.ComputationResources{Cpu: &cpu, Memory: &memory}
+}
+
+// nodePodTotals 按节点汇总已调度且未结束的 Pod 的有效 requests/limits
+func (h *ResourceHandler) nodePodTotals() map[string]*podTotals {
+	byNode := make(map[string]*podTotals)
+	for _, pod := range h.Handler.Informers[PodInformer].(*informer.PodInformer).List() {
+		if podPending(pod) || podTerminated(pod) {
+			continue
+		}
+		if byNode[pod.Spec.NodeName] == nil {
+			byNode[pod.Spec.NodeName] = &podTotals{}
+		}
+		byNode[pod.Spec.NodeName].add(pod)
+	}
+	return byNode
+}
+
+// classPodTotals 按节点类型汇总已调度且未结束的 Pod 的有效 requests/limits
+func (h *ResourceHandler) classPodTotals() classTotals {
+	totals := make(classTotals)
+	for _, pod := range h.Handler.Informers[PodInformer].(*informer.PodInformer).List() {
+		if podPending(pod) || podTerminated(pod) {
+			continue
+		}
+		totals.get(h.archOf(pod.Spec.NodeName)).add(pod)
+	}
+	return totals
+}
+
+// requestsByClass 返回各节点类型的 requests 之和
+func (c classTotals) requestsByClass() map[NodeType]v1.ResourceList {
+	out := make(map[NodeType]v1.ResourceList, len(c))
+	for t, totals := range c {
+		out[t] = totals.requests
+	}
+	return out
+}
+
+// limitsByClass 返回各节点类型的 limits 之和
+func (c classTotals) limitsByClass() map[NodeType]v1.ResourceList {
+	out := make(map[NodeType]v1.ResourceList, len(c))
+	for t, totals := range c {
+		out[t] = totals.limits
+	}
+	return out
+}
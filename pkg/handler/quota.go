@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"k8s-admin-informer/api/v1alpha1"
+	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/model"
+)
+
+// quotaResources 配额校验覆盖的资源维度
+var quotaResources = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory, v1.ResourcePods}
+
+// quotaRule 单个资源维度的配额口径
+type quotaRule struct {
+	// unsetUnlimited 为 true 时配额未设置视为不限制，否则视为配额 0
+	unsetUnlimited bool
+	// allowFull 为 true 时 已用 + 已预留 + 申请 恰好等于配额仍通过，否则达到配额即拒绝
+	allowFull bool
+}
+
+// quotaRules 各资源维度的校验口径：memory 沿用最初的内存校验（未设置即配额 0，达到配额即拒绝）；
+// cpu 与 pods 为后加的维度，未设置时不限制，恰好用满配额仍通过（与 Kubernetes ResourceQuota 一致）
+var quotaRules = map[v1.ResourceName]quotaRule{
+	v1.ResourceMemory: {},
+	v1.ResourceCPU:    {unsetUnlimited: true, allowFull: true},
+	v1.ResourcePods:   {unsetUnlimited: true, allowFull: true},
+}
+
+// 已用量来源
+const (
+	usedFromStatus = "status"
+	usedFromPods   = "pods"
+)
+
+// classRequests 以节点类型为键的申请量
+type classRequests map[NodeType]v1.ResourceList
+
+// parseQuotaRequest 校验并解析配额检查请求，兼容旧的三个内存字段；
+// 数量非法、为负、节点类型或资源名未知时返回错误
+func parseQuotaRequest(req *model.DeptResourceQuotaRequest) (classRequests, error) {
+	if strings.TrimSpace(req.Dept) == "" {
+		return nil, fmt.Errorf("dept is required")
+	}
+	out := make(classRequests)
+	add := func(field string, t NodeType, name v1.ResourceName, value string) error {
+		if strings.TrimSpace(value) == "" {
+			return nil
+		}
+		q, err := resource.ParseQuantity(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s: invalid quantity %q: %v", field, value, err)
+		}
+		if q.Sign() < 0 {
+			return fmt.Errorf("%s: quantity %q must not be negative", field, value)
+		}
+		out[t] = addResourceList(out[t], v1.ResourceList{name: q})
+		return nil
+	}
+
+	legacy := []struct {
+		field string
+		t     NodeType
+		value string
+	}{
+		{"requestNonXcMemory", NonXcNodeType, req.RequestNonXcMemory},
+		{"requestKylinArmMemory", XcArmNodeType, req.RequestKylinArmMemory},
+		{"requestKylinHgMemory", XcX86NodeType, req.RequestKylinHgMemory},
+	}
+	for _, l := range legacy {
+		if err := add(l.field, l.t, v1.ResourceMemory, l.value); err != nil {
+			return nil, err
+		}
+	}
+
+	for class, list := range req.Requests {
+		nc := classOf(NodeType(class))
+		if nc == nil {
+			return nil, fmt.Errorf("requests: unknown node class %q, expected one of %s", class, strings.Join(nodeClassNames(), ", "))
+		}
+		for name, value := range list {
+			if !isQuotaResource(v1.ResourceName(name)) {
+				return nil, fmt.Errorf("requests.%s: unsupported resource %q, expected cpu, memory or pods", class, name)
+			}
+			if err := add("requests."+class+"."+name, nc.Type, v1.ResourceName(name), value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+func isQuotaResource(name v1.ResourceName) bool {
+	for _, r := range quotaResources {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeClassNames() []string {
+	names := make([]string, 0, len(nodeClasses))
+	for _, nc := range nodeClasses {
+		names = append(names, string(nc.Type))
+	}
+	return names
+}
+
+// deptPodTotals 从 PodInformer 缓存汇总部门各节点类型上 Pod 的 requests/limits 与数量
+func (h *ResourceHandler) deptPodTotals(dept string) classTotals {
+	totals := make(classTotals)
+	for _, pod := range h.Handler.Informers[PodInformer].(*informer.PodInformer).List() {
+		if pod.Labels["department"] != dept || podTerminated(pod) {
+			continue
+		}
+		class, _ := classOfPodSpec(&pod.Spec)
+		totals.get(class).add(pod)
+	}
+	return totals
+}
+
+// evaluateQuota 按节点类型逐项比较 已用 + 已预留 + 申请 与配额（spec limits）：
+// 已用取配额对象 status 中宣布的 limits 与 PodInformer 中部门 Pod 的 limits 之和（pods 为 Pod 数）两者的较大值，
+// status 由状态控制器限频回写而预留在 Pod 出现后即释放，只看 status 会漏算刚创建的 Pod；
+// 配额未设置与恰好用满配额时的结论见 quotaRules；只有申请量非零的维度参与结论
+func evaluateQuota(quota *v1alpha1.DeptResourceQuota, requests classRequests, live classTotals, reserved classRequests) model.DeptResourceQuotaResponse {
+	resp := model.DeptResourceQuotaResponse{Success: true}
+	var failed []string
+	for _, nc := range nodeClasses {
+		spec := nc.quota(&quota.Spec.Resources)
+		announced := nc.announced(&quota.Status.UsedResources)
+		t := live.get(nc.Type)
+		for _, name := range quotaResources {
+			requested := requests[nc.Type][name]
+			check := model.QuotaCheck{
+				Class:     string(nc.Type),
+				Resource:  string(name),
+				Requested: model.NewQuantity(name, requested),
+				Passed:    true,
+			}
+
+			used := t.limits[name]
+			if name == v1.ResourcePods {
+				used = *resource.NewQuantity(t.pods, resource.DecimalSI)
+			}
+			check.UsedFrom = usedFromPods
+			if status, ok := announced.Limits[name]; ok && status.Cmp(used) > 0 {
+				used = status
+				check.UsedFrom = usedFromStatus
+			}
+			check.Used = model.NewQuantity(name, used)
+			held := reserved[nc.Type][name]
+			check.Reserved = model.NewQuantity(name, held)
+
+			rule := quotaRules[name]
+			limit, limited := spec.Limits[name]
+			if !limited && !rule.unsetUnlimited {
+				limit, limited = resource.Quantity{}, true
+			}
+			check.Limited = limited
+			if limited {
+				check.Quota = model.NewQuantity(name, limit)
+				remaining := limit.DeepCopy()
+				remaining.Sub(used)
+				remaining.Sub(held)
+				check.Remaining = model.NewQuantity(name, remaining)
+				cmp := requested.Cmp(remaining)
+				if !requested.IsZero() && (cmp > 0 || cmp == 0 && !rule.allowFull) {
+					verb := "exceeds"
+					if cmp == 0 {
+						verb = "would use up"
+					}
+					check.Passed = false
+					failed = append(failed, fmt.Sprintf("%s %s: requested %s %s remaining %s (quota %s, used %s, reserved %s)",
+						nc.Section, name, requested.String(), verb, remaining.String(), limit.String(), used.String(), held.String()))
+				}
+			}
+			resp.Checks = append(resp.Checks, check)
+		}
+	}
+	if len(failed) > 0 {
+		resp.Success = false
+		resp.Reason = "After adding request, dept quota exceeded: " + strings.Join(failed, "; ")
+	}
+	return resp
+}
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"k8s-admin-informer/api/v1alpha1"
+	k8s "k8s-admin-informer/pkg/kubernetes"
+	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/logging"
+)
+
+// QuotaStatusController 根据 PodInformer 缓存计算各部门每类节点上 Pod 的 requests/limits 之和，
+// 回写 DeptResourceQuota 的 status 子资源；通过 Lease 选主，只有 leader 写入。
+// Pod 事件按部门进入队列，同一部门两次写入至少间隔 minInterval，状态未变化时不写
+type QuotaStatusController struct {
+	rsc   *ResourceHandler
+	queue workqueue.RateLimitingInterface
+	// minInterval 同一部门两次写入的最小间隔
+	minInterval time.Duration
+	// resyncInterval 全量入队间隔，覆盖配额对象本身变化等没有 Pod 事件的情况
+	resyncInterval time.Duration
+	leaseNamespace string
+	leaseName      string
+
+	mu        sync.Mutex
+	lastWrite map[string]time.Time
+}
+
+// NewQuotaStatusController 从环境变量读取配置：
+// - QUOTA_STATUS_MIN_INTERVAL 同一部门两次写入的最小间隔，默认 30s
+// - QUOTA_STATUS_RESYNC_INTERVAL 全量重算间隔，默认 5m
+// - LEADER_ELECTION_NAMESPACE 选主 Lease 所在命名空间，缺省取 POD_NAMESPACE，再缺省为 default
+// - LEADER_ELECTION_LEASE_NAME Lease 名称，默认 k8s-admin-informer-leader
+func NewQuotaStatusController(rsc *ResourceHandler) *QuotaStatusController {
+	defaultMinInterval := 30 * time.Second
+	minInterval := defaultMinInterval
+	if v := os.Getenv("QUOTA_STATUS_MIN_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			minInterval = d
+		} else {
+			log.Warnf("解析 QUOTA_STATUS_MIN_INTERVAL 失败，使用默认值 %s，错误: %v", defaultMinInterval.String(), err)
+		}
+	}
+	defaultResync := 5 * time.Minute
+	resync := defaultResync
+	if v := os.Getenv("QUOTA_STATUS_RESYNC_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			resync = d
+		} else {
+			log.Warnf("解析 QUOTA_STATUS_RESYNC_INTERVAL 失败，使用默认值 %s，错误: %v", defaultResync.String(), err)
+		}
+	}
+	ns := os.Getenv("LEADER_ELECTION_NAMESPACE")
+	if ns == "" {
+		ns = os.Getenv("POD_NAMESPACE")
+	}
+	if ns == "" {
+		ns = "default"
+	}
+	name := os.Getenv("LEADER_ELECTION_LEASE_NAME")
+	if name == "" {
+		name = "k8s-admin-informer-leader"
+	}
+	return &QuotaStatusController{
+		rsc:            rsc,
+		queue:          workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Second, 5*time.Minute), "deptQuotaStatus"),
+		minInterval:    minInterval,
+		resyncInterval: resync,
+		leaseNamespace: ns,
+		leaseName:      name,
+		lastWrite:      make(map[string]time.Time),
+	}
+}
+
+// Start 注册 Pod 事件并参与选主，需在 informer 启动后调用
+func (c *QuotaStatusController) Start() {
+	podInf := c.rsc.Handler.Informers[PodInformer].(*informer.PodInformer)
+	_ = podInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueuePod,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueuePod(oldObj)
+			c.enqueuePod(newObj)
+		},
+		DeleteFunc: c.enqueuePod,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-c.rsc.Handler.stopCh
+		cancel()
+		c.queue.ShutDown()
+	}()
+	go k8s.RunWithLeaderElection(ctx, c.rsc.Handler.client, c.leaseNamespace, c.leaseName, c.lead)
+}
+
+func (c *QuotaStatusController) enqueuePod(obj interface{}) {
+	pod, ok := informer.PodFromObject(obj)
+	if !ok {
+		return
+	}
+	if dept := pod.Labels["department"]; dept != "" {
+		c.queue.Add(dept)
+	}
+}
+
+// lead 成为 leader 后运行，ctx 结束表示失去 leader 身份
+func (c *QuotaStatusController) lead(ctx context.Context) {
+	log.Infof("成为 leader，开始回写 DeptResourceQuota status")
+	go wait.Until(c.enqueueAll, c.resyncInterval, ctx.Done())
+	go wait.Until(func() {
+		for c.processNext(ctx) {
+		}
+	}, time.Second, ctx.Done())
+	<-ctx.Done()
+}
+
+func (c *QuotaStatusController) enqueueAll() {
+	for _, quota := range c.rsc.Handler.Informers[DeptResourceQuotaInformer].(*informer.DeptResourceQuotaInformer).List() {
+		c.queue.Add(quota.Spec.DeptName)
+	}
+}
+
+func (c *QuotaStatusController) processNext(ctx context.Context) bool {
+	item, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(item)
+	dept := item.(string)
+	if ctx.Err() != nil {
+		// 已不是 leader，留给下一任 leader 处理
+		c.queue.Add(dept)
+		return false
+	}
+	if err := c.sync(dept); err != nil {
+		log.WithField(logging.FieldDept, dept).Errorf("回写部门 %s 配额状态失败: %v", dept, err)
+		c.queue.AddRateLimited(dept)
+		return true
+	}
+	c.queue.Forget(dept)
+	return true
+}
+
+func (c *QuotaStatusController) sync(dept string) error {
+	c.mu.Lock()
+	last := c.lastWrite[dept]
+	c.mu.Unlock()
+	if remaining := c.minInterval - time.Since(last); remaining > 0 {
+		c.queue.AddAfter(dept, remaining)
+		return nil
+	}
+
+	quotaInf := c.rsc.Handler.Informers[DeptResourceQuotaInformer].(*informer.DeptResourceQuotaInformer)
+	quota := quotaInf.GetDeptResourceQuotaByName(dept)
+	if quota == nil {
+		return nil
+	}
+	status := c.computeStatus(quota)
+	if quotaStatusEqual(quota.Status, status) {
+		return nil
+	}
+	if err := quotaInf.PatchStatus(quota, status); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.lastWrite[dept] = time.Now()
+	c.mu.Unlock()
+	log.WithField(logging.FieldDept, dept).Infof("已更新部门 %s 配额状态: %s %s", dept, status.QuotaStatus, status.Reason)
+	return nil
+}
+
+// computeStatus 计算配额对象的新状态：
+// 用量未超出配额时 QuotaStatus=True、Reason=Updated；超出时 QuotaStatus=False、Reason=QuotaExceeded 并在 Message 中列出超出项；
+// Pod 缓存尚未同步时 QuotaStatus=Unknown、Reason=ErrorComputingQuotaResource，保留原有用量
+func (c *QuotaStatusController) computeStatus(quota *v1alpha1.DeptResourceQuota) v1alpha1.DeptResourceQuotaStatus {
+	status := v1alpha1.DeptResourceQuotaStatus{
+		QuotaStatus: v1.ConditionTrue,
+		Reason:      string(v1alpha1.ComputingQuotaResource),
+		Message:     "usage within quota",
+	}
+	podInf := c.rsc.Handler.Informers[PodInformer].(*informer.PodInformer)
+	if !podInf.HasSynced() {
+		status.UsedResources = quota.Status.UsedResources
+		status.QuotaStatus = v1.ConditionUnknown
+		status.Reason = string(v1alpha1.ErrorComputingQuotaResource)
+		status.Message = "pod cache is not synced"
+	} else {
+		totals := c.rsc.deptPodTotals(quota.Spec.DeptName)
+		var exceeded []string
+		for _, nc := range nodeClasses {
+			t := totals.get(nc.Type)
+			used := nc.announced(&status.UsedResources)
+			used.Requests = usedResourceList(t.requests, t.pods)
+			used.Limits = usedResourceList(t.limits, t.pods)
+			spec := nc.quota(&quota.Spec.Resources)
+			exceeded = append(exceeded, exceededItems(nc.Section, "requests", spec.Requests, used.Requests)...)
+			exceeded = append(exceeded, exceededItems(nc.Section, "limits", spec.Limits, used.Limits)...)
+		}
+		if len(exceeded) > 0 {
+			status.QuotaStatus = v1.ConditionFalse
+			status.Reason = string(v1alpha1.QuotaResourceExceeded)
+			status.Message = "usage exceeds quota: " + strings.Join(exceeded, "; ")
+		}
+	}
+
+	status.LastTransitionTime = quota.Status.LastTransitionTime
+	if quota.Status.QuotaStatus != status.QuotaStatus || status.LastTransitionTime.IsZero() {
+		status.LastTransitionTime = metaV1.Now()
+	}
+	return status
+}
+
+// usedResourceList 回写到 status 的用量：cpu、memory 与 Pod 数
+func usedResourceList(list v1.ResourceList, pods int64) v1.ResourceList {
+	out := withComputeDefaults(list)
+	out[v1.ResourcePods] = *resource.NewQuantity(pods, resource.DecimalSI)
+	return out
+}
+
+// exceededItems 列出 used 超出 quota 的维度，quota 未设置的维度不限制
+func exceededItems(section, kind string, quota, used v1.ResourceList) []string {
+	var out []string
+	for _, name := range quotaResources {
+		limit, ok := quota[name]
+		if !ok {
+			continue
+		}
+		u := used[name]
+		if u.Cmp(limit) > 0 {
+			out = append(out, fmt.Sprintf("%s %s %s: used %s exceeds quota %s", section, kind, name, u.String(), limit.String()))
+		}
+	}
+	return out
+}
+
+// quotaStatusEqual 忽略 LastTransitionTime 比较两个状态
+func quotaStatusEqual(a, b v1alpha1.DeptResourceQuotaStatus) bool {
+	return a.QuotaStatus == b.QuotaStatus && a.Reason == b.Reason && a.Message == b.Message &&
+		apiequality.Semantic.DeepEqual(a.UsedResources, b.UsedResources)
+}
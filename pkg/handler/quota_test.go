@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"k8s-admin-informer/api/v1alpha1"
+)
+
+func TestEvaluateQuota(t *testing.T) {
+	quantity := func(name v1.ResourceName, v string) v1.ResourceList {
+		if v == "" {
+			return v1.ResourceList{}
+		}
+		return v1.ResourceList{name: resource.MustParse(v)}
+	}
+	cases := []struct {
+		name      string
+		resource  v1.ResourceName
+		quota     string // 空表示未设置
+		used      string
+		reserved  string
+		requested string
+		limited   bool
+		passed    bool
+	}{
+		{name: "memory unset is a zero quota", resource: v1.ResourceMemory, requested: "1Mi", limited: true, passed: false},
+		{name: "memory zero quota", resource: v1.ResourceMemory, quota: "0", requested: "1Mi", limited: true, passed: false},
+		{name: "memory below quota", resource: v1.ResourceMemory, quota: "4Gi", used: "2Gi", requested: "1Gi", limited: true, passed: true},
+		{name: "memory exactly at quota", resource: v1.ResourceMemory, quota: "4Gi", used: "2Gi", requested: "2Gi", limited: true, passed: false},
+		{name: "memory at quota with reservation", resource: v1.ResourceMemory, quota: "4Gi", used: "1Gi", reserved: "1Gi", requested: "2Gi", limited: true, passed: false},
+		{name: "memory over quota", resource: v1.ResourceMemory, quota: "4Gi", used: "2Gi", requested: "3Gi", limited: true, passed: false},
+		{name: "memory unset without request", resource: v1.ResourceMemory, limited: true, passed: true},
+		{name: "cpu unset is unlimited", resource: v1.ResourceCPU, requested: "64", limited: false, passed: true},
+		{name: "cpu zero quota", resource: v1.ResourceCPU, quota: "0", requested: "100m", limited: true, passed: false},
+		{name: "cpu exactly at quota", resource: v1.ResourceCPU, quota: "4", used: "3", requested: "1", limited: true, passed: true},
+		{name: "cpu over quota", resource: v1.ResourceCPU, quota: "4", used: "3", reserved: "500m", requested: "1", limited: true, passed: false},
+		{name: "pods unset is unlimited", resource: v1.ResourcePods, requested: "100", limited: false, passed: true},
+		{name: "pods exactly at quota", resource: v1.ResourcePods, quota: "10", used: "8", requested: "2", limited: true, passed: true},
+		{name: "pods over quota", resource: v1.ResourcePods, quota: "10", used: "8", requested: "3", limited: true, passed: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			quota := &v1alpha1.DeptResourceQuota{}
+			quota.Spec.Resources.NonXcResources.Limits = quantity(tc.resource, tc.quota)
+			quota.Status.UsedResources.UsedNonXcResource.Limits = quantity(tc.resource, tc.used)
+			requests := classRequests{NonXcNodeType: quantity(tc.resource, tc.requested)}
+			reserved := classRequests{NonXcNodeType: quantity(tc.resource, tc.reserved)}
+
+			resp := evaluateQuota(quota, requests, classTotals{}, reserved)
+			if resp.Success != tc.passed {
+				t.Fatalf("success = %t, want %t (reason %q)", resp.Success, tc.passed, resp.Reason)
+			}
+			for _, check := range resp.Checks {
+				if check.Class != string(NonXcNodeType) || check.Resource != string(tc.resource) {
+					if !check.Passed {
+						t.Fatalf("unrelated check failed: %+v", check)
+					}
+					continue
+				}
+				if check.Limited != tc.limited || check.Passed != tc.passed {
+					t.Fatalf("check = %+v, want limited %t passed %t", check, tc.limited, tc.passed)
+				}
+				return
+			}
+			t.Fatalf("no check for %s %s", NonXcNodeType, tc.resource)
+		})
+	}
+}
+
+// TestEvaluateQuotaUsedFromLargerSource 已用取配额状态与 Pod 缓存汇总中较大的一方
+func TestEvaluateQuotaUsedFromLargerSource(t *testing.T) {
+	quota := &v1alpha1.DeptResourceQuota{}
+	quota.Spec.Resources.NonXcResources.Limits = v1.ResourceList{v1.ResourceMemory: resource.MustParse("4Gi")}
+	quota.Status.UsedResources.UsedNonXcResource.Limits = v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")}
+	live := classTotals{NonXcNodeType: &podTotals{limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("3Gi")}}}
+	requests := classRequests{NonXcNodeType: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")}}
+
+	resp := evaluateQuota(quota, requests, live, nil)
+	if resp.Success {
+		t.Fatalf("request reaching the quota over pod-cache usage passed")
+	}
+	for _, check := range resp.Checks {
+		if check.Class == string(NonXcNodeType) && check.Resource == string(v1.ResourceMemory) && check.UsedFrom != usedFromPods {
+			t.Fatalf("usedFrom = %s, want %s", check.UsedFrom, usedFromPods)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s-admin-informer/api/v1alpha1"
+	"k8s-admin-informer/pkg/authz"
+	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/model"
+	"k8s-admin-informer/pkg/reservation"
+)
+
+// newReservationStore 按环境变量创建预留存储：
+// - RESERVATION_NAMESPACE 保存预留的 ConfigMap 所在命名空间，缺省取 POD_NAMESPACE，再缺省为 default
+// - RESERVATION_CONFIGMAP ConfigMap 名称，默认 k8s-admin-informer-reservations
+// - RESERVATION_DEFAULT_TTL 未指定 TTL 时的有效期，默认 10m
+// - RESERVATION_MAX_TTL 有效期上限，默认 1h
+func newReservationStore(handler *Handler) *reservation.Store {
+	cfg := reservation.Config{
+		Namespace:     os.Getenv("RESERVATION_NAMESPACE"),
+		ConfigMapName: os.Getenv("RESERVATION_CONFIGMAP"),
+		DefaultTTL:    10 * time.Minute,
+		MaxTTL:        time.Hour,
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = os.Getenv("POD_NAMESPACE")
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = "default"
+	}
+	if cfg.ConfigMapName == "" {
+		cfg.ConfigMapName = "k8s-admin-informer-reservations"
+	}
+	if v := os.Getenv("RESERVATION_DEFAULT_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.DefaultTTL = d
+		} else {
+			log.Warnf("解析 RESERVATION_DEFAULT_TTL 失败，使用默认值 %s，错误: %v", cfg.DefaultTTL.String(), err)
+		}
+	}
+	if v := os.Getenv("RESERVATION_MAX_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.MaxTTL = d
+		} else {
+			log.Warnf("解析 RESERVATION_MAX_TTL 失败，使用默认值 %s，错误: %v", cfg.MaxTTL.String(), err)
+		}
+	}
+	if handler == nil || handler.client == nil {
+		return reservation.NewStore(nil, cfg)
+	}
+	return reservation.NewStore(handler.client, cfg)
+}
+
+// StartReservations 恢复持久化的预留，并监听 Pod 创建以自动释放预留；需在 informer 启动后调用
+func (h *ResourceHandler) StartReservations() {
+	h.reservations.Start(h.Handler.stopCh)
+	podInf := h.Handler.Informers[PodInformer].(*informer.PodInformer)
+	_ = podInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := informer.PodFromObject(obj); ok {
+				h.reservations.ObservePod(pod)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if pod, ok := informer.PodFromObject(newObj); ok {
+				h.reservations.ObservePod(pod)
+			}
+		},
+	})
+}
+
+// parseReserveOptions 校验预留参数并返回 TTL，opts 为 nil 时不预留
+func parseReserveOptions(opts *model.ReserveOptions) (time.Duration, error) {
+	if opts == nil {
+		return 0, nil
+	}
+	var ttl time.Duration
+	if opts.TTL != "" {
+		d, err := time.ParseDuration(opts.TTL)
+		if err != nil {
+			return 0, fmt.Errorf("reserve.ttl: %v", err)
+		}
+		if d <= 0 {
+			return 0, fmt.Errorf("reserve.ttl: must be positive")
+		}
+		ttl = d
+	}
+	if opts.Pods < 0 {
+		return 0, fmt.Errorf("reserve.pods: must not be negative")
+	}
+	return ttl, nil
+}
+
+// checkAndReserve 计入部门未过期预留后校验配额，通过且 opts 非空时在同一把锁内写入预留，
+// 因此并发的检查不会同时占用同一份剩余额度；owner 非 nil 时不计入为该对象本身预留的额度
+func (h *ResourceHandler) checkAndReserve(quota *v1alpha1.DeptResourceQuota, dept string, requests classRequests,
+	opts *model.ReserveOptions, ttl time.Duration, owner *reservation.Owner) model.DeptResourceQuotaResponse {
+	live := h.deptPodTotals(dept)
+	var resp model.DeptResourceQuotaResponse
+	r := h.reservations.Reserve(dept, ttl, owner, func(reserved map[string]v1.ResourceList) *reservation.Reservation {
+		resp = evaluateQuota(quota, requests, live, reservedByClass(reserved))
+		if !resp.Success || opts == nil {
+			return nil
+		}
+		out := &reservation.Reservation{
+			Requests:  make(map[string]v1.ResourceList, len(requests)),
+			Namespace: opts.Namespace,
+			Release:   opts.Release,
+			Pods:      opts.Pods,
+		}
+		for t, list := range requests {
+			out.Requests[string(t)] = list.DeepCopy()
+		}
+		return out
+	})
+	if r != nil {
+		resp.Reservation = modelReservation(r)
+	}
+	return resp
+}
+
+func reservedByClass(reserved map[string]v1.ResourceList) classRequests {
+	out := make(classRequests, len(reserved))
+	for class, list := range reserved {
+		out[NodeType(class)] = list
+	}
+	return out
+}
+
+func modelReservation(r *reservation.Reservation) *model.Reservation {
+	out := &model.Reservation{
+		ID:        r.ID,
+		Dept:      r.Dept,
+		Requests:  make(map[string]model.ResourceAmounts, len(r.Requests)),
+		Namespace: r.Namespace,
+		Release:   r.Release,
+		Pods:      r.Pods,
+		SeenPods:  len(r.Seen),
+		CreatedAt: r.CreatedAt,
+		ExpiresAt: r.ExpiresAt,
+	}
+	for class, list := range r.Requests {
+		out.Requests[class] = model.NewResourceAmounts(list)
+	}
+	return out
+}
+
+// ListReservations 列出未过期的配额预留，可通过 dept 查询参数过滤；
+// 启用部门授权时只返回调用方可见部门的预留，指定不可见的部门返回 403
+func (h *ResourceHandler) ListReservations(c *gin.Context) {
+	scope := authz.ScopeFrom(c.Request.Context())
+	dept := c.Query("dept")
+	if dept != "" && !scope.Allows(dept) {
+		authz.Forbidden(c, "department "+dept+" is not visible to the caller")
+		return
+	}
+	list := h.reservations.List(dept)
+	resp := model.ReservationList{Items: make([]model.Reservation, 0, len(list))}
+	for i := range list {
+		if scope.Allows(list[i].Dept) {
+			resp.Items = append(resp.Items, *modelReservation(&list[i]))
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ReleaseReservation 显式释放预留，预留不存在（已释放或已过期）时返回 404，所属部门对调用方不可见时返回 403
+func (h *ResourceHandler) ReleaseReservation(c *gin.Context) {
+	id := c.Param("id")
+	r, ok := h.reservations.Get(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "reservation not found"})
+		return
+	}
+	if !authz.ScopeFrom(c.Request.Context()).Allows(r.Dept) {
+		authz.Forbidden(c, "department "+r.Dept+" is not visible to the caller")
+		return
+	}
+	if !h.reservations.Release(id) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "reservation not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+
+	"k8s-admin-informer/pkg/auth"
+	"k8s-admin-informer/pkg/authz"
+	"k8s-admin-informer/pkg/model"
+	"k8s-admin-informer/pkg/reservation"
+)
+
+// scopedRouter 以 user 的身份调用配额校验与预留接口，授权策略只把部门 a 授予 alice
+func scopedRouter(h *ResourceHandler, user *auth.User) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	authorizer := authz.NewAuthorizer(nil, authz.Config{Enabled: true, Policy: authz.Policy{
+		Admins: authz.Subjects{Users: []string{"root"}},
+		Rules:  []authz.Rule{{Subjects: authz.Subjects{Users: []string{"alice"}}, Depts: []string{"a"}}},
+	}})
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(authz.WithScope(c.Request.Context(), authorizer.ScopeFor(user)))
+	})
+	engine.POST(model.DeptCheckLimitPath, h.ComputeDeptResourceQuotaLimit)
+	engine.POST(model.DeptCheckManifestPath, h.CheckDeptLimitByManifest)
+	engine.GET(model.DeptReservationsPath, h.ListReservations)
+	engine.DELETE(model.DeptReservationPath, h.ReleaseReservation)
+	return engine
+}
+
+func reserveFor(h *ResourceHandler, dept string) string {
+	return h.reservations.Reserve(dept, time.Minute, nil, func(map[string]v1.ResourceList) *reservation.Reservation {
+		return &reservation.Reservation{Requests: map[string]v1.ResourceList{}}
+	}).ID
+}
+
+func TestReservationsAreScopedToVisibleDepartments(t *testing.T) {
+	h := newTestResourceHandler(t)
+	own, other := reserveFor(h, "a"), reserveFor(h, "b")
+	alice := scopedRouter(h, &auth.User{Name: "alice"})
+
+	serve := func(engine *gin.Engine, method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := serve(alice, http.MethodGet, model.DeptReservationsPath)
+	var list model.ReservationList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("list: %d %s", w.Code, w.Body.String())
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != own {
+		t.Fatalf("list returned %+v, want only the reservation of department a", list.Items)
+	}
+	if w := serve(alice, http.MethodGet, model.DeptReservationsPath+"?dept=b"); w.Code != http.StatusForbidden {
+		t.Fatalf("list of another department: got %d, want 403", w.Code)
+	}
+
+	if w := serve(alice, http.MethodDelete, model.DeptReservationsPath+"/"+other); w.Code != http.StatusForbidden {
+		t.Fatalf("release of another department's reservation: got %d, want 403", w.Code)
+	}
+	if _, ok := h.reservations.Get(other); !ok {
+		t.Fatalf("forbidden release removed the reservation")
+	}
+	if w := serve(alice, http.MethodDelete, model.DeptReservationsPath+"/"+own); w.Code != http.StatusNoContent {
+		t.Fatalf("release of own reservation: got %d, want 204", w.Code)
+	}
+
+	root := scopedRouter(h, &auth.User{Name: "root"})
+	if w := serve(root, http.MethodDelete, model.DeptReservationsPath+"/"+other); w.Code != http.StatusNoContent {
+		t.Fatalf("admin release: got %d, want 204", w.Code)
+	}
+}
+
+func TestQuotaChecksAreScopedToVisibleDepartments(t *testing.T) {
+	h := newTestResourceHandler(t)
+	withDeptQuotas(h, "a", "b")
+	alice := scopedRouter(h, &auth.User{Name: "alice"})
+	post := func(path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		alice.ServeHTTP(w, req)
+		return w
+	}
+
+	check := `{"dept":"%s","requests":{"nonXc":{"memory":"1Gi"}},"reserve":{"ttl":"1m"}}`
+	if w := post(model.DeptCheckLimitPath, "application/json", strings.Replace(check, "%s", "a", 1)); w.Code != http.StatusOK {
+		t.Fatalf("check of own department: %d %s", w.Code, w.Body.String())
+	}
+	if w := post(model.DeptCheckLimitPath, "application/json", strings.Replace(check, "%s", "b", 1)); w.Code != http.StatusForbidden {
+		t.Fatalf("check of another department: got %d, want 403", w.Code)
+	}
+	if list := h.reservations.List("b"); len(list) != 0 {
+		t.Fatalf("forbidden check reserved quota in department b: %+v", list)
+	}
+
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: ns
+spec:
+  replicas: 1
+  template:
+    metadata:
+      labels:
+        department: b
+    spec:
+      containers:
+      - name: app
+        resources:
+          limits:
+            memory: 1Gi
+`
+	if w := post(model.DeptCheckManifestPath, "application/yaml", manifest); w.Code != http.StatusForbidden {
+		t.Fatalf("manifest check of another department: got %d, want 403", w.Code)
+	}
+	if w := post(model.DeptCheckManifestPath+"?dept=a", "application/yaml", manifest); w.Code != http.StatusOK {
+		t.Fatalf("manifest check of own department: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -1,25 +1,33 @@
 package handler
 
 import (
-    "context"
-    "net/http"
-    "strings"
-    "time"
-    "os"
-    "sync"
-
-    "github.com/gin-gonic/gin"
-    "github.com/prometheus/client_golang/prometheus"
-    log "github.com/sirupsen/logrus"
-    v1 "k8s.io/api/core/v1"
-    "k8s.io/apimachinery/pkg/api/resource"
-    metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-    "k8s.io/apimachinery/pkg/labels"
-    metrics "k8s.io/metrics/pkg/apis/metrics/v1beta1"
-    "k8s.io/client-go/tools/cache"
-
-    "k8s-admin-informer/pkg/kubernetes/informer"
-    "k8s-admin-informer/pkg/model"
+	"context"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"k8s-admin-informer/pkg/audit"
+	"k8s-admin-informer/pkg/authz"
+	"k8s-admin-informer/pkg/chargeback"
+	"k8s-admin-informer/pkg/export"
+	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/logging"
+	"k8s-admin-informer/pkg/model"
+	"k8s-admin-informer/pkg/reservation"
+	"k8s-admin-informer/pkg/tracing"
 )
 
 type NodeType string
@@ -38,252 +46,262 @@ const (
 	KylinArmNodePrefix  NodePrefix = "kk"
 )
 
-var (
-	deptMemResourceQuota = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "dept_memory_resource_quota_bytes",
-			Help: "Department current resource quota bytes.",
-		},
-		[]string{"department", "os", "arch"},
-	)
-	deptUsedMemResource = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "dept_used_memory_quota_bytes",
-			Help: "Department used memory resource quota bytes.",
-		},
-		[]string{"department", "os", "arch"},
-	)
-	deptPodCount = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "dept_current_pods_num_total",
-			Help: "Department current pod counts.",
-		},
-		[]string{"department"},
-	)
-)
-
-func init() {
-	// 注册自定义指标
-	prometheus.MustRegister(deptMemResourceQuota)
-	prometheus.MustRegister(deptUsedMemResource)
-	prometheus.MustRegister(deptPodCount)
-}
-
-func (h *ResourceHandler) ProbeDeptResource() {
-	deptResources := h.GetDeptResource()
-	for _, deptResource := range deptResources {
-		// set resource quota gauge
-		rhelAmdMemQuota := resource.MustParse(deptResource.Resources.NonXc.Limits.Memory)
-		kylinArmMemQuota := resource.MustParse(deptResource.Resources.XC.Arm.Limits.Memory)
-		kylinX86MemQuota := resource.MustParse(deptResource.Resources.XC.X86.Limits.Memory)
-
-		deptMemResourceQuota.With(prometheus.Labels{"department": deptResource.Name, "os": "rhel", "arch": "amd64"}).Set(float64(rhelAmdMemQuota.Value()))
-		deptMemResourceQuota.With(prometheus.Labels{"department": deptResource.Name, "os": "kylin", "arch": "arm64v8"}).Set(float64(kylinArmMemQuota.Value()))
-		deptMemResourceQuota.With(prometheus.Labels{"department": deptResource.Name, "os": "kylin", "arch": "amd64"}).Set(float64(kylinX86MemQuota.Value()))
-
-		// set used mem gauge
-		rhelAmdUsedMem := resource.MustParse(deptResource.Announced.NonXc.Limits.Memory)
-		kylinArmUsedMem := resource.MustParse(deptResource.Announced.XC.Arm.Limits.Memory)
-		kylinX86UsedMem := resource.MustParse(deptResource.Announced.XC.X86.Limits.Memory)
-
-		deptUsedMemResource.With(prometheus.Labels{"department": deptResource.Name, "os": "rhel", "arch": "amd64"}).Set(float64(rhelAmdUsedMem.Value()))
-		deptUsedMemResource.With(prometheus.Labels{"department": deptResource.Name, "os": "kylin", "arch": "arm64v8"}).Set(float64(kylinArmUsedMem.Value()))
-		deptUsedMemResource.With(prometheus.Labels{"department": deptResource.Name, "os": "kylin", "arch": "amd64"}).Set(float64(kylinX86UsedMem.Value()))
-		// set pod count gauge
-		deptPodCount.With(prometheus.Labels{"department": deptResource.Name}).Set(float64(deptResource.Pods))
-	}
-}
-
 // ResourceHandler 负责资源相关的处理：
 // - 使用 PodInformer 本地缓存进行一次性遍历与部门聚合
-// - 维护部门资源查询缓存与 TTL，降低重复计算与远端请求
+// - 以带版本号的不可变快照缓存部门/节点资源查询结果与 TTL，降低重复计算与远端请求
 type ResourceHandler struct {
-    Handler               *Handler
-    // deptResourceCache 缓存最近一次部门资源聚合结果
-    deptResourceCache     []model.DeptResource
-    // deptResourceCacheTime 记录缓存生成时间
-    deptResourceCacheTime time.Time
-    // cacheTTL 缓存有效期，默认 30s，可通过环境变量配置
-    cacheTTL              time.Duration
-    // nodeResourceCache 缓存最近一次节点资源聚合结果
-    nodeResourceCache     model.NodeList
-    // nodeResourceCacheTime 记录节点资源缓存生成时间
-    nodeResourceCacheTime time.Time
-    // deptRefreshInterval 部门指标后台刷新间隔
-    deptRefreshInterval   time.Duration
-    // nodeRefreshInterval 节点指标后台刷新间隔
-    nodeRefreshInterval   time.Duration
-    deptEvents            chan struct{}
-    nodeEvents            chan struct{}
-    recomputeMu           sync.Mutex
-    deptAgg               map[string]*struct{ nonXc, arm, x86 resource.Quantity; pods int }
-    podRecords            map[string]struct{ dept string; arch NodeType; mem resource.Quantity }
-    nodeAgg               map[string]struct{ allocCPU, allocMem, usedCPU, usedMem string; nodeType NodeType }
+	Handler *Handler
+	// deptSnapshots/nodeSnapshots 最近一次部门/节点资源聚合结果，在持有 recomputeMu 时构建并原子替换
+	deptSnapshots *snapshotCell[[]model.DeptResource]
+	nodeSnapshots *snapshotCell[model.NodeList]
+	// snapshotMaxWait minVersion 请求等待新快照的最长时间
+	snapshotMaxWait time.Duration
+	// cacheTTL 缓存有效期，默认 30s，可通过环境变量配置
+	cacheTTL time.Duration
+	// deptRefreshInterval 部门指标后台刷新间隔
+	deptRefreshInterval time.Duration
+	// nodeRefreshInterval 节点指标后台刷新间隔
+	nodeRefreshInterval time.Duration
+	// metrics 指标缓存，事件处理只从这里读取用量
+	metrics *metricsCache
+	// podQueue/nodeQueue 待同步的 Pod（namespace/name）与节点名，由 eventWorkers 个 worker 消费
+	podQueue     workqueue.Interface
+	nodeQueue    workqueue.Interface
+	eventWorkers int
+	// rebuildQueue 待重建的缓存（rebuildDept/rebuildNode），同一缓存两次重建至少间隔 rebuildInterval
+	rebuildQueue    workqueue.DelayingInterface
+	rebuildInterval time.Duration
+	rebuildMu       sync.Mutex
+	lastRebuild     map[string]time.Time
+	recomputeMu     sync.Mutex
+	deptAgg         map[string]*deptAggItem
+	// podRecords 按 Pod UID 记录已计入 deptAgg 的值，podUIDs 为 namespace/name 到当前 UID 的索引
+	podRecords map[types.UID]podRecord
+	podUIDs    map[string]types.UID
+	nodeAgg    map[string]nodeRecord
+	// reservations 配额预留，检查时计入已用
+	reservations *reservation.Store
+	// audit 配额校验结论与写操作的审计记录
+	audit *audit.Logger
+	// costs 部门成本账本，未配置成本模型时为 nil
+	costs *chargeback.Ledger
 }
 
 // DeptRefreshInterval 返回部门资源后台刷新间隔
 func (h *ResourceHandler) DeptRefreshInterval() time.Duration {
-    return h.deptRefreshInterval
+	return h.deptRefreshInterval
 }
 
 // NodeRefreshInterval 返回节点资源后台刷新间隔
 func (h *ResourceHandler) NodeRefreshInterval() time.Duration {
-    return h.nodeRefreshInterval
+	return h.nodeRefreshInterval
+}
+
+// UsageHeaders 返回中间件，在资源接口响应头中报告所用实时用量的来源（X-Usage-Source）、
+// 最近一次成功更新时间（X-Usage-Updated-At）、是否过期（X-Usage-Stale）与来源错误（X-Usage-Error）
+func (h *ResourceHandler) UsageHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.metrics.setUsageHeaders(c)
+		c.Next()
+	}
 }
 
 // NewResourceHandler 初始化资源处理器：
 // - 从环境变量 DEPT_RESOURCE_CACHE_TTL 读取缓存 TTL（如 "30s"、"1m"）
 // - 未设置或解析失败则使用默认 30s
+// - METRICS_SCRAPE_MIN_INTERVAL 两次批量抓取指标的最小间隔，默认 10s
+// - EVENT_WORKERS 处理 Pod/节点事件的 worker 数，默认 2
+// - AGG_REBUILD_MIN_INTERVAL 同一缓存两次重建的最小间隔，默认 1s
+// - USAGE_STALE_AFTER 用量超过该时长未成功更新时在响应头中标记为过期，默认 3m
+// - USAGE_PROVIDER 用量来源，见 newUsageProvider
+// - SNAPSHOT_MAX_WAIT 带 minVersion 的请求等待新快照的最长时间，默认 5s
 func NewResourceHandler(handler *Handler) *ResourceHandler {
-    defaultTTL := 30 * time.Second
-    ttl := defaultTTL
-    if v := os.Getenv("DEPT_RESOURCE_CACHE_TTL"); v != "" {
-        if d, err := time.ParseDuration(v); err == nil {
-            ttl = d
-        } else {
-            log.Warnf("解析 DEPT_RESOURCE_CACHE_TTL 失败，使用默认值 %s，错误: %v", defaultTTL.String(), err)
-        }
-    }
-    defaultDeptRefresh := 15 * time.Second
-    deptRefresh := defaultDeptRefresh
-    if v := os.Getenv("DEPT_RESOURCE_REFRESH_INTERVAL"); v != "" {
-        if d, err := time.ParseDuration(v); err == nil {
-            deptRefresh = d
-        } else {
-            log.Warnf("解析 DEPT_RESOURCE_REFRESH_INTERVAL 失败，使用默认值 %s，错误: %v", defaultDeptRefresh.String(), err)
-        }
-    }
-    defaultNodeRefresh := 10 * time.Second
-    nodeRefresh := defaultNodeRefresh
-    if v := os.Getenv("NODE_RESOURCE_REFRESH_INTERVAL"); v != "" {
-        if d, err := time.ParseDuration(v); err == nil {
-            nodeRefresh = d
-        } else {
-            log.Warnf("解析 NODE_RESOURCE_REFRESH_INTERVAL 失败，使用默认值 %s，错误: %v", defaultNodeRefresh.String(), err)
-        }
-    }
-    return &ResourceHandler{
-        Handler:            handler,
-        cacheTTL:           ttl,
-        deptRefreshInterval: deptRefresh,
-        nodeRefreshInterval: nodeRefresh,
-        deptEvents:         make(chan struct{}, 1),
-        nodeEvents:         make(chan struct{}, 1),
-        deptAgg:            make(map[string]*struct{ nonXc, arm, x86 resource.Quantity; pods int }),
-        podRecords:         make(map[string]struct{ dept string; arch NodeType; mem resource.Quantity }),
-        nodeAgg:            make(map[string]struct{ allocCPU, allocMem, usedCPU, usedMem string; nodeType NodeType }),
-    }
+	defaultTTL := 30 * time.Second
+	ttl := defaultTTL
+	if v := os.Getenv("DEPT_RESOURCE_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			ttl = d
+		} else {
+			log.Warnf("解析 DEPT_RESOURCE_CACHE_TTL 失败，使用默认值 %s，错误: %v", defaultTTL.String(), err)
+		}
+	}
+	defaultDeptRefresh := 15 * time.Second
+	deptRefresh := defaultDeptRefresh
+	if v := os.Getenv("DEPT_RESOURCE_REFRESH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			deptRefresh = d
+		} else {
+			log.Warnf("解析 DEPT_RESOURCE_REFRESH_INTERVAL 失败，使用默认值 %s，错误: %v", defaultDeptRefresh.String(), err)
+		}
+	}
+	defaultNodeRefresh := 10 * time.Second
+	nodeRefresh := defaultNodeRefresh
+	if v := os.Getenv("NODE_RESOURCE_REFRESH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			nodeRefresh = d
+		} else {
+			log.Warnf("解析 NODE_RESOURCE_REFRESH_INTERVAL 失败，使用默认值 %s，错误: %v", defaultNodeRefresh.String(), err)
+		}
+	}
+	defaultScrape := 10 * time.Second
+	scrape := defaultScrape
+	if v := os.Getenv("METRICS_SCRAPE_MIN_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			scrape = d
+		} else {
+			log.Warnf("解析 METRICS_SCRAPE_MIN_INTERVAL 失败，使用默认值 %s，错误: %v", defaultScrape.String(), err)
+		}
+	}
+	defaultWorkers := 2
+	workers := defaultWorkers
+	if v := os.Getenv("EVENT_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			workers = n
+		} else {
+			log.Warnf("解析 EVENT_WORKERS 失败，使用默认值 %d，错误: %v", defaultWorkers, err)
+		}
+	}
+	defaultRebuild := time.Second
+	rebuild := defaultRebuild
+	if v := os.Getenv("AGG_REBUILD_MIN_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			rebuild = d
+		} else {
+			log.Warnf("解析 AGG_REBUILD_MIN_INTERVAL 失败，使用默认值 %s，错误: %v", defaultRebuild.String(), err)
+		}
+	}
+	defaultStaleAfter := 3 * time.Minute
+	staleAfter := defaultStaleAfter
+	if v := os.Getenv("USAGE_STALE_AFTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			staleAfter = d
+		} else {
+			log.Warnf("解析 USAGE_STALE_AFTER 失败，使用默认值 %s，错误: %v", defaultStaleAfter.String(), err)
+		}
+	}
+	defaultMaxWait := 5 * time.Second
+	maxWait := defaultMaxWait
+	if v := os.Getenv("SNAPSHOT_MAX_WAIT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			maxWait = d
+		} else {
+			log.Warnf("解析 SNAPSHOT_MAX_WAIT 失败，使用默认值 %s，错误: %v", defaultMaxWait.String(), err)
+		}
+	}
+	return &ResourceHandler{
+		Handler:             handler,
+		deptSnapshots:       newSnapshotCell[[]model.DeptResource](),
+		nodeSnapshots:       newSnapshotCell[model.NodeList](),
+		snapshotMaxWait:     maxWait,
+		cacheTTL:            ttl,
+		deptRefreshInterval: deptRefresh,
+		nodeRefreshInterval: nodeRefresh,
+		metrics:             newMetricsCache(newUsageProvider(handler), scrape, staleAfter),
+		podQueue:            workqueue.NewNamed("podEvents"),
+		nodeQueue:           workqueue.NewNamed("nodeEvents"),
+		eventWorkers:        workers,
+		rebuildQueue:        workqueue.NewNamedDelayingQueue("aggRebuild"),
+		rebuildInterval:     rebuild,
+		lastRebuild:         make(map[string]time.Time),
+		deptAgg:             make(map[string]*deptAggItem),
+		podRecords:          make(map[types.UID]podRecord),
+		podUIDs:             make(map[string]types.UID),
+		nodeAgg:             make(map[string]nodeRecord),
+		reservations:        newReservationStore(handler),
+		audit:               newAuditLogger(),
+		costs:               newCostLedger(),
+	}
 }
 
+// ComputeDeptResourceQuotaLimit 检查本次申请是否超过部门配额：
+// 按节点类型逐项校验 cpu/memory/pods，请求非法返回 400，校验未通过返回 400 且 success=false，
+// 两种情况下 checks 都给出每一项的配额、已用、已预留、申请、剩余额度与结论；
+// 请求带 reserve 且校验通过时同时预留申请量，响应中返回预留 ID；启用部门授权时部门对调用方不可见返回 403
 func (h *ResourceHandler) ComputeDeptResourceQuotaLimit(c *gin.Context) {
 	var req model.DeptResourceQuotaRequest
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	deptResourceQuota := h.Handler.Informers[DeptResourceQuotaInformer].(*informer.DeptResourceQuotaInformer).GetDeptResourceQuotaByName(req.Dept)
-	if deptResourceQuota == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "DeptResourceQuota not found"})
+	requests, err := parseQuotaRequest(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
 		return
 	}
-
-	/*// 比较 Spec.Resources.Limits.Memory 与 Status.NonXcMemory
-	if cmpResult := deptResourceQuota.Spec.Resources.NonXcResources.Limits.Memory().Cmp(deptResourceQuota.Status.UsedResources.UsedNonXcResource.Limits.Memory().DeepCopy()); cmpResult < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "reason": "Non-XC memory usage exceeds limit"})
+	ttl, err := parseReserveOptions(req.Reserve)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: err.Error()})
 		return
 	}
-
-	// 比较 Spec.XcResources.Limits.Memory 与 Status.XcMemory
-	kylinArmMemory := deptResourceQuota.Status.UsedResources.UsedXcResource.ArmResource.Limits.Memory()
-	if cmpResult := deptResourceQuota.Spec.Resources.XcResources.ArmResource.Limits.Memory().Cmp(kylinArmMemory.DeepCopy()); cmpResult < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "reason": "XC memory usage exceeds limit"})
+	if !authz.ScopeFrom(c.Request.Context()).Allows(req.Dept) {
+		authz.Forbidden(c, "department "+req.Dept+" is not visible to the caller")
 		return
-	}*/
-
-	requestNonXcMem := resource.MustParse(req.RequestNonXcMemory)
-	requestKylinArmMem := resource.MustParse(req.RequestKylinArmMemory)
-	requestKylinX86Mem := resource.MustParse(req.RequestKylinHgMemory)
-
-	// 将请求中的 memory 和 xcMemory 分别加到 Status 中对应的字段
-	newNonXcMemory := deptResourceQuota.Status.UsedResources.UsedNonXcResource.Limits.Memory().DeepCopy()
-	newNonXcMemory.Add(requestNonXcMem)
-
-	newKylinArmMemory := deptResourceQuota.Status.UsedResources.UsedXcResource.ArmResource.Limits.Memory().DeepCopy()
-	newKylinArmMemory.Add(requestKylinArmMem)
-
-	newKylinX86Memory := deptResourceQuota.Status.UsedResources.UsedXcResource.HgResource.Limits.Memory().DeepCopy()
-	newKylinX86Memory.Add(requestKylinX86Mem)
-
-	// 比较 newNonXcMemory 与 Spec.Resources.Limits.Memory
-	if !requestNonXcMem.IsZero() {
-		if !deptResourceQuota.Spec.Resources.NonXcResources.Limits.Memory().IsZero() || !newNonXcMemory.IsZero() {
-			if cmpResult := deptResourceQuota.Spec.Resources.NonXcResources.Limits.Memory().Cmp(newNonXcMemory); cmpResult <= 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"success": false, "reason": "After adding request, non-XC memory usage exceeds limit"})
-				return
-			}
-		}
 	}
 
-	if !requestKylinArmMem.IsZero() {
-		// 比较 newXcMemory 与 Spec.XcResources.Limits.Memory
-		if !deptResourceQuota.Spec.Resources.XcResources.ArmResource.Limits.Memory().IsZero() || !newKylinArmMemory.IsZero() {
-			if cmpResult := deptResourceQuota.Spec.Resources.XcResources.ArmResource.Limits.Memory().Cmp(newKylinArmMemory); cmpResult <= 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"success": false, "reason": "After adding request, kylin arm memory usage exceeds limit"})
-				return
-			}
-		}
+	deptResourceQuota := h.Handler.Informers[DeptResourceQuotaInformer].(*informer.DeptResourceQuotaInformer).GetDeptResourceQuotaByName(req.Dept)
+	if deptResourceQuota == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "DeptResourceQuota not found"})
+		return
 	}
 
-	if !requestKylinX86Mem.IsZero() {
-		if !deptResourceQuota.Spec.Resources.XcResources.HgResource.Limits.Memory().IsZero() || !newKylinX86Memory.IsZero() {
-			if cmpResult := deptResourceQuota.Spec.Resources.XcResources.HgResource.Limits.Memory().Cmp(newKylinX86Memory); cmpResult <= 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"success": false, "reason": "After adding request, kylin hg x86 memory usage exceeds limit"})
-				return
-			}
-		}
+	resp := h.checkAndReserve(deptResourceQuota, req.Dept, requests, req.Reserve, ttl, nil)
+	status := http.StatusOK
+	if !resp.Success {
+		log.WithContext(c.Request.Context()).WithField(logging.FieldDept, req.Dept).Infof("部门配额校验未通过: %s", resp.Reason)
+		status = http.StatusBadRequest
 	}
-
-	// 如果所有检查都通过
-	c.JSON(http.StatusOK, gin.H{"success": true})
+	h.audit.Record(c, status, decisionRecord(audit.ActionCheckLimit, req.Dept, "", req, resp))
+	c.JSON(status, resp)
 }
 
 // DeptResources 返回部门资源，支持通过查询参数控制缓存：
 // - refresh=true 强制同步重算
 // - maxAge=duration 覆盖默认 TTL
-// 响应头包含 X-Cache 与 X-Generated-At
+// - minVersion=n 最多等待 SNAPSHOT_MAX_WAIT 直到快照版本不低于 n
+// - format=json|yaml|csv|xlsx 或 Accept 请求头选择输出格式，CSV/XLSX 为扁平化的表格
+// 响应头包含 X-Cache、X-Snapshot-Version、X-Generated-At、ETag 与 Last-Modified，If-None-Match/If-Modified-Since 命中时返回 304
 func (h *ResourceHandler) DeptResources(c *gin.Context) {
-    refresh := c.Query("refresh") == "true"
-    maxAgeStr := c.Query("maxAge")
-    var maxAge *time.Duration
-    if maxAgeStr != "" {
-        if d, err := time.ParseDuration(maxAgeStr); err == nil {
-            maxAge = &d
-        }
-    }
+	refresh := c.Query("refresh") == "true"
+	maxAge := h.cacheTTL
+	if d, err := time.ParseDuration(c.Query("maxAge")); err == nil {
+		maxAge = d
+	}
+	minVersion, err := parseMinVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "minVersion must be a non-negative integer"})
+		return
+	}
+	format, ok := negotiateFormat(c)
+	if !ok {
+		return
+	}
 
-    var data []model.DeptResource
-    var cacheState string
-    if refresh {
-        data = h.buildDeptResourceFromAgg()
-        cacheState = "MISS"
-    } else {
-        if maxAge != nil && !h.deptResourceCacheTime.IsZero() && time.Since(h.deptResourceCacheTime) > *maxAge {
-            data = h.buildDeptResourceFromAgg()
-            cacheState = "MISS"
-        } else if len(h.deptResourceCache) > 0 && !h.deptResourceCacheTime.IsZero() && time.Since(h.deptResourceCacheTime) < h.cacheTTL {
-            data = h.deptResourceCache
-            cacheState = "HIT"
-        } else {
-            data = h.buildDeptResourceFromAgg()
-            cacheState = "MISS"
-        }
-    }
-    c.Header("X-Cache", cacheState)
-    if !h.deptResourceCacheTime.IsZero() {
-        c.Header("X-Generated-At", h.deptResourceCacheTime.Format(time.RFC3339))
-    }
-    c.JSON(http.StatusOK, data)
+	snap := h.deptSnapshots.load()
+	if minVersion > 0 {
+		snap = h.deptSnapshots.wait(c.Request.Context(), minVersion, h.snapshotMaxWait)
+	}
+	cacheState := "HIT"
+	if refresh || snap == nil || len(snap.data) == 0 || snap.age() >= maxAge {
+		snap = h.rebuildDeptSnapshot(c.Request.Context())
+		cacheState = "MISS"
+	}
+	setSnapshotHeaders(c, cacheState, snap)
+	scope := authz.ScopeFrom(c.Request.Context())
+	if notModified(c, snapshotETag(formatTag("dept", format), snap, scope), snap.generated) {
+		return
+	}
+	data := snap.data
+	// 启用授权时只返回调用方可见的部门，快照是共享的，过滤时复制
+	if !scope.Admin() {
+		visible := make([]model.DeptResource, 0, len(data))
+		for _, dept := range data {
+			if scope.Allows(dept.Name) {
+				visible = append(visible, dept)
+			}
+		}
+		data = visible
+	}
+	renderReport(c, format, "dept-resources", data, func() export.Table { return deptTable(data) })
 }
 
 // GetDeptResource 聚合并返回部门资源：
@@ -292,96 +310,308 @@ func (h *ResourceHandler) DeptResources(c *gin.Context) {
 // 3) 使用 PodInformer 本地缓存一次遍历所有 Pod，按 department 标签聚合内存用量与 Pod 数
 // 4) 合并部门配额对象的限额与已宣布用量，生成响应并写入缓存
 func (h *ResourceHandler) GetDeptResource() []model.DeptResource {
-    if len(h.deptResourceCache) > 0 && !h.deptResourceCacheTime.IsZero() && time.Since(h.deptResourceCacheTime) < h.cacheTTL {
-        return h.deptResourceCache
-    }
-    data := h.buildDeptResourceFromAgg()
-    h.deptResourceCache = data
-    h.deptResourceCacheTime = time.Now()
-    return data
+	if snap := h.deptSnapshots.load(); snap != nil && len(snap.data) > 0 && snap.age() < h.cacheTTL {
+		return snap.data
+	}
+	return h.rebuildDeptSnapshot(context.Background()).data
 }
 
-// RecomputeDeptResource 强制重算部门资源并更新缓存
-func (h *ResourceHandler) RecomputeDeptResource() []model.DeptResource {
-    var deptResource []model.DeptResource
-    pms, err := h.Handler.metricsClient.MetricsV1beta1().PodMetricses("").List(context.TODO(), metaV1.ListOptions{})
-    if err != nil {
-        log.Errorf("获取pod资源信息失败: %v", err)
-    }
-    // 指标映射，键为 namespace/name，避免跨命名空间同名覆盖
-    metricsMap := make(map[string]metrics.PodMetrics)
-	for _, pm := range pms.Items {
-		key := pm.Namespace + "/" + pm.Name
-		metricsMap[key] = pm
-	}
+// rebuildDeptSnapshot 由增量聚合构建并发布新的部门快照
+func (h *ResourceHandler) rebuildDeptSnapshot(ctx context.Context) *snapshot[[]model.DeptResource] {
+	h.recomputeMu.Lock()
+	defer h.recomputeMu.Unlock()
+	return h.deptSnapshots.publish(h.buildDeptResourceFromAgg(ctx))
+}
 
+// rebuildNodeSnapshot 由增量聚合构建并发布新的节点快照
+func (h *ResourceHandler) rebuildNodeSnapshot(ctx context.Context) *snapshot[model.NodeList] {
+	h.recomputeMu.Lock()
+	defer h.recomputeMu.Unlock()
+	return h.nodeSnapshots.publish(h.buildNodeListFromAgg(ctx))
+}
+
+// RecomputeDeptResource 不经增量聚合，从 PodInformer 缓存与指标缓存强制重算部门资源并发布快照。
+// 重算与增量路径共用 Pod 记录与部门聚合项，两者输出的字段与口径一致
+func (h *ResourceHandler) RecomputeDeptResource() []model.DeptResource {
+	// 用量读取指标缓存（来源不可用时为最近一次成功抓取的值），键为 namespace/name
+	usage := h.metrics.podUsages()
 	pods := h.Handler.Informers[PodInformer].(*informer.PodInformer).List()
-    // 部门聚合项：记录非信创/信创(Arm/X86)内存用量与 Pod 数
-    type aggItem struct {
-        nonXc resource.Quantity
-        arm   resource.Quantity
-        x86   resource.Quantity
-        pods  int
-    }
-	agg := make(map[string]*aggItem)
 
-	for _, pod := range pods {
-		dept := pod.Labels["department"]
-		if dept == "" {
-			continue
-		}
-		item, ok := agg[dept]
-		if !ok {
-			non := resource.MustParse("0Mi")
-			arm := resource.MustParse("0Mi")
-			x86 := resource.MustParse("0Mi")
-			item = &aggItem{nonXc: non, arm: arm, x86: x86, pods: 0}
-			agg[dept] = item
+	h.recomputeMu.Lock()
+	defer h.recomputeMu.Unlock()
+	_, _, agg := h.recomputeDeptAgg(pods, usage, nil)
+	return h.deptSnapshots.publish(h.buildDeptResources(context.Background(), agg)).data
+}
+
+// NodeResources 返回节点资源，支持通过查询参数控制缓存：
+// - refresh=true 强制同步重算
+// - maxAge=duration 覆盖默认 TTL
+// - minVersion=n 最多等待 SNAPSHOT_MAX_WAIT 直到快照版本不低于 n
+// - format=json|yaml|csv|xlsx 或 Accept 请求头选择输出格式，CSV/XLSX 为扁平化的表格
+// 响应头包含 X-Cache、X-Snapshot-Version、X-Generated-At、ETag 与 Last-Modified，If-None-Match/If-Modified-Since 命中时返回 304
+func (h *ResourceHandler) NodeResources(c *gin.Context) {
+	refresh := c.Query("refresh") == "true"
+	maxAge := h.cacheTTL
+	if d, err := time.ParseDuration(c.Query("maxAge")); err == nil {
+		maxAge = d
+	}
+	minVersion, err := parseMinVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "minVersion must be a non-negative integer"})
+		return
+	}
+	format, ok := negotiateFormat(c)
+	if !ok {
+		return
+	}
+
+	snap := h.nodeSnapshots.load()
+	if minVersion > 0 {
+		snap = h.nodeSnapshots.wait(c.Request.Context(), minVersion, h.snapshotMaxWait)
+	}
+	cacheState := "HIT"
+	if refresh || snap == nil || len(snap.data.Items) == 0 || snap.age() >= maxAge {
+		h.recomputeMu.Lock()
+		if len(h.nodeAgg) == 0 {
+			h.recomputeMu.Unlock()
+			h.RecomputeNodeResources()
+			snap = h.nodeSnapshots.load()
+		} else {
+			snap = h.nodeSnapshots.publish(h.buildNodeListFromAgg(c.Request.Context()))
+			h.recomputeMu.Unlock()
 		}
-		item.pods++
+		cacheState = "MISS"
+	}
+	setSnapshotHeaders(c, cacheState, snap)
+	if notModified(c, snapshotETag(formatTag("node", format), snap, authz.ScopeFrom(c.Request.Context())), snap.generated) {
+		return
+	}
+	data := snap.data
+	renderReport(c, format, "node-resources", data, func() export.Table { return nodeTable(data) })
+}
 
-		key := pod.Namespace + "/" + pod.Name
-		metric, mok := metricsMap[key]
-		if !mok {
-			continue
+func (h *ResourceHandler) SeedNodeAggFromInformer() {
+	nodes := h.Handler.Informers[NodeInformer].(*informer.NodeInformer).List()
+	for _, node := range nodes {
+		h.syncNode(node.Name)
+	}
+	h.rebuildNodeSnapshot(context.Background())
+}
+
+// RecomputeNodeResources 不经增量聚合，从 NodeInformer 缓存与指标缓存强制重算节点资源并发布快照
+func (h *ResourceHandler) RecomputeNodeResources() model.NodeList {
+	h.recomputeMu.Lock()
+	defer h.recomputeMu.Unlock()
+	var nodeList model.NodeList
+	nodes := h.Handler.Informers[NodeInformer].(*informer.NodeInformer).List()
+	// 用量读取指标缓存（来源不可用时为最近一次成功抓取的值）
+	m := h.metrics.nodeUsages()
+
+	byNode := h.nodePodTotals()
+	for _, node := range nodes {
+		var nodeType NodeType
+		name := node.Name
+		if strings.Contains(name, string(RedHatX86NodePrefix)) {
+			nodeType = NonXcNodeType
+		} else if strings.Contains(name, string(KylinArmNodePrefix)) {
+			nodeType = XcArmNodeType
+		} else if strings.Contains(name, string(KylinX86NodePrefix)) {
+			nodeType = XcX86NodeType
 		}
 
-		var isNonXc, isArm, isX86 bool
-		node := pod.Spec.NodeName
-		if strings.Contains(node, string(RedHatX86NodePrefix)) {
-			isNonXc = true
-		} else if strings.Contains(node, string(KylinArmNodePrefix)) {
-			isArm = true
-		} else if strings.Contains(node, string(KylinX86NodePrefix)) {
-			isX86 = true
+		t := byNode[name]
+		if t == nil {
+			t = &podTotals{}
 		}
+		nodeMetrics, _ := m[name]
+		var usedCPU, usedMem string
+		if nodeMetrics != nil {
+			if cq := nodeMetrics.Cpu(); cq != nil {
+				usedCPU = cq.String()
+			} else {
+				usedCPU = "0"
+			}
+			if mq := nodeMetrics.Memory(); mq != nil {
+				usedMem = mq.String()
+			} else {
+				usedMem = "0"
+			}
+		} else {
+			usedCPU = "0"
+			usedMem = "0"
+		}
+
+		nodeList.Items = append(nodeList.Items, model.Node{
+			Name: name,
+			Type: string(nodeType),
+			Allocatable: map[string]string{
+				"cpu":    node.Status.Allocatable.Cpu().String(),
+				"memory": node.Status.Allocatable.Memory().String(),
+			},
+			Used: map[string]string{
+				"cpu":    usedCPU,
+				"memory": usedMem,
+			},
+			Requests: computeStrings(t.requests),
+			Limits:   computeStrings(t.limits),
+		})
+	}
+	sort.Slice(nodeList.Items, func(i, j int) bool { return nodeList.Items[i].Name < nodeList.Items[j].Name })
+	return h.nodeSnapshots.publish(nodeList).data
+}
 
-		for _, c := range metric.Containers {
-			mem := c.Usage.Memory()
-			if mem == nil {
-				continue
+// 待重建的缓存
+const (
+	rebuildDept = "dept"
+	rebuildNode = "node"
+)
+
+// EnableEventDrivenInvalidation 注册 Pod/节点事件：事件只把对象 key 放入队列，
+// 由 worker 从 informer 缓存读取对象、从指标缓存读取用量后更新增量聚合，再按最小间隔重建查询缓存
+func (h *ResourceHandler) EnableEventDrivenInvalidation() {
+	podInf := h.Handler.Informers[PodInformer].(*informer.PodInformer)
+	nodeInf := h.Handler.Informers[NodeInformer].(*informer.NodeInformer)
+
+	h.metrics.scrape()
+	_ = podInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			enqueueKey(h.podQueue, obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// 周期性 resync 的对象没有变化，用量由 StartUsageRefresh 定期刷新
+			oldPod, _ := informer.PodFromObject(oldObj)
+			newPod, ok := informer.PodFromObject(newObj)
+			if ok && oldPod != nil && oldPod.ResourceVersion == newPod.ResourceVersion {
+				return
 			}
-			if isNonXc {
-				item.nonXc.Add(*mem)
-			} else if isArm {
-				item.arm.Add(*mem)
-			} else if isX86 {
-				item.x86.Add(*mem)
+			enqueueKey(h.podQueue, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			enqueueKey(h.podQueue, obj)
+		},
+	})
+	_ = nodeInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			enqueueKey(h.nodeQueue, obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, _ := informer.NodeFromObject(oldObj)
+			newNode, ok := informer.NodeFromObject(newObj)
+			if ok && oldNode != nil && oldNode.ResourceVersion == newNode.ResourceVersion {
+				return
 			}
+			enqueueKey(h.nodeQueue, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			enqueueKey(h.nodeQueue, obj)
+		},
+	})
+
+	stopCh := h.Handler.stopCh
+	for i := 0; i < h.eventWorkers; i++ {
+		go wait.Until(func() { runQueue(h.podQueue, h.syncPod) }, time.Second, stopCh)
+		go wait.Until(func() { runQueue(h.nodeQueue, h.syncNode) }, time.Second, stopCh)
+	}
+	go wait.Until(func() { runQueue(h.rebuildQueue, h.rebuild) }, time.Second, stopCh)
+	go func() {
+		<-stopCh
+		h.podQueue.ShutDown()
+		h.nodeQueue.ShutDown()
+		h.rebuildQueue.ShutDown()
+	}()
+}
+
+// enqueueKey 把对象 key 放入队列，删除事件的 tombstone（DeletedFinalStateUnknown）也能取到 key；
+// worker 按 key 从缓存读取当前状态，漏掉的删除事件在 relist 后同样按 key 处理
+func enqueueKey(queue workqueue.Interface, obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		log.Errorf("获取对象 key 失败: %v", err)
+		return
+	}
+	queue.Add(key)
+}
+
+// runQueue 循环处理队列直到队列关闭
+func runQueue(queue workqueue.Interface, sync func(key string)) {
+	for {
+		item, quit := queue.Get()
+		if quit {
+			return
 		}
+		sync(item.(string))
+		queue.Done(item)
+	}
+}
+
+// scheduleRebuild 安排重建查询缓存：距上次重建已超过最小间隔时立即重建，否则延迟到满足间隔，
+// 期间的多次调用在队列中合并为一次
+func (h *ResourceHandler) scheduleRebuild(kind string) {
+	h.rebuildMu.Lock()
+	last := h.lastRebuild[kind]
+	h.rebuildMu.Unlock()
+	if remaining := h.rebuildInterval - time.Since(last); remaining > 0 {
+		h.rebuildQueue.AddAfter(kind, remaining)
+		return
+	}
+	h.rebuildQueue.Add(kind)
+}
+
+// rebuild 根据增量聚合重建部门或节点快照（构建与发布都持有 recomputeMu，避免与事件写入并发）
+func (h *ResourceHandler) rebuild(kind string) {
+	ctx, span := tracing.Start(context.Background(), "rebuild."+kind)
+	defer span.End()
+	start := time.Now()
+	switch kind {
+	case rebuildDept:
+		h.rebuildDeptSnapshot(ctx)
+	case rebuildNode:
+		h.rebuildNodeSnapshot(ctx)
 	}
+	aggRebuildDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+	h.rebuildMu.Lock()
+	h.lastRebuild[kind] = time.Now()
+	h.rebuildMu.Unlock()
+}
 
-	for _, deptRscQuota := range h.Handler.Informers[DeptResourceQuotaInformer].(*informer.DeptResourceQuotaInformer).List() {
+// buildDeptResourceFromAgg 由增量聚合生成部门资源，调用方需持有 recomputeMu
+func (h *ResourceHandler) buildDeptResourceFromAgg(ctx context.Context) []model.DeptResource {
+	return h.buildDeptResources(ctx, h.deptAgg)
+}
+
+// buildDeptResources 合并部门配额对象与部门聚合 agg 生成部门资源，调用方需持有 recomputeMu
+func (h *ResourceHandler) buildDeptResources(ctx context.Context, agg map[string]*deptAggItem) []model.DeptResource {
+	ctx, span := tracing.Start(ctx, "aggregate.dept")
+	defer span.End()
+	var deptResource []model.DeptResource
+	for _, deptRscQuota := range h.Handler.Informers[DeptResourceQuotaInformer].(*informer.DeptResourceQuotaInformer).ListContext(ctx) {
 		a := agg[deptRscQuota.Spec.DeptName]
 		var used model.UsedResource
+		var pending model.PendingResource
+		requests := classComputeResources(nil)
+		limits := classComputeResources(nil)
 		if a != nil {
-			used.NonXc.Memory = a.nonXc.String()
-			used.XC.Arm.Memory = a.arm.String()
-			used.XC.X86.Memory = a.x86.String()
+			used.NonXc.Memory = memoryString(a.usage[NonXcNodeType])
+			used.XC.Arm.Memory = memoryString(a.usage[XcArmNodeType])
+			used.XC.X86.Memory = memoryString(a.usage[XcX86NodeType])
+			used.NonXc.Cpu = cpuString(a.usage[NonXcNodeType])
+			used.XC.Arm.Cpu = cpuString(a.usage[XcArmNodeType])
+			used.XC.X86.Cpu = cpuString(a.usage[XcX86NodeType])
+			requests = classComputeResources(a.requests)
+			limits = classComputeResources(a.limits)
+			pending.NonXc = a.pendingDemand(NonXcNodeType)
+			pending.XC.Arm = a.pendingDemand(XcArmNodeType)
+			pending.XC.X86 = a.pendingDemand(XcX86NodeType)
 		} else {
 			used.NonXc.Memory = "0Mi"
 			used.XC.Arm.Memory = "0Mi"
 			used.XC.X86.Memory = "0Mi"
+			used.NonXc.Cpu = "0"
+			used.XC.Arm.Cpu = "0"
+			used.XC.X86.Cpu = "0"
+			pending.NonXc.Memory = "0Mi"
+			pending.XC.Arm.Memory = "0Mi"
+			pending.XC.X86.Memory = "0Mi"
 		}
 
 		deptResource = append(deptResource, model.DeptResource{
@@ -412,573 +642,132 @@ func (h *ResourceHandler) RecomputeDeptResource() []model.DeptResource {
 					},
 				},
 			},
-			Used: used,
+			Used:     used,
+			Requests: requests,
+			Limits:   limits,
 			Pods: func() int {
 				if a != nil {
 					return a.pods
 				}
 				return 0
 			}(),
+			Pending: pending,
 		})
 	}
-
-	h.deptResourceCache = deptResource
-	h.deptResourceCacheTime = time.Now()
+	sort.Slice(deptResource, func(i, j int) bool { return deptResource[i].Name < deptResource[j].Name })
 	return deptResource
 }
 
-// NodeResources 返回节点资源，支持通过查询参数控制缓存：
-// - refresh=true 强制同步重算
-// - maxAge=duration 覆盖默认 TTL
-// 响应头包含 X-Cache 与 X-Generated-At
-func (h *ResourceHandler) NodeResources(c *gin.Context) {
-    refresh := c.Query("refresh") == "true"
-    maxAgeStr := c.Query("maxAge")
-    var maxAge *time.Duration
-    if maxAgeStr != "" {
-        if d, err := time.ParseDuration(maxAgeStr); err == nil {
-            maxAge = &d
-        }
-    }
-
-    if !refresh && maxAge == nil && !h.nodeResourceCacheTime.IsZero() && time.Since(h.nodeResourceCacheTime) < h.cacheTTL && len(h.nodeResourceCache.Items) > 0 {
-        c.Header("X-Cache", "HIT")
-        c.Header("X-Generated-At", h.nodeResourceCacheTime.Format(time.RFC3339))
-        c.JSON(http.StatusOK, h.nodeResourceCache)
-        return
-    }
-
-    h.recomputeMu.Lock()
-    aggEmpty := len(h.nodeAgg) == 0
-    h.recomputeMu.Unlock()
-
-    var data model.NodeList
-    if aggEmpty {
-        data = h.RecomputeNodeResources()
-    } else {
-        data = h.buildNodeListFromAgg()
-        h.nodeResourceCache = data
-        h.nodeResourceCacheTime = time.Now()
-    }
-    c.Header("X-Cache", "MISS")
-    c.Header("X-Generated-At", h.nodeResourceCacheTime.Format(time.RFC3339))
-    c.JSON(http.StatusOK, data)
-}
-
-func (h *ResourceHandler) SeedNodeAggFromInformer() {
-    nodes := h.Handler.Informers[NodeInformer].(*informer.NodeInformer).List()
-    for _, node := range nodes {
-        h.onNodeAdd(node)
-    }
-    h.recomputeMu.Lock()
-    h.nodeResourceCache = h.buildNodeListFromAgg()
-    h.nodeResourceCacheTime = time.Now()
-    h.recomputeMu.Unlock()
-}
-
-// RecomputeNodeResources 强制重算节点资源并更新缓存
-func (h *ResourceHandler) RecomputeNodeResources() model.NodeList {
-    var nodeList model.NodeList
-    nodes := h.Handler.Informers[NodeInformer].(*informer.NodeInformer).List()
-    m := make(map[string]v1.ResourceList)
-
-    nodeMetricsList, err := h.Handler.metricsClient.MetricsV1beta1().NodeMetricses().List(context.TODO(), metaV1.ListOptions{})
-    if err != nil {
-        log.Errorf("获取节点资源失败，错误原因:%v", err)
-    }
-    for _, node := range nodeMetricsList.Items {
-        m[node.Name] = node.Usage
-    }
-
-    for _, node := range nodes {
-        var nodeType NodeType
-        name := node.Name
-        if strings.Contains(name, string(RedHatX86NodePrefix)) {
-            nodeType = NonXcNodeType
-        } else if strings.Contains(name, string(KylinArmNodePrefix)) {
-            nodeType = XcArmNodeType
-        } else if strings.Contains(name, string(KylinX86NodePrefix)) {
-            nodeType = XcX86NodeType
-        }
-
-        nodeMetrics, _ := m[name]
-        var usedCPU, usedMem string
-        if nodeMetrics != nil {
-            if cq := nodeMetrics.Cpu(); cq != nil {
-                usedCPU = cq.String()
-            } else {
-                usedCPU = "0"
-            }
-            if mq := nodeMetrics.Memory(); mq != nil {
-                usedMem = mq.String()
-            } else {
-                usedMem = "0"
-            }
-        } else {
-            usedCPU = "0"
-            usedMem = "0"
-        }
-
-        nodeList.Items = append(nodeList.Items, model.Node{
-            Name: name,
-            Type: string(nodeType),
-            Allocatable: map[string]string{
-                "cpu":    node.Status.Allocatable.Cpu().String(),
-                "memory": node.Status.Allocatable.Memory().String(),
-            },
-            Used: map[string]string{
-                "cpu":    usedCPU,
-                "memory": usedMem,
-            },
-        })
-    }
-    h.nodeResourceCache = nodeList
-    h.nodeResourceCacheTime = time.Now()
-    return nodeList
-}
-
-func (h *ResourceHandler) EnableEventDrivenInvalidation() {
-    podInf := h.Handler.Informers[PodInformer].(*informer.PodInformer)
-    nodeInf := h.Handler.Informers[NodeInformer].(*informer.NodeInformer)
-
-    _ = podInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
-        AddFunc: func(obj interface{}) {
-            pod := obj.(*v1.Pod)
-            h.onPodAdd(pod)
-        },
-        UpdateFunc: func(oldObj, newObj interface{}) {
-            oldPod := oldObj.(*v1.Pod)
-            newPod := newObj.(*v1.Pod)
-            h.onPodUpdate(oldPod, newPod)
-        },
-        DeleteFunc: func(obj interface{}) {
-            pod := obj.(*v1.Pod)
-            h.onPodDelete(pod)
-        },
-    })
-    _ = nodeInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
-        AddFunc: func(obj interface{}) {
-            node := obj.(*v1.Node)
-            h.onNodeAdd(node)
-        },
-        UpdateFunc: func(oldObj, newObj interface{}) {
-            node := newObj.(*v1.Node)
-            h.onNodeUpdate(node)
-        },
-        DeleteFunc: func(obj interface{}) {
-            node := obj.(*v1.Node)
-            h.onNodeDelete(node)
-        },
-    })
-
-    go h.deptWorker()
-    go h.nodeWorker()
-}
-
-func (h *ResourceHandler) triggerDeptEvent() {
-    select {
-    case h.deptEvents <- struct{}{}:
-    default:
-    }
-}
-
-func (h *ResourceHandler) triggerNodeEvent() {
-    select {
-    case h.nodeEvents <- struct{}{}:
-    default:
-    }
-}
-
-func (h *ResourceHandler) deptWorker() {
-    for {
-        <-h.deptEvents
-        timer := time.NewTimer(500 * time.Millisecond)
-        drain := true
-        for drain {
-            select {
-            case <-h.deptEvents:
-                continue
-            case <-timer.C:
-                drain = false
-            }
-        }
-        // 合并事件后根据增量聚合构建缓存（读取需加锁以避免与事件写入并发）
-        h.recomputeMu.Lock()
-        h.deptResourceCache = h.buildDeptResourceFromAgg()
-        h.deptResourceCacheTime = time.Now()
-        h.recomputeMu.Unlock()
-    }
-}
-
-func (h *ResourceHandler) nodeWorker() {
-    for {
-        <-h.nodeEvents
-        timer := time.NewTimer(500 * time.Millisecond)
-        drain := true
-        for drain {
-            select {
-            case <-h.nodeEvents:
-                continue
-            case <-timer.C:
-                drain = false
-            }
-        }
-        // 合并事件后根据增量聚合构建缓存（读取需加锁以避免与事件写入并发）
-        h.recomputeMu.Lock()
-        h.nodeResourceCache = h.buildNodeListFromAgg()
-        h.nodeResourceCacheTime = time.Now()
-        h.recomputeMu.Unlock()
-    }
+// buildNodeListFromAgg 由增量聚合生成节点资源，调用方需持有 recomputeMu
+func (h *ResourceHandler) buildNodeListFromAgg(ctx context.Context) model.NodeList {
+	_, span := tracing.Start(ctx, "aggregate.node")
+	defer span.End()
+	var nodeList model.NodeList
+	byNode := h.nodePodTotals()
+	for name, rec := range h.nodeAgg {
+		t := byNode[name]
+		if t == nil {
+			t = &podTotals{}
+		}
+		nodeList.Items = append(nodeList.Items, model.Node{
+			Name: name,
+			Type: string(rec.nodeType),
+			Allocatable: map[string]string{
+				"cpu":    rec.allocatable.Cpu().String(),
+				"memory": rec.allocatable.Memory().String(),
+			},
+			Used: map[string]string{
+				"cpu":    rec.usage.Cpu().String(),
+				"memory": rec.usage.Memory().String(),
+			},
+			Requests: computeStrings(t.requests),
+			Limits:   computeStrings(t.limits),
+		})
+	}
+	// nodeAgg 为 map，按节点名排序保证每次输出顺序一致
+	sort.Slice(nodeList.Items, func(i, j int) bool { return nodeList.Items[i].Name < nodeList.Items[j].Name })
+	return nodeList
 }
 
-func (h *ResourceHandler) buildDeptResourceFromAgg() []model.DeptResource {
-    var deptResource []model.DeptResource
-    for _, deptRscQuota := range h.Handler.Informers[DeptResourceQuotaInformer].(*informer.DeptResourceQuotaInformer).List() {
-        a := h.deptAgg[deptRscQuota.Spec.DeptName]
-        var used model.UsedResource
-        if a != nil {
-            used.NonXc.Memory = a.nonXc.String()
-            used.XC.Arm.Memory = a.arm.String()
-            used.XC.X86.Memory = a.x86.String()
-        } else {
-            used.NonXc.Memory = "0Mi"
-            used.XC.Arm.Memory = "0Mi"
-            used.XC.X86.Memory = "0Mi"
-        }
-
-        deptResource = append(deptResource, model.DeptResource{
-            Name: deptRscQuota.Spec.DeptName,
-            Resources: model.Resources{
-                NonXc: model.ResourceQuotas{
-                    Limits: model.ResourceLimits{Memory: deptRscQuota.Spec.Resources.NonXcResources.Limits.Memory().String()},
-                },
-                XC: model.SubResource{
-                    X86: model.ResourceQuotas{
-                        Limits: model.ResourceLimits{Memory: deptRscQuota.Spec.Resources.XcResources.HgResource.Limits.Memory().String()},
-                    },
-                    Arm: model.ResourceQuotas{
-                        Limits: model.ResourceLimits{Memory: deptRscQuota.Spec.Resources.XcResources.ArmResource.Limits.Memory().String()},
-                    },
-                },
-            },
-            Announced: model.Announced{
-                NonXc: model.ResourceQuotas{
-                    Limits: model.ResourceLimits{Memory: deptRscQuota.Status.UsedResources.UsedNonXcResource.Limits.Memory().String()},
-                },
-                XC: model.SubResource{
-                    X86: model.ResourceQuotas{
-                        Limits: model.ResourceLimits{Memory: deptRscQuota.Status.UsedResources.UsedXcResource.HgResource.Limits.Memory().String()},
-                    },
-                    Arm: model.ResourceQuotas{
-                        Limits: model.ResourceLimits{Memory: deptRscQuota.Status.UsedResources.UsedXcResource.ArmResource.Limits.Memory().String()},
-                    },
-                },
-            },
-            Used: used,
-            Pods: func() int { if a != nil { return a.pods } ; return 0 }(),
-        })
-    }
-    return deptResource
+// cpuString 返回 cpu 用量字符串，缺失时为 0
+func cpuString(list v1.ResourceList) string {
+	if q, ok := list[v1.ResourceCPU]; ok {
+		return q.String()
+	}
+	return "0"
 }
 
-func (h *ResourceHandler) buildNodeListFromAgg() model.NodeList {
-    var nodeList model.NodeList
-    for name, rec := range h.nodeAgg {
-        nodeList.Items = append(nodeList.Items, model.Node{
-            Name: name,
-            Type: string(rec.nodeType),
-            Allocatable: map[string]string{
-                "cpu":    rec.allocCPU,
-                "memory": rec.allocMem,
-            },
-            Used: map[string]string{
-                "cpu":    rec.usedCPU,
-                "memory": rec.usedMem,
-            },
-        })
-    }
-    return nodeList
+// memoryString 返回用量中的内存，缺失时为 0Mi
+func memoryString(list v1.ResourceList) string {
+	if q, ok := list[v1.ResourceMemory]; ok {
+		return q.String()
+	}
+	return "0Mi"
 }
 
 func (h *ResourceHandler) archOf(nodeName string) NodeType {
-    if strings.Contains(nodeName, string(RedHatX86NodePrefix)) {
-        return NonXcNodeType
-    }
-    if strings.Contains(nodeName, string(KylinArmNodePrefix)) {
-        return XcArmNodeType
-    }
-    if strings.Contains(nodeName, string(KylinX86NodePrefix)) {
-        return XcX86NodeType
-    }
-    return NonXcNodeType
-}
-
-func (h *ResourceHandler) getPodMem(ns, name string) (resource.Quantity, bool) {
-    pm, err := h.Handler.metricsClient.MetricsV1beta1().PodMetricses(ns).Get(context.TODO(), name, metaV1.GetOptions{})
-    if err != nil {
-        return resource.MustParse("0Mi"), false
-    }
-    total := resource.MustParse("0Mi")
-    for _, c := range pm.Containers {
-        mem := c.Usage.Memory()
-        if mem != nil {
-            total.Add(*mem)
-        }
-    }
-    return total, true
-}
-
-func (h *ResourceHandler) onPodAdd(pod *v1.Pod) {
-    // 无部门标签直接跳过
-    dept := pod.Labels["department"]
-    if dept == "" { return }
-    // 指标不可用时按 0 记录，但仍计入 Pod 数
-    mem, ok := h.getPodMem(pod.Namespace, pod.Name)
-    if !ok { mem = resource.MustParse("0Mi") }
-    arch := h.archOf(pod.Spec.NodeName)
-    key := pod.Namespace + "/" + pod.Name
-    h.recomputeMu.Lock()
-    a := h.deptAgg[dept]
-    if a == nil {
-        non := resource.MustParse("0Mi")
-        arm := resource.MustParse("0Mi")
-        x86 := resource.MustParse("0Mi")
-        a = &struct{ nonXc, arm, x86 resource.Quantity; pods int }{non, arm, x86, 0}
-        h.deptAgg[dept] = a
-    }
-    switch arch {
-    case NonXcNodeType:
-        a.nonXc.Add(mem)
-    case XcArmNodeType:
-        a.arm.Add(mem)
-    case XcX86NodeType:
-        a.x86.Add(mem)
-    }
-    a.pods++
-    h.podRecords[key] = struct{ dept string; arch NodeType; mem resource.Quantity }{dept: dept, arch: arch, mem: mem}
-    h.recomputeMu.Unlock()
-    h.triggerDeptEvent()
-}
-
-func (h *ResourceHandler) onPodUpdate(oldPod, newPod *v1.Pod) {
-    // 读取新旧键与新属性
-    oldKey := oldPod.Namespace + "/" + oldPod.Name
-    newKey := newPod.Namespace + "/" + newPod.Name
-    newDept := newPod.Labels["department"]
-    newArch := h.archOf(newPod.Spec.NodeName)
-    newMem, ok := h.getPodMem(newPod.Namespace, newPod.Name)
-    h.recomputeMu.Lock()
-    defer h.recomputeMu.Unlock()
-    rec, exists := h.podRecords[oldKey]
-    if exists {
-        // 若新对象删除部门标签：扣减旧值并减少 Pod 数后退出
-        if newDept == "" {
-            if a := h.deptAgg[rec.dept]; a != nil {
-                switch rec.arch {
-                case NonXcNodeType:
-                    if a.nonXc.Cmp(rec.mem) >= 0 { a.nonXc.Sub(rec.mem) } else { a.nonXc = resource.MustParse("0Mi") }
-                case XcArmNodeType:
-                    if a.arm.Cmp(rec.mem) >= 0 { a.arm.Sub(rec.mem) } else { a.arm = resource.MustParse("0Mi") }
-                case XcX86NodeType:
-                    if a.x86.Cmp(rec.mem) >= 0 { a.x86.Sub(rec.mem) } else { a.x86 = resource.MustParse("0Mi") }
-                }
-                if a.pods > 0 { a.pods-- }
-            }
-            delete(h.podRecords, oldKey)
-            h.triggerDeptEvent()
-            return
-        }
-        oldMem := rec.mem
-        oldDept := rec.dept
-        oldArch := rec.arch
-        // 指标可用：扣旧加新，处理部门迁移的 Pod 数
-        if ok {
-            if a := h.deptAgg[oldDept]; a != nil {
-                switch oldArch {
-                case NonXcNodeType:
-                    if a.nonXc.Cmp(oldMem) >= 0 { a.nonXc.Sub(oldMem) } else { a.nonXc = resource.MustParse("0Mi") }
-                case XcArmNodeType:
-                    if a.arm.Cmp(oldMem) >= 0 { a.arm.Sub(oldMem) } else { a.arm = resource.MustParse("0Mi") }
-                case XcX86NodeType:
-                    if a.x86.Cmp(oldMem) >= 0 { a.x86.Sub(oldMem) } else { a.x86 = resource.MustParse("0Mi") }
-                }
-                if oldDept != newDept { if a.pods > 0 { a.pods-- } }
-            }
-            a2 := h.deptAgg[newDept]
-            if a2 == nil {
-                non := resource.MustParse("0Mi")
-                arm := resource.MustParse("0Mi")
-                x86 := resource.MustParse("0Mi")
-                a2 = &struct{ nonXc, arm, x86 resource.Quantity; pods int }{non, arm, x86, 0}
-                h.deptAgg[newDept] = a2
-            }
-            switch newArch {
-            case NonXcNodeType:
-                a2.nonXc.Add(newMem)
-            case XcArmNodeType:
-                a2.arm.Add(newMem)
-            case XcX86NodeType:
-                a2.x86.Add(newMem)
-            }
-            if oldDept != newDept { a2.pods++ }
-            // 更新记录为新值
-            delete(h.podRecords, oldKey)
-            h.podRecords[newKey] = struct{ dept string; arch NodeType; mem resource.Quantity }{dept: newDept, arch: newArch, mem: newMem}
-        } else {
-            // 指标不可用：保持旧内存，若部门/架构变化则按旧值搬迁
-            if a := h.deptAgg[oldDept]; a != nil {
-                switch oldArch {
-                case NonXcNodeType:
-                    if a.nonXc.Cmp(oldMem) >= 0 { a.nonXc.Sub(oldMem) } else { a.nonXc = resource.MustParse("0Mi") }
-                case XcArmNodeType:
-                    if a.arm.Cmp(oldMem) >= 0 { a.arm.Sub(oldMem) } else { a.arm = resource.MustParse("0Mi") }
-                case XcX86NodeType:
-                    if a.x86.Cmp(oldMem) >= 0 { a.x86.Sub(oldMem) } else { a.x86 = resource.MustParse("0Mi") }
-                }
-                if oldDept != newDept { if a.pods > 0 { a.pods-- } }
-            }
-            a2 := h.deptAgg[newDept]
-            if a2 == nil {
-                non := resource.MustParse("0Mi")
-                arm := resource.MustParse("0Mi")
-                x86 := resource.MustParse("0Mi")
-                a2 = &struct{ nonXc, arm, x86 resource.Quantity; pods int }{non, arm, x86, 0}
-                h.deptAgg[newDept] = a2
-            }
-            switch newArch {
-            case NonXcNodeType:
-                a2.nonXc.Add(oldMem)
-            case XcArmNodeType:
-                a2.arm.Add(oldMem)
-            case XcX86NodeType:
-                a2.x86.Add(oldMem)
-            }
-            if oldDept != newDept { a2.pods++ }
-            // 记录仍保留旧内存值
-            delete(h.podRecords, oldKey)
-            h.podRecords[newKey] = struct{ dept string; arch NodeType; mem resource.Quantity }{dept: newDept, arch: newArch, mem: oldMem}
-        }
-    } else {
-        // 无旧记录：按新增逻辑处理
-        if newDept == "" { h.triggerDeptEvent(); return }
-        if !ok { newMem = resource.MustParse("0Mi") }
-        a2 := h.deptAgg[newDept]
-        if a2 == nil {
-            non := resource.MustParse("0Mi")
-            arm := resource.MustParse("0Mi")
-            x86 := resource.MustParse("0Mi")
-            a2 = &struct{ nonXc, arm, x86 resource.Quantity; pods int }{non, arm, x86, 0}
-            h.deptAgg[newDept] = a2
-        }
-        switch newArch {
-        case NonXcNodeType:
-            a2.nonXc.Add(newMem)
-        case XcArmNodeType:
-            a2.arm.Add(newMem)
-        case XcX86NodeType:
-            a2.x86.Add(newMem)
-        }
-        a2.pods++
-        h.podRecords[newKey] = struct{ dept string; arch NodeType; mem resource.Quantity }{dept: newDept, arch: newArch, mem: newMem}
-    }
-    h.triggerDeptEvent()
-}
-
-func (h *ResourceHandler) onPodDelete(pod *v1.Pod) {
-    key := pod.Namespace + "/" + pod.Name
-    h.recomputeMu.Lock()
-    defer h.recomputeMu.Unlock()
-    rec, exists := h.podRecords[key]
-    if !exists { return }
-    a := h.deptAgg[rec.dept]
-    if a != nil {
-        switch rec.arch {
-        case NonXcNodeType:
-            if a.nonXc.Cmp(rec.mem) >= 0 { a.nonXc.Sub(rec.mem) } else { a.nonXc = resource.MustParse("0Mi") }
-        case XcArmNodeType:
-            if a.arm.Cmp(rec.mem) >= 0 { a.arm.Sub(rec.mem) } else { a.arm = resource.MustParse("0Mi") }
-        case XcX86NodeType:
-            if a.x86.Cmp(rec.mem) >= 0 { a.x86.Sub(rec.mem) } else { a.x86 = resource.MustParse("0Mi") }
-        }
-        if a.pods > 0 { a.pods-- }
-    }
-    delete(h.podRecords, key)
-    h.triggerDeptEvent()
-}
-
-func (h *ResourceHandler) onNodeAdd(node *v1.Node) {
-    name := node.Name
-    nodeType := h.archOf(name)
-    allocCPU := node.Status.Allocatable.Cpu().String()
-    allocMem := node.Status.Allocatable.Memory().String()
-    usedCPU := "0"
-    usedMem := "0"
-    nm, err := h.Handler.metricsClient.MetricsV1beta1().NodeMetricses().Get(context.TODO(), name, metaV1.GetOptions{})
-    if err == nil {
-        if cq := nm.Usage.Cpu(); cq != nil { usedCPU = cq.String() }
-        if mq := nm.Usage.Memory(); mq != nil { usedMem = mq.String() }
-    }
-    h.recomputeMu.Lock()
-    h.nodeAgg[name] = struct{ allocCPU, allocMem, usedCPU, usedMem string; nodeType NodeType }{allocCPU: allocCPU, allocMem: allocMem, usedCPU: usedCPU, usedMem: usedMem, nodeType: nodeType}
-    h.recomputeMu.Unlock()
-    h.triggerNodeEvent()
+	return archOfNodeName(nodeName)
 }
 
-func (h *ResourceHandler) onNodeUpdate(node *v1.Node) {
-    h.onNodeAdd(node)
-}
-
-func (h *ResourceHandler) onNodeDelete(node *v1.Node) {
-    name := node.Name
-    h.recomputeMu.Lock()
-    delete(h.nodeAgg, name)
-    h.recomputeMu.Unlock()
-    h.triggerNodeEvent()
+// archOfNodeName 按节点名前缀判断节点类型，无法识别时视为非信创节点
+func archOfNodeName(nodeName string) NodeType {
+	if strings.Contains(nodeName, string(RedHatX86NodePrefix)) {
+		return NonXcNodeType
+	}
+	if strings.Contains(nodeName, string(KylinArmNodePrefix)) {
+		return XcArmNodeType
+	}
+	if strings.Contains(nodeName, string(KylinX86NodePrefix)) {
+		return XcX86NodeType
+	}
+	return NonXcNodeType
 }
 
-// ClusterResources return the cluster resources(so far, only limits memory)
+// ClusterResources 按节点类型返回节点实时用量与已调度 Pod 的有效 requests/limits，
+// 旧字段 nonXcLimitsResources/xcLimitsResources 保留为 limits 内存
 func (h *ResourceHandler) ClusterResources(c *gin.Context) {
-	pods := h.Handler.Informers[PodInformer].(*informer.PodInformer).List()
-
-	nonXcQuantity := resource.MustParse("0Mi")
-	kylinArmQuantity := resource.MustParse("0Mi")
-	kylinX86Quantity := resource.MustParse("0Mi")
-
-	for _, pod := range pods {
-		for _, c := range pod.Spec.Containers {
-			if strings.Contains(pod.Spec.NodeName, string(RedHatX86NodePrefix)) {
-				nonXcQuantity.Add(c.Resources.Limits.Memory().DeepCopy())
-			} else if strings.Contains(pod.Spec.NodeName, string(KylinArmNodePrefix)) {
-				kylinArmQuantity.Add(c.Resources.Limits.Memory().DeepCopy())
-			} else if strings.Contains(pod.Spec.NodeName, string(KylinX86NodePrefix)) {
-				kylinX86Quantity.Add(c.Resources.Limits.Memory().DeepCopy())
-			}
-		}
+	totals := h.classPodTotals()
+	usage := make(map[NodeType]v1.ResourceList)
+	for _, rec := range h.nodeRecords() {
+		usage[rec.nodeType] = addResourceList(usage[rec.nodeType], rec.usage)
 	}
+	limits := totals.limitsByClass()
 
 	clusterResource := model.ClusterResource{
 		NonXcLimitsResources: map[string]string{
-			"memory": nonXcQuantity.String(),
+			"memory": memoryString(withComputeDefaults(limits[NonXcNodeType])),
 		},
 		XcLimitsResources: model.XcLimitsResources{
 			X86: map[string]string{
-				"memory": kylinX86Quantity.String(),
+				"memory": memoryString(withComputeDefaults(limits[XcX86NodeType])),
 			},
 			Arm: map[string]string{
-				"memory": kylinArmQuantity.String(),
+				"memory": memoryString(withComputeDefaults(limits[XcArmNodeType])),
 			},
 		},
+		Used:     classComputeResources(usage),
+		Requests: classComputeResources(totals.requestsByClass()),
+		Limits:   classComputeResources(limits),
 	}
 
 	c.JSON(http.StatusOK, clusterResource)
 }
 
+// EnvResources 返回部门下各环境（namespaceGroup 标签）按节点类型的实时用量与已调度 Pod 的有效 requests/limits；format 参数或 Accept 请求头选择输出格式
 func (h *ResourceHandler) EnvResources(c *gin.Context) {
 	dept := c.Query("dept")
 	if dept == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "dept query parameter is required"})
 		return
 	}
+	format, ok := negotiateFormat(c)
+	if !ok {
+		return
+	}
+	if !authz.ScopeFrom(c.Request.Context()).Allows(dept) {
+		authz.Forbidden(c, "department "+dept+" is not visible to the caller")
+		return
+	}
 
 	labelSelector := labels.Set{"department": dept}
 	pods := h.Handler.Informers[PodInformer].(*informer.PodInformer).ListBySelector(labelSelector)
@@ -987,50 +776,51 @@ func (h *ResourceHandler) EnvResources(c *gin.Context) {
 		return
 	}
 
-	envPods := make(map[string]model.EnvResource)
-	for _, pod := range pods {
+	type envAgg struct {
+		totals classTotals
+		usage  map[NodeType]v1.ResourceList
+	}
+	envs := make(map[string]*envAgg)
+	h.recomputeMu.Lock()
+	for i := range pods {
+		pod := &pods[i]
 		namespaceGroup, exists := pod.Labels["namespaceGroup"]
-		if exists {
-			if _, groupExists := envPods[namespaceGroup]; !groupExists {
-				nonXcMemory := resource.MustParse("0Mi")
-				kylinArmMemory := resource.MustParse("0Mi")
-				kylinX86Memory := resource.MustParse("0Mi")
-
-				envPods[namespaceGroup] = model.EnvResource{
-					Dept:    dept,
-					EnvName: namespaceGroup,
-					NonXcResource: model.NonXcResource{
-						CommonResource: model.CommonResource{
-							Limits: model.ComputationResources{
-								Memory: &nonXcMemory,
-							},
-						},
-					},
-					XcResource: model.XcResource{
-						Arm: model.CommonResource{
-							Limits: model.ComputationResources{
-								Memory: &kylinArmMemory,
-							},
-						},
-						X86: model.CommonResource{
-							Limits: model.ComputationResources{
-								Memory: &kylinX86Memory,
-							},
-						},
-					},
-				}
-			}
-			for _, c := range pod.Spec.Containers {
-				if strings.Contains(pod.Spec.NodeName, string(RedHatX86NodePrefix)) {
-					envPods[namespaceGroup].NonXcResource.Limits.Memory.Add(c.Resources.Limits.Memory().DeepCopy())
-				} else if strings.Contains(pod.Spec.NodeName, string(KylinArmNodePrefix)) {
-					envPods[namespaceGroup].XcResource.Arm.Limits.Memory.Add(c.Resources.Limits.Memory().DeepCopy())
-				} else if strings.Contains(pod.Spec.NodeName, string(KylinX86NodePrefix)) {
-					envPods[namespaceGroup].XcResource.X86.Limits.Memory.Add(c.Resources.Limits.Memory().DeepCopy())
-				}
+		if !exists || podTerminated(pod) || podPending(pod) {
+			continue
+		}
+		e := envs[namespaceGroup]
+		if e == nil {
+			e = &envAgg{totals: make(classTotals), usage: make(map[NodeType]v1.ResourceList)}
+			envs[namespaceGroup] = e
+		}
+		arch := h.archOf(pod.Spec.NodeName)
+		e.totals.get(arch).add(pod)
+		if rec, ok := h.podRecordLocked(pod.Namespace + "/" + pod.Name); ok {
+			e.usage[arch] = addResourceList(e.usage[arch], rec.usage)
+		}
+	}
+	h.recomputeMu.Unlock()
+
+	envPods := make(map[string]model.EnvResource, len(envs))
+	for name, e := range envs {
+		common := func(t NodeType) model.CommonResource {
+			totals := e.totals.get(t)
+			return model.CommonResource{
+				Limits:   computationResources(totals.limits),
+				Requests: computationResources(totals.requests),
+				Used:     computationResources(e.usage[t]),
 			}
 		}
+		envPods[name] = model.EnvResource{
+			Dept:          dept,
+			EnvName:       name,
+			NonXcResource: model.NonXcResource{CommonResource: common(NonXcNodeType)},
+			XcResource: model.XcResource{
+				Arm: common(XcArmNodeType),
+				X86: common(XcX86NodeType),
+			},
+		}
 	}
 
-	c.JSON(http.StatusOK, envPods)
+	renderReport(c, format, dept+"-env-resources", envPods, func() export.Table { return envTable(envPods) })
 }
@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+
+	"k8s-admin-informer/pkg/authz"
+	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/model"
+)
+
+// DeptResourcesV2 按节点类型返回部门配额、已宣布用量、实时用量、requests/limits 与待调度需求，
+// 已结束的 Pod 不计入，支持 dept 查询参数只返回单个部门
+func (h *ResourceHandler) DeptResourcesV2(c *gin.Context) {
+	filter := c.Query("dept")
+	scope := authz.ScopeFrom(c.Request.Context())
+	if filter != "" && !scope.Allows(filter) {
+		authz.Forbidden(c, "department "+filter+" is not visible to the caller")
+		return
+	}
+
+	// requests/limits 直接从 PodInformer 缓存汇总
+	byDept := make(map[string]classTotals)
+	for _, pod := range h.Handler.Informers[PodInformer].(*informer.PodInformer).List() {
+		dept := pod.Labels["department"]
+		if dept == "" || (filter != "" && dept != filter) || podTerminated(pod) || podPending(pod) {
+			continue
+		}
+		if byDept[dept] == nil {
+			byDept[dept] = make(classTotals)
+		}
+		byDept[dept].get(h.archOf(pod.Spec.NodeName)).add(pod)
+	}
+
+	// 实时用量读取增量聚合结果，复制后释放锁
+	usage := make(map[string]map[NodeType]v1.ResourceList)
+	pending := make(map[string]map[NodeType]model.PendingDemandV2)
+	pods := make(map[string]int)
+	h.recomputeMu.Lock()
+	for dept, a := range h.deptAgg {
+		m := make(map[NodeType]v1.ResourceList, len(a.usage))
+		for t, list := range a.usage {
+			m[t] = list.DeepCopy()
+		}
+		usage[dept] = m
+		p := make(map[NodeType]model.PendingDemandV2, len(a.pendingPods))
+		for _, nc := range nodeClasses {
+			p[nc.Type] = model.PendingDemandV2{
+				Pods:     a.pendingPods[nc.Type],
+				Requests: model.NewResourceAmounts(withComputeDefaults(a.pendingRequests[nc.Type])),
+			}
+		}
+		pending[dept] = p
+		pods[dept] = a.pods
+	}
+	h.recomputeMu.Unlock()
+
+	data := make([]model.DeptResourceV2, 0)
+	for _, quota := range h.Handler.Informers[DeptResourceQuotaInformer].(*informer.DeptResourceQuotaInformer).List() {
+		name := quota.Spec.DeptName
+		if (filter != "" && name != filter) || !scope.Allows(name) {
+			continue
+		}
+		totals := byDept[name]
+		if totals == nil {
+			totals = make(classTotals)
+		}
+		dept := model.DeptResourceV2{Name: name, Pods: pods[name]}
+		for _, nc := range nodeClasses {
+			q := nc.quota(&quota.Spec.Resources)
+			announced := nc.announced(&quota.Status.UsedResources)
+			t := totals.get(nc.Type)
+			p, ok := pending[name][nc.Type]
+			if !ok {
+				p = model.PendingDemandV2{Requests: model.NewResourceAmounts(zeroUsage())}
+			}
+			dept.PendingPods += p.Pods
+			dept.Classes = append(dept.Classes, model.NodeClassResourceV2{
+				Class: string(nc.Type),
+				OS:    nc.OS,
+				Arch:  nc.Arch,
+				Quota: model.ResourceBounds{
+					Requests: model.NewResourceAmounts(q.Requests),
+					Limits:   model.NewResourceAmounts(q.Limits),
+				},
+				Announced: model.ResourceBounds{
+					Requests: model.NewResourceAmounts(announced.Requests),
+					Limits:   model.NewResourceAmounts(announced.Limits),
+				},
+				Used:      model.NewResourceAmounts(withComputeDefaults(usage[name][nc.Type])),
+				Requested: t.requested(),
+				Limited:   t.limited(),
+				Pending:   p,
+			})
+		}
+		data = append(data, dept)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Name < data[j].Name })
+
+	if filter != "" && len(data) == 0 {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "DeptResourceQuota not found"})
+		return
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// NodeResourcesV2 返回每个节点的容量、可分配量、实时用量以及节点上 Pod 的 requests/limits
+func (h *ResourceHandler) NodeResourcesV2(c *gin.Context) {
+	byNode := h.nodePodTotals()
+
+	records := h.nodeRecords()
+	data := model.NodeListV2{Items: make([]model.NodeV2, 0, len(records))}
+	for name, rec := range records {
+		t := byNode[name]
+		if t == nil {
+			t = &podTotals{}
+		}
+		item := model.NodeV2{
+			Name:        name,
+			Class:       string(rec.nodeType),
+			Capacity:    model.NewResourceAmounts(rec.capacity),
+			Allocatable: model.NewResourceAmounts(rec.allocatable),
+			Used:        model.NewResourceAmounts(withComputeDefaults(rec.usage)),
+			Requested:   t.requested(),
+			Limited:     t.limited(),
+		}
+		if nc := classOf(rec.nodeType); nc != nil {
+			item.OS, item.Arch = nc.OS, nc.Arch
+		}
+		data.Items = append(data.Items, item)
+	}
+	sort.Slice(data.Items, func(i, j int) bool { return data.Items[i].Name < data.Items[j].Name })
+	c.JSON(http.StatusOK, data)
+}
+
+// ClusterResourcesV2 按节点类型汇总节点容量、可分配量、实时用量与全部 Pod 的 requests/limits
+func (h *ResourceHandler) ClusterResourcesV2(c *gin.Context) {
+	totals := h.classPodTotals()
+
+	type nodeSum struct {
+		nodes                        int
+		capacity, allocatable, usage v1.ResourceList
+	}
+	sums := make(map[NodeType]*nodeSum)
+	for _, rec := range h.nodeRecords() {
+		s := sums[rec.nodeType]
+		if s == nil {
+			s = &nodeSum{}
+			sums[rec.nodeType] = s
+		}
+		s.nodes++
+		s.capacity = addResourceList(s.capacity, rec.capacity)
+		s.allocatable = addResourceList(s.allocatable, rec.allocatable)
+		s.usage = addResourceList(s.usage, rec.usage)
+	}
+
+	var data model.ClusterResourceV2
+	for _, nc := range nodeClasses {
+		s := sums[nc.Type]
+		if s == nil {
+			s = &nodeSum{}
+		}
+		t := totals.get(nc.Type)
+		data.Classes = append(data.Classes, model.ClusterClassResourceV2{
+			Class:       string(nc.Type),
+			OS:          nc.OS,
+			Arch:        nc.Arch,
+			Nodes:       s.nodes,
+			Capacity:    model.NewResourceAmounts(s.capacity),
+			Allocatable: model.NewResourceAmounts(s.allocatable),
+			Used:        model.NewResourceAmounts(withComputeDefaults(s.usage)),
+			Requested:   t.requested(),
+			Limited:     t.limited(),
+		})
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// EnvResourcesV2 返回部门下各环境（namespaceGroup 标签）按节点类型的实时用量与 requests/limits
+func (h *ResourceHandler) EnvResourcesV2(c *gin.Context) {
+	dept := c.Query("dept")
+	if dept == "" {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "dept query parameter is required"})
+		return
+	}
+	if !authz.ScopeFrom(c.Request.Context()).Allows(dept) {
+		authz.Forbidden(c, "department "+dept+" is not visible to the caller")
+		return
+	}
+
+	type envAgg struct {
+		totals classTotals
+		usage  map[NodeType]v1.ResourceList
+	}
+	envs := make(map[string]*envAgg)
+	pods := h.Handler.Informers[PodInformer].(*informer.PodInformer).List()
+	h.recomputeMu.Lock()
+	for _, pod := range pods {
+		if pod.Labels["department"] != dept || podTerminated(pod) || podPending(pod) {
+			continue
+		}
+		env, ok := pod.Labels["namespaceGroup"]
+		if !ok {
+			continue
+		}
+		e := envs[env]
+		if e == nil {
+			e = &envAgg{totals: make(classTotals), usage: make(map[NodeType]v1.ResourceList)}
+			envs[env] = e
+		}
+		arch := h.archOf(pod.Spec.NodeName)
+		e.totals.get(arch).add(pod)
+		if rec, ok := h.podRecordLocked(pod.Namespace + "/" + pod.Name); ok {
+			e.usage[arch] = addResourceList(e.usage[arch], rec.usage)
+		}
+	}
+	h.recomputeMu.Unlock()
+
+	data := make([]model.EnvResourceV2, 0, len(envs))
+	for name, e := range envs {
+		env := model.EnvResourceV2{Dept: dept, EnvName: name}
+		for _, nc := range nodeClasses {
+			t := e.totals.get(nc.Type)
+			env.Pods += int(t.pods)
+			env.Classes = append(env.Classes, model.EnvClassResourceV2{
+				Class:     string(nc.Type),
+				OS:        nc.OS,
+				Arch:      nc.Arch,
+				Used:      model.NewResourceAmounts(withComputeDefaults(e.usage[nc.Type])),
+				Requested: t.requested(),
+				Limited:   t.limited(),
+			})
+		}
+		data = append(data, env)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].EnvName < data[j].EnvName })
+	c.JSON(http.StatusOK, data)
+}
+
+// nodeRecords 复制一份节点增量聚合结果
+func (h *ResourceHandler) nodeRecords() map[string]nodeRecord {
+	h.recomputeMu.Lock()
+	defer h.recomputeMu.Unlock()
+	out := make(map[string]nodeRecord, len(h.nodeAgg))
+	for name, rec := range h.nodeAgg {
+		out[name] = nodeRecord{
+			nodeType:    rec.nodeType,
+			capacity:    rec.capacity.DeepCopy(),
+			allocatable: rec.allocatable.DeepCopy(),
+			usage:       rec.usage.DeepCopy(),
+		}
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"k8s-admin-informer/pkg/logging"
+)
+
+// 服务自身的运行指标：HTTP 请求、informer 事件与缓存大小、事件队列、聚合重建与用量来源调用
+var (
+	httpRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, gin route and status code.",
+		},
+		[]string{"method", "route", "code"},
+	)
+	httpDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method and gin route.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "route"},
+	)
+	informerEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "informer_events_total",
+			Help: "Informer events by informer kind and event type (add, update, delete).",
+		},
+		[]string{"kind", "event"},
+	)
+	aggRebuildDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aggregation_rebuild_duration_seconds",
+			Help:    "Duration of department/node cache rebuilds from the incremental aggregation.",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+		},
+		[]string{"kind"},
+	)
+	usageSourceDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "usage_source_request_duration_seconds",
+			Help:    "Latency of bulk usage requests to the usage source by source and target (pods or nodes).",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"source", "target"},
+	)
+	usageSourceErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "usage_source_errors_total",
+			Help: "Failed bulk usage requests to the usage source by source and target (pods or nodes).",
+		},
+		[]string{"source", "target"},
+	)
+	workqueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "workqueue_depth",
+			Help: "Current depth of the work queue.",
+		},
+		[]string{"name"},
+	)
+	workqueueAdds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "workqueue_adds_total",
+			Help: "Items added to the work queue.",
+		},
+		[]string{"name"},
+	)
+	workqueueLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "workqueue_queue_duration_seconds",
+			Help:    "Time an item stays in the work queue before being processed.",
+			Buckets: prometheus.ExponentialBuckets(10e-6, 10, 8),
+		},
+		[]string{"name"},
+	)
+	workqueueWorkDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "workqueue_work_duration_seconds",
+			Help:    "Time spent processing an item from the work queue.",
+			Buckets: prometheus.ExponentialBuckets(10e-6, 10, 8),
+		},
+		[]string{"name"},
+	)
+	workqueueUnfinished = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "workqueue_unfinished_work_seconds",
+			Help: "Seconds of work in progress that has not been observed by work_duration yet.",
+		},
+		[]string{"name"},
+	)
+	workqueueLongestRunning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "workqueue_longest_running_processor_seconds",
+			Help: "Seconds the longest running processor of the work queue has been running.",
+		},
+		[]string{"name"},
+	)
+	workqueueRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "workqueue_retries_total",
+			Help: "Retries handled by the work queue.",
+		},
+		[]string{"name"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpDuration, informerEvents, aggRebuildDuration,
+		usageSourceDuration, usageSourceErrors,
+		workqueueDepth, workqueueAdds, workqueueLatency, workqueueWorkDuration,
+		workqueueUnfinished, workqueueLongestRunning, workqueueRetries)
+	// 需在创建任何队列之前设置，podEvents/nodeEvents/aggRebuild/deptQuotaStatus 队列均按名称上报
+	workqueue.SetProvider(workqueueMetrics{})
+}
+
+// HTTPMetrics 按 gin 路由模板统计请求数、状态码与耗时，未匹配路由的请求记为 route="unmatched"
+func HTTPMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// instrumentInformers 为每个 informer 注册事件计数，并导出缓存对象数
+func (h *Handler) instrumentInformers() {
+	for kind, inf := range h.Informers {
+		kind := kind
+		err := inf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(interface{}) {
+				informerEvents.WithLabelValues(kind, "add").Inc()
+			},
+			UpdateFunc: func(interface{}, interface{}) {
+				informerEvents.WithLabelValues(kind, "update").Inc()
+			},
+			DeleteFunc: func(interface{}) {
+				informerEvents.WithLabelValues(kind, "delete").Inc()
+			},
+		})
+		if err != nil {
+			logging.Informer(kind).Errorf("注册 %s informer 事件计数失败: %v", kind, err)
+		}
+	}
+	prometheus.MustRegister(informerStoreCollector{h: h})
+}
+
+// informerStoreCollector 在抓取时读取各 informer 缓存中的对象数
+type informerStoreCollector struct {
+	h *Handler
+}
+
+var informerStoreDesc = prometheus.NewDesc("informer_store_objects",
+	"Number of objects in the informer cache by kind.", []string{"kind"}, nil)
+
+func (c informerStoreCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- informerStoreDesc
+}
+
+func (c informerStoreCollector) Collect(ch chan<- prometheus.Metric) {
+	for kind, inf := range c.h.Informers {
+		ch <- prometheus.MustNewConstMetric(informerStoreDesc, prometheus.GaugeValue, float64(inf.Len()), kind)
+	}
+}
+
+// workqueueMetrics 将 client-go workqueue 指标接入 prometheus
+type workqueueMetrics struct{}
+
+func (workqueueMetrics) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return workqueueDepth.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewAddsMetric(name string) workqueue.CounterMetric {
+	return workqueueAdds.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return workqueueLatency.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return workqueueWorkDuration.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueUnfinished.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueLongestRunning.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return workqueueRetries.WithLabelValues(name)
+}
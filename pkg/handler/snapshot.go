@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"k8s-admin-informer/pkg/authz"
+	"k8s-admin-informer/pkg/model"
+)
+
+// snapshotEpoch 进程启动时生成，写入 ETag 以区分重启或多副本之间相同的版本号
+var snapshotEpoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+// snapshot 查询结果的不可变快照：发布后 data 不再修改，读取方无需加锁，直接编码或复制后过滤
+type snapshot[T any] struct {
+	version   uint64
+	generated time.Time
+	data      T
+}
+
+func (s *snapshot[T]) age() time.Duration {
+	return time.Since(s.generated)
+}
+
+// snapshotCell 以原子指针保存最新快照，版本号单调递增；
+// 发布方需串行（本包中在持有 recomputeMu 时构建并发布），保证版本号与数据新旧一致
+type snapshotCell[T any] struct {
+	cur atomic.Pointer[snapshot[T]]
+
+	mu      sync.Mutex
+	version uint64
+	// changed 在每次发布时关闭并替换，等待新版本的请求在其上阻塞
+	changed chan struct{}
+}
+
+func newSnapshotCell[T any]() *snapshotCell[T] {
+	return &snapshotCell[T]{changed: make(chan struct{})}
+}
+
+// load 返回最新快照，尚未发布时为 nil
+func (c *snapshotCell[T]) load() *snapshot[T] {
+	return c.cur.Load()
+}
+
+// publish 以下一个版本号发布快照并唤醒等待方
+func (c *snapshotCell[T]) publish(data T) *snapshot[T] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	s := &snapshot[T]{version: c.version, generated: time.Now(), data: data}
+	c.cur.Store(s)
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return s
+}
+
+// wait 等待版本不低于 minVersion 的快照，超过 timeout 或 ctx 结束时返回当时的最新快照（可能为 nil 或低于 minVersion）
+func (c *snapshotCell[T]) wait(ctx context.Context, minVersion uint64, timeout time.Duration) *snapshot[T] {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		c.mu.Lock()
+		s, changed := c.cur.Load(), c.changed
+		c.mu.Unlock()
+		if s != nil && s.version >= minVersion {
+			return s
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return c.cur.Load()
+		case <-ctx.Done():
+			return c.cur.Load()
+		}
+	}
+}
+
+// parseMinVersion 解析 minVersion 查询参数，未设置时为 0
+func parseMinVersion(c *gin.Context) (uint64, error) {
+	v := c.Query("minVersion")
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+// setSnapshotHeaders 写入缓存命中情况、快照版本与生成时间，Last-Modified 取生成时间，
+// Cache-Control: no-cache 要求客户端每次以条件请求重新校验。X-Generated-At 保持 v1 一直使用的 RFC3339（精确到秒），
+// 需要区分同一秒内的快照时以 X-Snapshot-Version 或 ETag 为准
+func setSnapshotHeaders[T any](c *gin.Context, cacheState string, s *snapshot[T]) {
+	c.Header(model.CacheHeader, cacheState)
+	c.Header(model.SnapshotVersionHeader, strconv.FormatUint(s.version, 10))
+	c.Header(model.GeneratedAtHeader, s.generated.Format(time.RFC3339))
+	c.Header("Last-Modified", s.generated.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-cache")
+}
+
+// snapshotETag 由快照种类与版本生成弱 ETag（同一内容的压缩与未压缩表示共用）；
+// 调用方只可见部分部门时附加可见范围的摘要，不同范围的响应不共用 ETag
+func snapshotETag[T any](kind string, s *snapshot[T], scope *authz.Scope) string {
+	tag := kind + "-" + snapshotEpoch + "-" + strconv.FormatUint(s.version, 10)
+	if !scope.Admin() {
+		sum := fnv.New32a()
+		for _, dept := range scope.Depts() {
+			sum.Write([]byte(dept))
+			sum.Write([]byte{0})
+		}
+		tag += "-" + strconv.FormatUint(uint64(sum.Sum32()), 36)
+	}
+	return `W/"` + tag + `"`
+}
+
+// notModified 写入 ETag，并按 If-None-Match（存在时优先）或 If-Modified-Since 判断客户端缓存是否仍有效，有效时响应 304
+func notModified(c *gin.Context, etag string, modified time.Time) bool {
+	c.Header("ETag", etag)
+	fresh := false
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				fresh = true
+				break
+			}
+		}
+	} else if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil {
+		fresh = !modified.Truncate(time.Second).After(since)
+	}
+	if fresh {
+		c.Status(http.StatusNotModified)
+	}
+	return fresh
+}
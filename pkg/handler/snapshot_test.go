@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/model"
+)
+
+// 这些测试需配合 go test -race 运行，验证快照发布、等待与读取在并发下没有数据竞争
+
+func TestSnapshotCellWait(t *testing.T) {
+	cell := newSnapshotCell[uint64]()
+	const versions = 200
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for min := uint64(i + 1); min <= versions; min += 8 {
+				s := cell.wait(context.Background(), min, time.Second)
+				if s == nil || s.version < min {
+					t.Errorf("wait(%d) returned %+v", min, s)
+					return
+				}
+				// 快照数据在发布后不变，与版本号一致
+				if s.data != s.version {
+					t.Errorf("snapshot %d carries data %d", s.version, s.data)
+					return
+				}
+			}
+		}(i)
+	}
+	go func() {
+		for v := uint64(1); v <= versions; v++ {
+			cell.publish(v)
+		}
+	}()
+	wg.Wait()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if s := cell.wait(ctx, versions+1, time.Minute); s == nil || s.version != versions {
+		t.Fatalf("wait with a cancelled context returned %+v, want the latest snapshot", s)
+	}
+	if s := cell.wait(context.Background(), versions+1, 10*time.Millisecond); s == nil || s.version != versions {
+		t.Fatalf("wait past the timeout returned %+v, want the latest snapshot", s)
+	}
+}
+
+// TestSnapshotHeaders X-Generated-At 沿用 v1 的 RFC3339 格式，不带小数秒
+func TestSnapshotHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	generated := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.FixedZone("CST", 8*3600))
+	setSnapshotHeaders(c, "HIT", &snapshot[int]{version: 42, generated: generated})
+
+	if got, want := w.Header().Get(model.GeneratedAtHeader), "2024-05-06T07:08:09+08:00"; got != want {
+		t.Fatalf("%s = %q, want %q", model.GeneratedAtHeader, got, want)
+	}
+	if got := w.Header().Get(model.SnapshotVersionHeader); got != "42" {
+		t.Fatalf("%s = %q, want 42", model.SnapshotVersionHeader, got)
+	}
+}
+
+// TestConcurrentReadersAndEventWorkers 事件处理协程持续写入 Pod/节点聚合并重建快照，
+// 同时通过 HTTP 接口与 GetDeptResource 读取快照（含 refresh、minVersion 等待）
+func TestConcurrentReadersAndEventWorkers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestResourceHandler(t)
+	withDeptQuotas(h, "a", "b")
+	h.snapshotMaxWait = 20 * time.Millisecond
+	nodeStore := h.Handler.Informers[NodeInformer].(*informer.NodeInformer).Store()
+
+	engine := gin.New()
+	engine.GET(model.DeptResourcePath, h.DeptResources)
+	engine.GET(model.NodeResourcePath, h.NodeResources)
+
+	nodes := []string{"b-node-1", "hk-node-1", "kk-node-1"}
+	depts := []string{"a", "b"}
+	const workers, readers, rounds = 4, 4, 150
+
+	var writers sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; i < rounds; i++ {
+				name := fmt.Sprintf("pod-%d-%d", w, i%10)
+				uid := types.UID(fmt.Sprintf("uid-%d-%d", w, i))
+				pod := newTestPod("ns", name, uid, testPodSpec{
+					dept: depts[i%len(depts)], node: nodes[i%len(nodes)], phase: v1.PodRunning, cpu: "100m", memory: "128Mi",
+				})
+				if i%7 == 6 {
+					_ = podStore(h).Delete(pod)
+				} else {
+					_ = podStore(h).Update(pod)
+				}
+				h.syncPod("ns/" + name)
+
+				node := nodes[(w+i)%len(nodes)]
+				_ = nodeStore.Update(&v1.Node{
+					ObjectMeta: metaV1.ObjectMeta{Name: node},
+					Status: v1.NodeStatus{Allocatable: v1.ResourceList{
+						v1.ResourceCPU:    *resource.NewQuantity(int64(8+i%4), resource.DecimalSI),
+						v1.ResourceMemory: resource.MustParse("32Gi"),
+					}},
+				})
+				h.syncNode(node)
+
+				if i%5 == 0 {
+					h.rebuild(rebuildDept)
+					h.rebuild(rebuildNode)
+				}
+			}
+		}(w)
+	}
+
+	done := make(chan struct{})
+	var readersWG sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		readersWG.Add(1)
+		go func(r int) {
+			defer readersWG.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				var path string
+				switch i % 4 {
+				case 0:
+					path = model.DeptResourcePath
+				case 1:
+					path = model.NodeResourcePath
+				case 2:
+					next := h.deptSnapshots.load()
+					min := uint64(1)
+					if next != nil {
+						min = next.version + 1
+					}
+					path = model.DeptResourcePath + "?minVersion=" + strconv.FormatUint(min, 10)
+				default:
+					path = model.NodeResourcePath + "?refresh=true"
+				}
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("GET %s: %d %s", path, w.Code, w.Body.String())
+					return
+				}
+				for _, dept := range h.GetDeptResource() {
+					_ = dept.Used.NonXc.Memory
+				}
+			}
+		}(r)
+	}
+
+	writers.Wait()
+	close(done)
+	readersWG.Wait()
+
+	assertMatchesRecompute(t, h, "after concurrent load")
+	h.rebuild(rebuildNode)
+	if got := len(h.nodeSnapshots.load().data.Items); got != len(nodes) {
+		t.Fatalf("node snapshot has %d nodes, want %d", got, len(nodes))
+	}
+}
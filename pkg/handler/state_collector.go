@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s-admin-informer/pkg/kubernetes/informer"
+)
+
+// StateCollector 在抓取时从 informer 缓存生成类似 kube-state-metrics 的工作负载、Pod 与节点指标，
+// 序列带上本服务识别的 department、env（namespaceGroup）与节点类型标签，便于与部门配额直接关联。
+// 单次抓取的序列数超过 maxSeries 后其余序列被丢弃，并通过 state_metrics_series_dropped 报告
+type StateCollector struct {
+	rsc       *ResourceHandler
+	maxSeries int
+
+	workloadDesired   *prometheus.Desc
+	workloadReady     *prometheus.Desc
+	workloadAvailable *prometheus.Desc
+	podRestarts       *prometheus.Desc
+	podOOMKilled      *prometheus.Desc
+	nodeAllocatable   *prometheus.Desc
+	nodeRequests      *prometheus.Desc
+	nodeLimits        *prometheus.Desc
+	nodeUsage         *prometheus.Desc
+	dropped           *prometheus.Desc
+}
+
+// NewStateCollector 创建状态指标采集器，序列上限由 STATE_METRICS_MAX_SERIES 配置，默认 50000，0 表示不限制
+func NewStateCollector(rsc *ResourceHandler) *StateCollector {
+	defaultMax := 50000
+	maxSeries := defaultMax
+	if v := os.Getenv("STATE_METRICS_MAX_SERIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			maxSeries = n
+		} else {
+			log.Warnf("解析 STATE_METRICS_MAX_SERIES 失败，使用默认值 %d，错误: %v", defaultMax, err)
+		}
+	}
+	wlLabels := []string{"namespace", "workload", "kind", "department", "env", "class"}
+	podLabels := []string{"namespace", "pod", "department", "env", "class"}
+	nodeLabels := []string{"node", "class", "os", "arch", "resource"}
+	return &StateCollector{
+		rsc:       rsc,
+		maxSeries: maxSeries,
+		workloadDesired: prometheus.NewDesc("workload_replicas_desired",
+			"Desired replicas of a Deployment or StatefulSet.", wlLabels, nil),
+		workloadReady: prometheus.NewDesc("workload_replicas_ready",
+			"Ready replicas of a Deployment or StatefulSet.", wlLabels, nil),
+		workloadAvailable: prometheus.NewDesc("workload_replicas_available",
+			"Available replicas of a Deployment or StatefulSet.", wlLabels, nil),
+		podRestarts: prometheus.NewDesc("pod_container_restarts",
+			"Sum of container restart counts of a non-terminated pod.", podLabels, nil),
+		podOOMKilled: prometheus.NewDesc("pod_container_oom_killed",
+			"Number of containers of a non-terminated pod whose current or last termination reason is OOMKilled.", podLabels, nil),
+		nodeAllocatable: prometheus.NewDesc("node_allocatable",
+			"Node allocatable resources (cpu in cores, memory in bytes).", nodeLabels, nil),
+		nodeRequests: prometheus.NewDesc("node_requests",
+			"Sum of effective requests of scheduled, non-terminated pods on the node.", nodeLabels, nil),
+		nodeLimits: prometheus.NewDesc("node_limits",
+			"Sum of effective limits of scheduled, non-terminated pods on the node.", nodeLabels, nil),
+		nodeUsage: prometheus.NewDesc("node_usage",
+			"Node live usage from the configured usage source.", nodeLabels, nil),
+		dropped: prometheus.NewDesc("state_metrics_series_dropped",
+			"Number of series dropped in the last scrape because STATE_METRICS_MAX_SERIES was reached.", nil, nil),
+	}
+}
+
+// Describe 实现 prometheus.Collector
+func (c *StateCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.workloadDesired, c.workloadReady, c.workloadAvailable,
+		c.podRestarts, c.podOOMKilled, c.nodeAllocatable, c.nodeRequests, c.nodeLimits, c.nodeUsage, c.dropped} {
+		ch <- d
+	}
+}
+
+// Collect 实现 prometheus.Collector，按节点、工作负载、Pod 的顺序输出，超出上限时优先丢弃数量最多的 Pod 序列
+func (c *StateCollector) Collect(ch chan<- prometheus.Metric) {
+	emitted, dropped := 0, 0
+	emit := func(desc *prometheus.Desc, value float64, labels ...string) {
+		if c.maxSeries > 0 && emitted >= c.maxSeries {
+			dropped++
+			return
+		}
+		emitted++
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+
+	c.collectNodes(emit)
+	c.collectWorkloads(emit)
+	c.collectPods(emit)
+
+	if dropped > 0 {
+		log.Warnf("状态指标序列数超过上限 %d，本次丢弃 %d 条", c.maxSeries, dropped)
+	}
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.GaugeValue, float64(dropped))
+}
+
+type emitFunc func(desc *prometheus.Desc, value float64, labels ...string)
+
+func (c *StateCollector) collectNodes(emit emitFunc) {
+	byNode := c.rsc.nodePodTotals()
+	for name, rec := range c.rsc.nodeRecords() {
+		nc := classOf(rec.nodeType)
+		if nc == nil {
+			continue
+		}
+		t := byNode[name]
+		if t == nil {
+			t = &podTotals{}
+		}
+		for _, res := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			labels := []string{name, string(nc.Type), nc.OS, nc.Arch, string(res)}
+			emit(c.nodeAllocatable, quantityValue(rec.allocatable[res]), labels...)
+			emit(c.nodeRequests, quantityValue(t.requests[res]), labels...)
+			emit(c.nodeLimits, quantityValue(t.limits[res]), labels...)
+			emit(c.nodeUsage, quantityValue(rec.usage[res]), labels...)
+		}
+	}
+}
+
+func (c *StateCollector) collectWorkloads(emit emitFunc) {
+	workload := func(kind string, meta metaV1.ObjectMeta, tmpl *v1.PodTemplateSpec, desired *int32, ready, available int32) {
+		replicas := int32(1)
+		if desired != nil {
+			replicas = *desired
+		}
+		dept, env := workloadLabels(meta.Labels, tmpl.Labels)
+		class, _ := classOfPodSpec(&tmpl.Spec)
+		labels := []string{meta.Namespace, meta.Name, kind, dept, env, string(class)}
+		emit(c.workloadDesired, float64(replicas), labels...)
+		emit(c.workloadReady, float64(ready), labels...)
+		emit(c.workloadAvailable, float64(available), labels...)
+	}
+	for _, d := range c.rsc.Handler.Informers[DeploymentInformer].(*informer.DeploymentInformer).List() {
+		workload("Deployment", d.ObjectMeta, &d.Spec.Template, d.Spec.Replicas, d.Status.ReadyReplicas, d.Status.AvailableReplicas)
+	}
+	for _, s := range c.rsc.Handler.Informers[StatefulSetInformer].(*informer.StatefulSetInformer).List() {
+		workload("StatefulSet", s.ObjectMeta, &s.Spec.Template, s.Spec.Replicas, s.Status.ReadyReplicas, s.Status.AvailableReplicas)
+	}
+}
+
+func (c *StateCollector) collectPods(emit emitFunc) {
+	for _, pod := range c.rsc.Handler.Informers[PodInformer].(*informer.PodInformer).List() {
+		if podTerminated(pod) {
+			continue
+		}
+		var restarts int32
+		oomKilled := 0
+		statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			restarts += cs.RestartCount
+			if terminatedBy(cs.State, "OOMKilled") || terminatedBy(cs.LastTerminationState, "OOMKilled") {
+				oomKilled++
+			}
+		}
+		class, _ := classOfPodSpec(&pod.Spec)
+		labels := []string{pod.Namespace, pod.Name, pod.Labels["department"], pod.Labels["namespaceGroup"], string(class)}
+		emit(c.podRestarts, float64(restarts), labels...)
+		emit(c.podOOMKilled, float64(oomKilled), labels...)
+	}
+}
+
+// workloadLabels 优先取 Pod 模板上的 department/namespaceGroup 标签，缺失时回退到工作负载自身标签
+func workloadLabels(own, tmpl map[string]string) (dept, env string) {
+	dept, env = tmpl["department"], tmpl["namespaceGroup"]
+	if dept == "" {
+		dept = own["department"]
+	}
+	if env == "" {
+		env = own["namespaceGroup"]
+	}
+	return dept, env
+}
+
+func terminatedBy(state v1.ContainerState, reason string) bool {
+	return state.Terminated != nil && state.Terminated.Reason == reason
+}
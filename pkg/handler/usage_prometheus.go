@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"k8s-admin-informer/pkg/tracing"
+)
+
+// Prometheus 查询默认值，基于 kubelet 内置 cAdvisor 指标
+const (
+	defaultPromPodMemoryQuery  = `sum by (namespace, pod) (container_memory_working_set_bytes{container!="", container!="POD"})`
+	defaultPromPodCPUQuery     = `sum by (namespace, pod) (rate(container_cpu_usage_seconds_total{container!="", container!="POD"}[5m]))`
+	defaultPromNodeMemoryQuery = `sum by (node) (container_memory_working_set_bytes{id="/"})`
+	defaultPromNodeCPUQuery    = `sum by (node) (rate(container_cpu_usage_seconds_total{id="/"}[5m]))`
+)
+
+// prometheusUsage 通过 Prometheus HTTP API（/api/v1/query）查询用量：
+// Pod 查询结果需带 namespace、pod 标签，节点查询结果需带 nodeLabel 标签；cpu 单位为核，内存单位为字节
+type prometheusUsage struct {
+	baseURL     string
+	client      *http.Client
+	bearerToken string
+	podMemory   string
+	podCPU      string
+	nodeMemory  string
+	nodeCPU     string
+	nodeLabel   string
+}
+
+// newPrometheusUsage 从环境变量读取配置：
+// - PROMETHEUS_URL Prometheus 地址，默认 http://prometheus:9090
+// - PROMETHEUS_TIMEOUT 单次查询超时，默认 10s
+// - PROMETHEUS_BEARER_TOKEN_FILE 可选，读取其中的 token 作为 Authorization: Bearer
+// - PROMETHEUS_POD_MEMORY_QUERY / PROMETHEUS_POD_CPU_QUERY / PROMETHEUS_NODE_MEMORY_QUERY / PROMETHEUS_NODE_CPU_QUERY 覆盖默认查询
+// - PROMETHEUS_NODE_LABEL 节点查询结果中节点名所在标签，默认 node
+func newPrometheusUsage() *prometheusUsage {
+	defaultTimeout := 10 * time.Second
+	timeout := defaultTimeout
+	if v := os.Getenv("PROMETHEUS_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			timeout = d
+		} else {
+			log.Warnf("解析 PROMETHEUS_TIMEOUT 失败，使用默认值 %s，错误: %v", defaultTimeout.String(), err)
+		}
+	}
+	p := &prometheusUsage{
+		baseURL:    strings.TrimRight(envOrDefault("PROMETHEUS_URL", "http://prometheus:9090"), "/"),
+		client:     &http.Client{Timeout: timeout, Transport: tracing.WrapTransport(http.DefaultTransport)},
+		podMemory:  envOrDefault("PROMETHEUS_POD_MEMORY_QUERY", defaultPromPodMemoryQuery),
+		podCPU:     envOrDefault("PROMETHEUS_POD_CPU_QUERY", defaultPromPodCPUQuery),
+		nodeMemory: envOrDefault("PROMETHEUS_NODE_MEMORY_QUERY", defaultPromNodeMemoryQuery),
+		nodeCPU:    envOrDefault("PROMETHEUS_NODE_CPU_QUERY", defaultPromNodeCPUQuery),
+		nodeLabel:  envOrDefault("PROMETHEUS_NODE_LABEL", "node"),
+	}
+	if file := os.Getenv("PROMETHEUS_BEARER_TOKEN_FILE"); file != "" {
+		if data, err := os.ReadFile(file); err == nil {
+			p.bearerToken = strings.TrimSpace(string(data))
+		} else {
+			log.Errorf("读取 PROMETHEUS_BEARER_TOKEN_FILE 失败: %v", err)
+		}
+	}
+	log.Infof("使用 Prometheus %s 作为用量来源", p.baseURL)
+	return p
+}
+
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func (p *prometheusUsage) Name() string {
+	return UsageSourcePrometheus
+}
+
+func (p *prometheusUsage) PodUsage(ctx context.Context) (map[string]v1.ResourceList, error) {
+	podKey := func(labels map[string]string) string {
+		if labels["namespace"] == "" || labels["pod"] == "" {
+			return ""
+		}
+		return labels["namespace"] + "/" + labels["pod"]
+	}
+	return p.usage(ctx, p.podMemory, p.podCPU, podKey)
+}
+
+func (p *prometheusUsage) NodeUsage(ctx context.Context) (map[string]v1.ResourceList, error) {
+	nodeKey := func(labels map[string]string) string {
+		return labels[p.nodeLabel]
+	}
+	return p.usage(ctx, p.nodeMemory, p.nodeCPU, nodeKey)
+}
+
+// usage 执行内存与 cpu 两个查询并按 key 合并，任一查询失败即返回错误
+func (p *prometheusUsage) usage(ctx context.Context, memoryQuery, cpuQuery string, key func(map[string]string) string) (map[string]v1.ResourceList, error) {
+	memory, err := p.query(ctx, memoryQuery)
+	if err != nil {
+		return nil, err
+	}
+	cpu, err := p.query(ctx, cpuQuery)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]v1.ResourceList)
+	get := func(k string) v1.ResourceList {
+		if out[k] == nil {
+			out[k] = zeroUsage()
+		}
+		return out[k]
+	}
+	for _, s := range memory {
+		if k := key(s.labels); k != "" {
+			get(k)[v1.ResourceMemory] = *resource.NewQuantity(int64(s.value), resource.BinarySI)
+		}
+	}
+	for _, s := range cpu {
+		if k := key(s.labels); k != "" {
+			get(k)[v1.ResourceCPU] = *resource.NewMilliQuantity(int64(s.value*1000), resource.DecimalSI)
+		}
+	}
+	return out, nil
+}
+
+// promSample 即时查询结果中的一条样本
+type promSample struct {
+	labels map[string]string
+	value  float64
+}
+
+// query 执行即时查询，只接受 vector 类型结果
+func (p *prometheusUsage) query(ctx context.Context, q string) ([]promSample, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/api/v1/query?query="+url.QueryEscape(q), nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.bearerToken)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Metric map[string]string `json:"metric"`
+				Value  [2]interface{}    `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("prometheus query %q: unexpected response (HTTP %d): %v", q, resp.StatusCode, err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("prometheus query %q failed (HTTP %d): %s", q, resp.StatusCode, result.Error)
+	}
+	if result.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("prometheus query %q: expected vector result, got %s", q, result.Data.ResultType)
+	}
+	samples := make([]promSample, 0, len(result.Data.Result))
+	for _, r := range result.Data.Result {
+		str, ok := r.Value[1].(string)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, promSample{labels: r.Metric, value: v})
+	}
+	return samples, nil
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// 用量来源
+const (
+	UsageSourceMetricsServer = "metrics-server"
+	UsageSourcePrometheus    = "prometheus"
+)
+
+// UsageProvider 批量提供 Pod 与节点的实时 cpu/memory 用量，由 metricsCache 按最小间隔调用
+type UsageProvider interface {
+	// Name 返回来源名称，用于响应头与日志
+	Name() string
+	// PodUsage 返回 namespace/name 到 cpu/memory 用量（各容器之和）的映射
+	PodUsage(ctx context.Context) (map[string]v1.ResourceList, error)
+	// NodeUsage 返回节点名到 cpu/memory 用量的映射
+	NodeUsage(ctx context.Context) (map[string]v1.ResourceList, error)
+}
+
+// newUsageProvider 按 USAGE_PROVIDER 选择用量来源：
+// - metrics-server（默认）使用 metrics.k8s.io API
+// - prometheus 使用 Prometheus HTTP API，配置见 newPrometheusUsage
+// metrics-server 客户端为空时返回 nil，此时用量全部为 0
+func newUsageProvider(handler *Handler) UsageProvider {
+	switch source := strings.ToLower(os.Getenv("USAGE_PROVIDER")); source {
+	case UsageSourcePrometheus:
+		return newPrometheusUsage()
+	case "", UsageSourceMetricsServer:
+	default:
+		log.Warnf("未知的 USAGE_PROVIDER %q，使用默认值 %s", source, UsageSourceMetricsServer)
+	}
+	if handler == nil || handler.metricsClient == nil {
+		return nil
+	}
+	return &metricsServerUsage{client: handler.metricsClient}
+}
+
+// metricsServerUsage 从 metrics-server 批量拉取 PodMetrics/NodeMetrics
+type metricsServerUsage struct {
+	client metricsv.Interface
+}
+
+func (m *metricsServerUsage) Name() string {
+	return UsageSourceMetricsServer
+}
+
+func (m *metricsServerUsage) PodUsage(ctx context.Context) (map[string]v1.ResourceList, error) {
+	pms, err := m.client.MetricsV1beta1().PodMetricses(metaV1.NamespaceAll).List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]v1.ResourceList, len(pms.Items))
+	for _, pm := range pms.Items {
+		total := zeroUsage()
+		for _, c := range pm.Containers {
+			total = addResourceList(total, v1.ResourceList{
+				v1.ResourceCPU:    c.Usage[v1.ResourceCPU],
+				v1.ResourceMemory: c.Usage[v1.ResourceMemory],
+			})
+		}
+		out[pm.Namespace+"/"+pm.Name] = total
+	}
+	return out, nil
+}
+
+func (m *metricsServerUsage) NodeUsage(ctx context.Context) (map[string]v1.ResourceList, error) {
+	nms, err := m.client.MetricsV1beta1().NodeMetricses().List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]v1.ResourceList, len(nms.Items))
+	for _, nm := range nms.Items {
+		out[nm.Name] = nm.Usage.DeepCopy()
+	}
+	return out, nil
+}
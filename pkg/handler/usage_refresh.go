@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/logging"
+)
+
+var (
+	deptAggDrift = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dept_aggregation_drift",
+			Help: "Difference between incremental department aggregation and a from-scratch recompute at the last reconciliation (cpu in cores, memory in bytes, pods in count; requests./limits. prefixes for effective pod requests and limits).",
+		},
+		[]string{"department", "class", "resource"},
+	)
+	deptAggReconciles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dept_aggregation_reconcile_total",
+			Help: "Department aggregation reconciliations by result (clean or drift).",
+		},
+		[]string{"result"},
+	)
+	usageRefreshUpdatedPods = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "dept_usage_refresh_updated_pods",
+			Help: "Number of pod records whose usage changed in the last usage refresh.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(deptAggDrift)
+	prometheus.MustRegister(deptAggReconciles)
+	prometheus.MustRegister(usageRefreshUpdatedPods)
+}
+
+// StartUsageRefresh 启动两个后台循环：
+//   - 每 USAGE_REFRESH_INTERVAL（默认 1m）批量抓取 PodMetrics/NodeMetrics 写入指标缓存，把用量变化应用到 podRecords/deptAgg 与 nodeAgg，
+//     避免长时间没有对象变更的 Pod 一直保留首次事件时的用量
+//   - 每 USAGE_RECONCILE_INTERVAL（默认 10m）根据 PodInformer 缓存从头重建部门聚合，与增量结果比对，
+//     记录并导出偏差后以重建结果为准
+func (h *ResourceHandler) StartUsageRefresh() {
+	defaultRefresh := time.Minute
+	refresh := defaultRefresh
+	if v := os.Getenv("USAGE_REFRESH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			refresh = d
+		} else {
+			log.Warnf("解析 USAGE_REFRESH_INTERVAL 失败，使用默认值 %s，错误: %v", defaultRefresh.String(), err)
+		}
+	}
+	defaultReconcile := 10 * time.Minute
+	reconcile := defaultReconcile
+	if v := os.Getenv("USAGE_RECONCILE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			reconcile = d
+		} else {
+			log.Warnf("解析 USAGE_RECONCILE_INTERVAL 失败，使用默认值 %s，错误: %v", defaultReconcile.String(), err)
+		}
+	}
+	go wait.Until(h.refreshUsage, refresh, h.Handler.stopCh)
+	go wait.Until(h.reconcileDeptAgg, reconcile, h.Handler.stopCh)
+}
+
+// refreshUsage 批量抓取指标写入指标缓存，再把最新用量应用到已记录的 Pod 与节点：逐条扣减旧用量、计入新用量；
+// 本轮指标中缺失的 Pod 保留原值（可能是 metrics-server 尚未采集到）
+func (h *ResourceHandler) refreshUsage() {
+	h.metrics.scrape()
+	usage := h.metrics.podUsages()
+	updated := 0
+	h.recomputeMu.Lock()
+	for uid, rec := range h.podRecords {
+		latest, ok := usage[rec.key]
+		if !ok || rec.pending || resourceListEqual(rec.usage, latest) {
+			continue
+		}
+		if a := h.deptAgg[rec.dept]; a != nil {
+			a.sub(rec)
+			rec.usage = latest
+			a.add(rec)
+		} else {
+			rec.usage = latest
+		}
+		h.podRecords[uid] = rec
+		updated++
+	}
+	h.recomputeMu.Unlock()
+	usageRefreshUpdatedPods.Set(float64(updated))
+	if updated > 0 {
+		log.Debugf("刷新 %d 个 Pod 的用量", updated)
+		h.scheduleRebuild(rebuildDept)
+	}
+
+	h.recomputeMu.Lock()
+	for name, u := range h.metrics.nodeUsages() {
+		if rec, ok := h.nodeAgg[name]; ok {
+			rec.usage = u
+			h.nodeAgg[name] = rec
+		}
+	}
+	h.recomputeMu.Unlock()
+	h.scheduleRebuild(rebuildNode)
+}
+
+// reconcileDeptAgg 从 PodInformer 缓存从头重建 podRecords/deptAgg 并与增量结果比对。
+// 重建按 Pod UID 进行，沿用同一 UID 已有记录的用量（没有记录的 Pod 使用指标缓存中的用量），因此偏差只反映事件记账的问题，而不是指标采样时间差。
+// Pod 列表在持有 recomputeMu 时读取：informer 先更新缓存再分发事件，尚未处理的事件在重建后仍能正确去重
+func (h *ResourceHandler) reconcileDeptAgg() {
+	usage := h.metrics.podUsages()
+	podInf := h.Handler.Informers[PodInformer].(*informer.PodInformer)
+
+	h.recomputeMu.Lock()
+	records, uids, agg := h.recomputeDeptAgg(podInf.List(), usage, h.podRecords)
+	drift := deptAggDiff(h.deptAgg, agg)
+	h.podRecords = records
+	h.podUIDs = uids
+	h.deptAgg = agg
+	h.recomputeMu.Unlock()
+
+	deptAggDrift.Reset()
+	if len(drift) == 0 {
+		deptAggReconciles.WithLabelValues("clean").Inc()
+		return
+	}
+	deptAggReconciles.WithLabelValues("drift").Inc()
+	for _, d := range drift {
+		deptAggDrift.WithLabelValues(d.dept, d.class, d.resource).Set(d.value)
+		log.WithFields(log.Fields{logging.FieldDept: d.dept, logging.FieldClass: d.class, "resource": d.resource}).
+			Warnf("部门聚合偏差: 部门 %s 节点类型 %s %s 增量值比重算值多 %v，已按重算结果修正", d.dept, d.class, d.resource, d.value)
+	}
+	h.scheduleRebuild(rebuildDept)
+}
+
+// recomputeDeptAgg 由 pods 从头构建 Pod 记录、名称索引与部门聚合。
+// 运行中 Pod 的用量优先沿用 prev 中同一 UID 的记录，其次取 usage[namespace/name]，都没有时为 0；调用方需持有 recomputeMu
+func (h *ResourceHandler) recomputeDeptAgg(pods []*v1.Pod, usage map[string]v1.ResourceList, prev map[types.UID]podRecord) (map[types.UID]podRecord, map[string]types.UID, map[string]*deptAggItem) {
+	records := make(map[types.UID]podRecord)
+	uids := make(map[string]types.UID)
+	agg := make(map[string]*deptAggItem)
+	for _, pod := range pods {
+		key := pod.Namespace + "/" + pod.Name
+		rec, ok := h.podRecordOf(key, pod)
+		if !ok {
+			continue
+		}
+		if !rec.pending {
+			if old, ok := prev[pod.UID]; ok && !old.pending {
+				rec.usage = old.usage
+			} else if u, ok := usage[key]; ok {
+				rec.usage = u
+			} else {
+				rec.usage = zeroUsage()
+			}
+		}
+		a := agg[rec.dept]
+		if a == nil {
+			a = newDeptAggItem()
+			agg[rec.dept] = a
+		}
+		a.add(rec)
+		records[pod.UID] = rec
+		uids[key] = pod.UID
+	}
+	return records, uids, agg
+}
+
+// aggDrift 一项聚合偏差，value 为 增量值 - 重算值
+type aggDrift struct {
+	dept     string
+	class    string
+	resource string
+	value    float64
+}
+
+// deptAggDiff 比较增量与重算的部门聚合，返回非零的偏差
+func deptAggDiff(incremental, recomputed map[string]*deptAggItem) []aggDrift {
+	var out []aggDrift
+	depts := make(map[string]bool)
+	for d := range incremental {
+		depts[d] = true
+	}
+	for d := range recomputed {
+		depts[d] = true
+	}
+	for dept := range depts {
+		inc, rec := incremental[dept], recomputed[dept]
+		if inc == nil {
+			inc = newDeptAggItem()
+		}
+		if rec == nil {
+			rec = newDeptAggItem()
+		}
+		if inc.pods != rec.pods {
+			out = append(out, aggDrift{dept: dept, class: "all", resource: string(v1.ResourcePods), value: float64(inc.pods - rec.pods)})
+		}
+		for _, nc := range nodeClasses {
+			if p, q := inc.pendingPods[nc.Type], rec.pendingPods[nc.Type]; p != q {
+				out = append(out, aggDrift{dept: dept, class: string(nc.Type), resource: "pendingPods", value: float64(p - q)})
+			}
+			for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+				// 用量的 resource 标签为资源名，requests/limits 带前缀
+				for prefix, lists := range map[string][2]map[NodeType]v1.ResourceList{
+					"":          {inc.usage, rec.usage},
+					"requests.": {inc.requests, rec.requests},
+					"limits.":   {inc.limits, rec.limits},
+				} {
+					a, b := lists[0][nc.Type][name], lists[1][nc.Type][name]
+					if a.Cmp(b) != 0 {
+						out = append(out, aggDrift{dept: dept, class: string(nc.Type), resource: prefix + string(name),
+							value: a.AsApproximateFloat64() - b.AsApproximateFloat64()})
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// resourceListEqual 按数值比较两个 ResourceList
+func resourceListEqual(a, b v1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, q := range a {
+		other, ok := b[name]
+		if !ok || q.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -1,12 +1,16 @@
 package handler
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
-	log "github.com/sirupsen/logrus"
+	"k8s-admin-informer/pkg/authz"
 	"k8s-admin-informer/pkg/kubernetes/informer"
+	"k8s-admin-informer/pkg/logging"
 	"k8s-admin-informer/pkg/model"
 	"k8s-admin-informer/pkg/util"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -30,9 +34,19 @@ func (h *WorkloadHandler) GetWorkloadInstance(c *gin.Context) {
 	deployments := filterAppsByWorkloadType(req.Apps, "deployment")
 	statefulSets := filterAppsByWorkloadType(req.Apps, "statefulset")
 
+	scope := authz.ScopeFrom(c.Request.Context())
 	var apps []model.AppInstance
-	apps = append(apps, h.getAppInstance(deployments)...)
-	apps = append(apps, h.getAppInstance(statefulSets)...)
+	var denied []string
+	for _, group := range [][]model.App{deployments, statefulSets} {
+		instances, forbidden := h.getAppInstance(c.Request.Context(), scope, group)
+		apps = append(apps, instances...)
+		denied = append(denied, forbidden...)
+	}
+	// 请求中任一工作负载所属部门不可见时整体拒绝，避免返回部分 Pod 与事件
+	if len(denied) > 0 {
+		authz.Forbidden(c, "workloads in departments not visible to the caller: "+strings.Join(denied, ", "))
+		return
+	}
 
 	var response model.GetWorkloadInstanceResponse
 	response.Apps = apps
@@ -40,12 +54,12 @@ func (h *WorkloadHandler) GetWorkloadInstance(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *WorkloadHandler) getPodAndEvents(ns string, parentName string) []model.Instance {
+func (h *WorkloadHandler) getPodAndEvents(ctx context.Context, ns string, parentName string) []model.Instance {
 	var instances []model.Instance
 
 	pods, err := h.Handler.Informers[PodInformer].(*informer.PodInformer).GetPodsByNsAndParent(ns, parentName)
 	if err != nil {
-		log.Errorf("查询pod异常: %v", err)
+		logging.Object(PodInformer, ns, parentName).WithContext(ctx).Errorf("查询pod异常: %v", err)
 		return instances
 	}
 	for _, pod := range pods {
@@ -53,14 +67,14 @@ func (h *WorkloadHandler) getPodAndEvents(ns string, parentName string) []model.
 			continue
 		}
 		instance := model.Instance{Name: pod.Name}
-		events := h.Handler.Informers[EventInformer].(*informer.EventInformer).GetPodEvent(pod.Namespace, pod.Name)
+		events := h.Handler.Informers[EventInformer].(*informer.EventInformer).GetPodEvent(ctx, pod.Namespace, pod.Name)
 		var instEvents []model.InstanceEvent
 
 		if len(events) > 0 {
 			for _, event := range events {
 				asiaTime, err := util.ConvertUTCToAsiaShanghai(event.CreationTimestamp.Time)
 				if err != nil {
-					log.Errorf("解析时间出现错误:%v", err)
+					logging.Object(EventInformer, event.Namespace, event.Name).WithContext(ctx).Errorf("解析时间出现错误:%v", err)
 					asiaTime = event.CreationTimestamp.Time.Format(time.RFC3339)
 				}
 				instanceEvent := model.InstanceEvent{
@@ -79,35 +93,44 @@ func (h *WorkloadHandler) getPodAndEvents(ns string, parentName string) []model.
 	return instances
 }
 
-func (h *WorkloadHandler) getAppInstance(apps []model.App) []model.AppInstance {
-	var res []model.AppInstance
+// getAppInstance 查询工作负载及其 Pod、事件与 Service，所属部门（取自 Pod 模板或工作负载的 department 标签）
+// 对调用方不可见的工作负载不查询，以 namespace/name 返回在 denied 中
+func (h *WorkloadHandler) getAppInstance(ctx context.Context, scope *authz.Scope, apps []model.App) (res []model.AppInstance, denied []string) {
 
 	for _, app := range apps {
 		if app.WorkloadType == "deployment" {
-			deployments := h.Handler.Informers[DeploymentInformer].(*informer.DeploymentInformer).GetDeployments(app.Namespace, app.Name)
+			deployments := h.Handler.Informers[DeploymentInformer].(*informer.DeploymentInformer).GetDeployments(ctx, app.Namespace, app.Name)
 			for _, deployment := range deployments {
+				if dept, _ := workloadLabels(deployment.Labels, deployment.Spec.Template.Labels); !scope.AllowsNamespace(ctx, deployment.Namespace, dept) {
+					denied = append(denied, deployment.Namespace+"/"+deployment.Name)
+					continue
+				}
 				appInstance := model.AppInstance{
-					Instances:   h.getPodAndEvents(app.Namespace, app.Name),
+					Instances:   h.getPodAndEvents(ctx, app.Namespace, app.Name),
 					Name:        app.Name,
 					Namespace:   app.Namespace,
 					Ready:       deployment.Status.ReadyReplicas,
 					Total:       deployment.Status.Replicas,
-					Services:    h.getServices(app.Namespace, app.Name),
+					Services:    h.getServices(ctx, app.Namespace, app.Name),
 					Labels:      deployment.Labels,
 					Annotations: deployment.Annotations,
 				}
 				res = append(res, appInstance)
 			}
 		} else {
-			statefulSets := h.Handler.Informers[StatefulSetInformer].(*informer.StatefulSetInformer).GetStatefulSets(app.Namespace, app.Name)
+			statefulSets := h.Handler.Informers[StatefulSetInformer].(*informer.StatefulSetInformer).GetStatefulSets(ctx, app.Namespace, app.Name)
 			for _, statefulSet := range statefulSets {
+				if dept, _ := workloadLabels(statefulSet.Labels, statefulSet.Spec.Template.Labels); !scope.AllowsNamespace(ctx, statefulSet.Namespace, dept) {
+					denied = append(denied, statefulSet.Namespace+"/"+statefulSet.Name)
+					continue
+				}
 				appInstance := model.AppInstance{
-					Instances:   h.getPodAndEvents(app.Namespace, app.Name),
+					Instances:   h.getPodAndEvents(ctx, app.Namespace, app.Name),
 					Name:        app.Name,
 					Namespace:   app.Namespace,
 					Ready:       statefulSet.Status.ReadyReplicas,
 					Total:       statefulSet.Status.Replicas,
-					Services:    h.getServices(app.Namespace, app.Name),
+					Services:    h.getServices(ctx, app.Namespace, app.Name),
 					Labels:      statefulSet.Labels,
 					Annotations: statefulSet.Annotations,
 				}
@@ -115,13 +138,13 @@ func (h *WorkloadHandler) getAppInstance(apps []model.App) []model.AppInstance {
 			}
 		}
 	}
-	return res
+	return res, denied
 }
 
-func (h *WorkloadHandler) getServices(ns string, name string) []model.Service {
+func (h *WorkloadHandler) getServices(ctx context.Context, ns string, name string) []model.Service {
 	var res []model.Service
 
-	services := h.Handler.Informers[ServiceInformer].(*informer.ServiceInformer).GetServices(ns, name)
+	services := h.Handler.Informers[ServiceInformer].(*informer.ServiceInformer).GetServices(ctx, ns, name)
 
 	for _, service := range services {
 		if service != nil {
@@ -17,6 +17,7 @@ func NewKubernetesClientFromConfig(cfgPath string) (*kubernetes.Clientset, dynam
 		log.Errorf("获取本地kubeconfig失败: %v\n", err)
 		return nil, nil, nil, err
 	}
+	instrumentConfig(config)
 
 	// 创建kubernetes deploy
 	cs, err := kubernetes.NewForConfig(config)
@@ -46,6 +47,7 @@ func NewKubernetesClientInCluster() (*kubernetes.Clientset, dynamic.Interface, *
 		log.Errorf("获取集群内config失败: %v", err)
 		return nil, nil, nil, err
 	}
+	instrumentConfig(config)
 
 	cs, err := kubernetes.NewForConfig(config)
 	if err != nil {
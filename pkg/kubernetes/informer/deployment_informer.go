@@ -2,14 +2,14 @@ package informer
 
 import (
 	"context"
-	"fmt"
-	log "github.com/sirupsen/logrus"
 	appsV1 "k8s.io/api/apps/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+
+	"k8s-admin-informer/pkg/logging"
 	"time"
 )
 
@@ -24,7 +24,7 @@ func NewDeploymentInformer(cs *kubernetes.Clientset) *DeploymentInformer {
 				ListFunc: func(options metaV1.ListOptions) (runtime.Object, error) {
 					list, err := cs.AppsV1().Deployments(metaV1.NamespaceAll).List(context.TODO(), options)
 					if err != nil {
-						log.Errorf("list deployment 异常:%v", err)
+						logging.Informer(kindDeployment).Errorf("list deployment 异常:%v", err)
 						return nil, err
 					}
 					return list, err
@@ -32,7 +32,7 @@ func NewDeploymentInformer(cs *kubernetes.Clientset) *DeploymentInformer {
 				WatchFunc: func(options metaV1.ListOptions) (watch.Interface, error) {
 					w, err := cs.AppsV1().Deployments(metaV1.NamespaceAll).Watch(context.TODO(), options)
 					if err != nil {
-						log.Errorf("watch deployment 异常:%v", err)
+						logging.Informer(kindDeployment).Errorf("watch deployment 异常:%v", err)
 						return nil, err
 					}
 					return w, err
@@ -45,22 +45,6 @@ func NewDeploymentInformer(cs *kubernetes.Clientset) *DeploymentInformer {
 	}
 	indexFunc := genNamespaceDepIndexFunc()
 	deploymentInformer.AddIndexer(indexFunc, "namespaceDepIdx")
-
-	_, err := deploymentInformer.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: nil,
-		UpdateFunc: func(oldObj, newObj interface{}) {
-			oldDep := oldObj.(*appsV1.Deployment)
-			newDep := newObj.(*appsV1.Deployment)
-			if oldDep.Namespace == "tdd-m6-biz-docker" && oldDep.Name == "aut" {
-				fmt.Println(oldDep)
-				fmt.Println(newDep)
-			}
-		},
-		DeleteFunc: nil,
-	})
-	if err != nil {
-		return nil
-	}
 	return &deploymentInformer
 }
 
@@ -70,7 +54,7 @@ func (depInformer *DeploymentInformer) AddIndexer(idxFunc cache.IndexFunc, idxNa
 		idxName: idxFunc,
 	})
 	if err != nil {
-		log.Errorf("增加索引失败:%v", err)
+		logging.Informer(kindDeployment).Errorf("增加索引 %s 失败:%v", idxName, err)
 	}
 	//log.Infof("增加Deployment索引：%s", idxName)
 }
@@ -83,17 +67,17 @@ func genNamespaceDepIndexFunc() cache.IndexFunc {
 }
 
 // GetDeployments 查询deployment
-func (depInformer *DeploymentInformer) GetDeployments(ns string, name string) []*appsV1.Deployment {
+func (depInformer *DeploymentInformer) GetDeployments(ctx context.Context, ns string, name string) []*appsV1.Deployment {
 	var res []*appsV1.Deployment
 
 	if ns == "" || name == "" {
-		log.Errorf("namespace和name不能为空")
+		logging.Informer(kindDeployment).WithContext(ctx).Errorf("namespace和name不能为空")
 		return res
 	}
 
 	deployments, err := depInformer.informer.GetIndexer().ByIndex("namespaceDepIdx", ns+"/"+name)
 	if err != nil {
-		log.Errorf("根据namespace和name查询deployment异常:%v", err)
+		logging.Object(kindDeployment, ns, name).WithContext(ctx).Errorf("根据namespace和name查询deployment异常:%v", err)
 		return res
 	}
 
@@ -105,6 +89,18 @@ func (depInformer *DeploymentInformer) GetDeployments(ns string, name string) []
 	return res
 }
 
+// List 返回缓存中的全部 deployment
+func (depInformer *DeploymentInformer) List() []*appsV1.Deployment {
+	list := depInformer.informer.GetStore().List()
+	res := make([]*appsV1.Deployment, 0, len(list))
+	for _, obj := range list {
+		if w, ok := obj.(*appsV1.Deployment); ok {
+			res = append(res, w)
+		}
+	}
+	return res
+}
+
 func (depInformer *DeploymentInformer) Start(stopCh <-chan struct{}) {
 	depInformer.informer.Run(stopCh)
 }
@@ -113,3 +109,13 @@ func (depInformer *DeploymentInformer) HasSynced() bool {
 	return depInformer.informer.HasSynced()
 	//return true
 }
+
+// Len 返回缓存中的对象数
+func (depInformer *DeploymentInformer) Len() int {
+	return len(depInformer.informer.GetStore().ListKeys())
+}
+
+func (depInformer *DeploymentInformer) AddEventHandler(handler cache.ResourceEventHandler) error {
+	_, err := depInformer.informer.AddEventHandler(handler)
+	return err
+}
@@ -2,15 +2,19 @@ package informer
 
 import (
 	"context"
-	log "github.com/sirupsen/logrus"
+	"encoding/json"
+
 	"k8s-admin-informer/api/v1alpha1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/tools/cache"
+
+	"k8s-admin-informer/pkg/logging"
 )
 
 type DeptResourceQuotaInformer struct {
@@ -46,7 +50,7 @@ func (d *DeptResourceQuotaInformer) GetDeptResourceQuotaByName(dept string) *v1a
 		deptResourceQuota := &v1alpha1.DeptResourceQuota{}
 		err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deptResourceQuota)
 		if err != nil {
-			log.Errorf("failed to convert unstructed object: %v", err)
+			logging.Object(kindDeptResourceQuota, obj.GetNamespace(), obj.GetName()).Errorf("failed to convert unstructed object: %v", err)
 			continue
 		}
 
@@ -58,20 +62,26 @@ func (d *DeptResourceQuotaInformer) GetDeptResourceQuotaByName(dept string) *v1a
 }
 
 func (d *DeptResourceQuotaInformer) List() []*v1alpha1.DeptResourceQuota {
+	return d.ListContext(context.TODO())
+}
+
+// ListContext 同 List，请求 apiserver 时使用 ctx（超时与 trace）
+func (d *DeptResourceQuotaInformer) ListContext(ctx context.Context) []*v1alpha1.DeptResourceQuota {
 	var quotas []*v1alpha1.DeptResourceQuota
 	list, err := d.client.Resource(schema.GroupVersionResource{
 		Group:    "resource.wukong.io",
 		Version:  "v1alpha1",
 		Resource: "deptresourcequotas",
-	}).List(context.TODO(), metaV1.ListOptions{})
+	}).List(ctx, metaV1.ListOptions{})
 	if err != nil {
+		logging.Informer(kindDeptResourceQuota).WithContext(ctx).Errorf("list deptresourcequota 异常:%v", err)
 		return nil
 	}
 	for _, obj := range list.Items {
 		deptResourceQuota := &v1alpha1.DeptResourceQuota{}
 		err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deptResourceQuota)
 		if err != nil {
-			log.Errorf("failed to convert unstructed object: %v", err)
+			logging.Object(kindDeptResourceQuota, obj.GetNamespace(), obj.GetName()).Errorf("failed to convert unstructed object: %v", err)
 			continue
 		}
 
@@ -81,6 +91,20 @@ func (d *DeptResourceQuotaInformer) List() []*v1alpha1.DeptResourceQuota {
 	return quotas
 }
 
+// PatchStatus 以 merge patch 更新配额对象的 status 子资源
+func (d *DeptResourceQuotaInformer) PatchStatus(quota *v1alpha1.DeptResourceQuota, status v1alpha1.DeptResourceQuotaStatus) error {
+	patch, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return err
+	}
+	_, err = d.client.Resource(schema.GroupVersionResource{
+		Group:    "resource.wukong.io",
+		Version:  "v1alpha1",
+		Resource: "deptresourcequotas",
+	}).Namespace(quota.Namespace).Patch(context.TODO(), quota.Name, types.MergePatchType, patch, metaV1.PatchOptions{}, "status")
+	return err
+}
+
 func (d *DeptResourceQuotaInformer) Start(stopCh <-chan struct{}) {
 	//d.informer.Run(stopCh)
 }
@@ -88,3 +112,13 @@ func (d *DeptResourceQuotaInformer) Start(stopCh <-chan struct{}) {
 func (d *DeptResourceQuotaInformer) HasSynced() bool {
 	return d.informer.HasSynced()
 }
+
+// Len 返回缓存中的对象数
+func (d *DeptResourceQuotaInformer) Len() int {
+	return len(d.informer.GetStore().ListKeys())
+}
+
+func (d *DeptResourceQuotaInformer) AddEventHandler(handler cache.ResourceEventHandler) error {
+	_, err := d.informer.AddEventHandler(handler)
+	return err
+}
@@ -3,13 +3,14 @@ package informer
 import (
 	"context"
 
-	log "github.com/sirupsen/logrus"
 	coreV1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+
+	"k8s-admin-informer/pkg/logging"
 )
 
 type EventInformer struct {
@@ -54,13 +55,13 @@ func (eventInformer *EventInformer) AddIndexer(idxFunc cache.IndexFunc, idxName
 		idxName: idxFunc,
 	})
 	if err != nil {
-		log.Errorf("增加索引失败:%v", err)
+		logging.Informer(kindEvent).Errorf("增加索引 %s 失败:%v", idxName, err)
 	}
 	//log.Infof("增加Event索引：%s", idxName)
 }
 
 // GetPodEvent 获取pod事件
-func (eventInformer *EventInformer) GetPodEvent(ns string, pod string) []*coreV1.Event {
+func (eventInformer *EventInformer) GetPodEvent(ctx context.Context, ns string, pod string) []*coreV1.Event {
 	var res []*coreV1.Event
 	if ns == "" || pod == "" {
 		return res
@@ -68,7 +69,7 @@ func (eventInformer *EventInformer) GetPodEvent(ns string, pod string) []*coreV1
 
 	events, err := eventInformer.informer.GetIndexer().ByIndex("NamespaceIdx", ns)
 	if err != nil {
-		log.Errorf("查询event出现错误：%v", err)
+		logging.Object(kindEvent, ns, pod).WithContext(ctx).Errorf("查询event出现错误：%v", err)
 		return res
 	}
 
@@ -85,3 +86,13 @@ func (eventInformer *EventInformer) GetPodEvent(ns string, pod string) []*coreV1
 func (eventInformer *EventInformer) HasSynced() bool {
 	return eventInformer.informer.HasSynced()
 }
+
+// Len 返回缓存中的对象数
+func (eventInformer *EventInformer) Len() int {
+	return len(eventInformer.informer.GetStore().ListKeys())
+}
+
+func (eventInformer *EventInformer) AddEventHandler(handler cache.ResourceEventHandler) error {
+	_, err := eventInformer.informer.AddEventHandler(handler)
+	return err
+}
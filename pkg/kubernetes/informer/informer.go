@@ -1,6 +1,23 @@
 package informer
 
+import "k8s.io/client-go/tools/cache"
+
+// 日志中 informer 类型字段的取值
+const (
+	kindDeployment        = "deployment"
+	kindStatefulSet       = "statefulSet"
+	kindPod               = "pod"
+	kindNode              = "node"
+	kindService           = "service"
+	kindEvent             = "event"
+	kindDeptResourceQuota = "deptResourceQuota"
+)
+
 type Informer interface {
 	Start(stopCh <-chan struct{})
 	HasSynced() bool
+	// Len 返回缓存中的对象数
+	Len() int
+	// AddEventHandler 注册事件处理函数
+	AddEventHandler(handler cache.ResourceEventHandler) error
 }
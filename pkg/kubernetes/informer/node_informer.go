@@ -44,6 +44,15 @@ func (nodeInformer *NodeInformer) List() []*coreV1.Node {
 	return nodeList
 }
 
+// Get 根据节点名从缓存中获取节点
+func (nodeInformer *NodeInformer) Get(name string) (*coreV1.Node, bool) {
+	obj, exists, err := nodeInformer.informer.GetStore().GetByKey(name)
+	if err != nil || !exists {
+		return nil, false
+	}
+	return obj.(*coreV1.Node), true
+}
+
 func (nodeInformer *NodeInformer) Start(stopCh <-chan struct{}) {
 	nodeInformer.informer.Run(stopCh)
 }
@@ -52,7 +61,26 @@ func (nodeInformer *NodeInformer) HasSynced() bool {
 	return nodeInformer.informer.HasSynced()
 }
 
+// NodeFromObject 从事件对象中取出节点，删除事件中的 DeletedFinalStateUnknown 会被解开
+func NodeFromObject(obj interface{}) (*coreV1.Node, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(*coreV1.Node)
+	return node, ok
+}
+
 func (nodeInformer *NodeInformer) AddEventHandler(handler cache.ResourceEventHandler) error {
 	_, err := nodeInformer.informer.AddEventHandler(handler)
 	return err
 }
+
+// Store 返回本地缓存；informer 未启动时可直接增删其中的对象，用于在测试中模拟 list/watch 的结果
+func (nodeInformer *NodeInformer) Store() cache.Store {
+	return nodeInformer.informer.GetStore()
+}
+
+// Len 返回缓存中的对象数
+func (nodeInformer *NodeInformer) Len() int {
+	return len(nodeInformer.informer.GetStore().ListKeys())
+}
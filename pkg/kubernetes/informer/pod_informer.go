@@ -4,7 +4,6 @@ import (
 	"context"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	coreV1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -12,6 +11,8 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+
+	"k8s-admin-informer/pkg/logging"
 )
 
 type PodInformer struct {
@@ -65,7 +66,7 @@ func (podInformer *PodInformer) AddIndexer(idxFunc cache.IndexFunc, idxName stri
 		idxName: idxFunc,
 	})
 	if err != nil {
-		log.Errorf("增加索引失败:%v", err)
+		logging.Informer(kindPod).Errorf("增加索引 %s 失败:%v", idxName, err)
 	}
 	//log.Infof("增加Pod索引：%s", idxName)
 }
@@ -95,6 +96,15 @@ func (podInformer *PodInformer) HasSynced() bool {
 	return podInformer.informer.HasSynced()
 }
 
+// Get 根据 namespace/name 从缓存中获取 pod
+func (podInformer *PodInformer) Get(key string) (*coreV1.Pod, bool) {
+	obj, exists, err := podInformer.informer.GetStore().GetByKey(key)
+	if err != nil || !exists {
+		return nil, false
+	}
+	return obj.(*coreV1.Pod), true
+}
+
 func (podInformer *PodInformer) List() []*coreV1.Pod {
 	list := podInformer.informer.GetStore().List()
 	var res []*coreV1.Pod
@@ -105,6 +115,15 @@ func (podInformer *PodInformer) List() []*coreV1.Pod {
 	return res
 }
 
+// PodFromObject 从事件对象中取出 pod，删除事件中的 DeletedFinalStateUnknown 会被解开
+func PodFromObject(obj interface{}) (*coreV1.Pod, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*coreV1.Pod)
+	return pod, ok
+}
+
 func (podInformer *PodInformer) AddEventHandler(handler cache.ResourceEventHandler) error {
 	_, err := podInformer.informer.AddEventHandler(handler)
 	return err
@@ -123,3 +142,13 @@ func (podInformer *PodInformer) ListBySelector(ls labels.Set) []coreV1.Pod {
 
 	return pods
 }
+
+// Store 返回本地缓存；informer 未启动时可直接增删其中的对象，用于在测试中模拟 list/watch 的结果
+func (podInformer *PodInformer) Store() cache.Store {
+	return podInformer.informer.GetStore()
+}
+
+// Len 返回缓存中的对象数
+func (podInformer *PodInformer) Len() int {
+	return len(podInformer.informer.GetStore().ListKeys())
+}
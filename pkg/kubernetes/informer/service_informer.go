@@ -4,13 +4,14 @@ import (
 	"context"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	coreV1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+
+	"k8s-admin-informer/pkg/logging"
 )
 
 type ServiceInformer struct {
@@ -44,7 +45,7 @@ func (serviceInformer *ServiceInformer) AddIndexer(idxFunc cache.IndexFunc, idxN
 		idxName: idxFunc,
 	})
 	if err != nil {
-		log.Errorf("增加索引失败:%v", err)
+		logging.Informer(kindService).Errorf("增加索引 %s 失败:%v", idxName, err)
 	}
 	//log.Infof("增加Service索引：%s", idxName)
 }
@@ -57,17 +58,17 @@ func genNamespaceServiceIndexFunc() cache.IndexFunc {
 }
 
 // GetDeployments 查询deployment
-func (serviceInformer *ServiceInformer) GetServices(ns string, name string) []*coreV1.Service {
+func (serviceInformer *ServiceInformer) GetServices(ctx context.Context, ns string, name string) []*coreV1.Service {
 	var res []*coreV1.Service
 
 	if ns == "" || name == "" {
-		log.Errorf("namespace和name不能为空")
+		logging.Informer(kindService).WithContext(ctx).Errorf("namespace和name不能为空")
 		return res
 	}
 
 	services, err := serviceInformer.informer.GetIndexer().ByIndex("namespaceSvcIdx", ns+"/"+name)
 	if err != nil {
-		log.Errorf("根据namespace和name查询deployment异常:%v", err)
+		logging.Object(kindService, ns, name).WithContext(ctx).Errorf("根据namespace和name查询service异常:%v", err)
 		return res
 	}
 
@@ -86,3 +87,13 @@ func (serviceInformer *ServiceInformer) Start(stopCh <-chan struct{}) {
 func (serviceInformer *ServiceInformer) HasSynced() bool {
 	return serviceInformer.informer.HasSynced()
 }
+
+// Len 返回缓存中的对象数
+func (serviceInformer *ServiceInformer) Len() int {
+	return len(serviceInformer.informer.GetStore().ListKeys())
+}
+
+func (serviceInformer *ServiceInformer) AddEventHandler(handler cache.ResourceEventHandler) error {
+	_, err := serviceInformer.informer.AddEventHandler(handler)
+	return err
+}
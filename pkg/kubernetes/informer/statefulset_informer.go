@@ -2,13 +2,14 @@ package informer
 
 import (
 	"context"
-	log "github.com/sirupsen/logrus"
 	appsV1 "k8s.io/api/apps/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+
+	"k8s-admin-informer/pkg/logging"
 	"time"
 )
 
@@ -43,7 +44,7 @@ func (statefulSetInformer *StatefulSetInformer) AddIndexer(idxFunc cache.IndexFu
 		idxName: idxFunc,
 	})
 	if err != nil {
-		log.Errorf("增加索引失败:%v", err)
+		logging.Informer(kindStatefulSet).Errorf("增加索引 %s 失败:%v", idxName, err)
 	}
 	//log.Infof("增加StatefulSet索引：%s", idxName)
 }
@@ -56,17 +57,17 @@ func genNamespaceStatIndexFunc() cache.IndexFunc {
 }
 
 // GetStatefulSets 查询statefulSets
-func (statefulSetInformer *StatefulSetInformer) GetStatefulSets(ns string, name string) []*appsV1.StatefulSet {
+func (statefulSetInformer *StatefulSetInformer) GetStatefulSets(ctx context.Context, ns string, name string) []*appsV1.StatefulSet {
 	var res []*appsV1.StatefulSet
 
 	if ns == "" || name == "" {
-		log.Errorf("namespace和name不能为空")
+		logging.Informer(kindStatefulSet).WithContext(ctx).Errorf("namespace和name不能为空")
 		return res
 	}
 
 	statefulSets, err := statefulSetInformer.informer.GetIndexer().ByIndex("namespaceStatIdx", ns+"/"+name)
 	if err != nil {
-		log.Errorf("根据namespace和name查询statefulSet异常:%v", err)
+		logging.Object(kindStatefulSet, ns, name).WithContext(ctx).Errorf("根据namespace和name查询statefulSet异常:%v", err)
 		return res
 	}
 
@@ -78,6 +79,18 @@ func (statefulSetInformer *StatefulSetInformer) GetStatefulSets(ns string, name
 	return res
 }
 
+// List 返回缓存中的全部 statefulSet
+func (statefulSetInformer *StatefulSetInformer) List() []*appsV1.StatefulSet {
+	list := statefulSetInformer.informer.GetStore().List()
+	res := make([]*appsV1.StatefulSet, 0, len(list))
+	for _, obj := range list {
+		if w, ok := obj.(*appsV1.StatefulSet); ok {
+			res = append(res, w)
+		}
+	}
+	return res
+}
+
 func (statefulSetInformer *StatefulSetInformer) Start(stopCh <-chan struct{}) {
 	statefulSetInformer.informer.Run(stopCh)
 }
@@ -85,3 +98,13 @@ func (statefulSetInformer *StatefulSetInformer) Start(stopCh <-chan struct{}) {
 func (statefulSetInformer *StatefulSetInformer) HasSynced() bool {
 	return statefulSetInformer.informer.HasSynced()
 }
+
+// Len 返回缓存中的对象数
+func (statefulSetInformer *StatefulSetInformer) Len() int {
+	return len(statefulSetInformer.informer.GetStore().ListKeys())
+}
+
+func (statefulSetInformer *StatefulSetInformer) AddEventHandler(handler cache.ResourceEventHandler) error {
+	_, err := statefulSetInformer.informer.AddEventHandler(handler)
+	return err
+}
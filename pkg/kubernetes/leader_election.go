@@ -0,0 +1,48 @@
+package kubernetes
+
+import (
+	"context"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// RunWithLeaderElection 基于 Lease 进行选主，成为 leader 后调用 run，失去 leader 身份时取消 run 的 ctx；
+// 失去 leader 后重新参与选举，直到 ctx 结束
+func RunWithLeaderElection(ctx context.Context, cs kubernetes.Interface, namespace, name string, run func(ctx context.Context)) {
+	identity, err := os.Hostname()
+	if err != nil || identity == "" {
+		identity = string(uuid.NewUUID())
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metaV1.ObjectMeta{Namespace: namespace, Name: name},
+		Client:     cs.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			ReleaseOnCancel: true,
+			LeaseDuration:   15 * time.Second,
+			RenewDeadline:   10 * time.Second,
+			RetryPeriod:     2 * time.Second,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: run,
+				OnStoppedLeading: func() {
+					log.Infof("%s 不再是 %s/%s 的 leader", identity, namespace, name)
+				},
+				OnNewLeader: func(current string) {
+					if current != identity {
+						log.Infof("%s/%s 当前 leader: %s", namespace, name, current)
+					}
+				},
+			},
+		})
+	}
+}
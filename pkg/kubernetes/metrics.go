@@ -0,0 +1,92 @@
+package kubernetes
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/rest"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+
+	"k8s-admin-informer/pkg/tracing"
+)
+
+var (
+	restClientRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rest_client_requests_total",
+			Help: "Requests to the apiserver by HTTP method and status code.",
+		},
+		[]string{"method", "code"},
+	)
+	restClientLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rest_client_request_duration_seconds",
+			Help:    "Apiserver request latency by verb.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"verb"},
+	)
+	restClientThrottle = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rest_client_rate_limiter_duration_seconds",
+			Help:    "Time requests waited on the client-side rate limiter by verb.",
+			Buckets: []float64{0.005, 0.025, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+		},
+		[]string{"verb"},
+	)
+	watchStarts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "apiserver_watch_starts_total",
+			Help: "Watch requests started against the apiserver by resource; increases beyond one per informer are watch restarts.",
+		},
+		[]string{"resource"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(restClientRequests, restClientLatency, restClientThrottle, watchStarts)
+	clientmetrics.Register(clientmetrics.RegisterOpts{
+		RequestLatency:     latencyAdapter{restClientLatency},
+		RateLimiterLatency: latencyAdapter{restClientThrottle},
+		RequestResult:      resultAdapter{},
+	})
+}
+
+type latencyAdapter struct {
+	m *prometheus.HistogramVec
+}
+
+func (l latencyAdapter) Observe(_ context.Context, verb string, _ url.URL, latency time.Duration) {
+	l.m.WithLabelValues(verb).Observe(latency.Seconds())
+}
+
+type resultAdapter struct{}
+
+func (resultAdapter) Increment(_ context.Context, code, method, _ string) {
+	restClientRequests.WithLabelValues(method, code).Inc()
+}
+
+// instrumentConfig 为客户端增加 watch 请求计数（每次 informer 重新建立 watch 都会经过这里）与出站请求 span，
+// 由同一 config 创建的 clientset、dynamic 与 metrics 客户端共用
+func instrumentConfig(config *rest.Config) {
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return watchCounter{next: rt}
+	})
+	config.Wrap(tracing.WrapTransport)
+}
+
+type watchCounter struct {
+	next http.RoundTripper
+}
+
+func (w watchCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("watch") == "true" {
+		segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+		watchStarts.WithLabelValues(segments[len(segments)-1]).Inc()
+	}
+	return w.next.RoundTrip(req)
+}
@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// 统一的日志字段名
+const (
+	FieldRequestID = "request_id"
+	FieldKind      = "kind"
+	FieldNamespace = "namespace"
+	FieldName      = "name"
+	FieldDept      = "dept"
+	FieldClass     = "class"
+)
+
+// RequestIDHeader 请求 ID 所在的请求/响应头
+const RequestIDHeader = "X-Request-Id"
+
+// 日志格式
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Setup 按环境变量配置全局 logrus：
+// - LOG_FORMAT json（默认）或 text
+// - LOG_LEVEL trace/debug/info（默认）/warn/error，运行时可通过管理接口调整
+func Setup() {
+	switch format := strings.ToLower(os.Getenv("LOG_FORMAT")); format {
+	case FormatText:
+		log.SetFormatter(&log.TextFormatter{FullTimestamp: true, TimestampFormat: time.RFC3339Nano})
+	case "", FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	default:
+		log.SetFormatter(&log.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+		log.Warnf("未知的 LOG_FORMAT %q，使用默认值 %s", format, FormatJSON)
+	}
+
+	log.SetLevel(log.InfoLevel)
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if level, err := log.ParseLevel(v); err == nil {
+			log.SetLevel(level)
+		} else {
+			log.Warnf("解析 LOG_LEVEL 失败，使用默认值 %s，错误: %v", log.InfoLevel.String(), err)
+		}
+	}
+	log.AddHook(contextHook{})
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 返回携带请求 ID 的 context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom 返回 context 中的请求 ID，没有时为空
+func RequestIDFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID 为每个请求分配请求 ID：沿用请求头中的 X-Request-Id，没有时生成新的，并写回响应头；
+// 处理请求期间通过 log.WithContext(c.Request.Context()) 输出的日志都带有 request_id 字段
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// AccessLog 通过 logrus 输出访问日志，格式随 LOG_FORMAT（默认 JSON），每行带有 request_id；
+// 需安装在 RequestID 之前，请求处理完成后才读取 context 中的请求 ID。5xx 记为 error，其余记为 info
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		entry := log.WithContext(c.Request.Context()).WithFields(log.Fields{
+			"method":     c.Request.Method,
+			"path":       path,
+			"route":      c.FullPath(),
+			"status":     c.Writer.Status(),
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip":  c.ClientIP(),
+			"bytes":      c.Writer.Size(),
+		})
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			entry = entry.WithField("errors", errs)
+		}
+		if c.Writer.Status() >= 500 {
+			entry.Errorf("%s %s %d", c.Request.Method, path, c.Writer.Status())
+			return
+		}
+		entry.Infof("%s %s %d", c.Request.Method, path, c.Writer.Status())
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// contextHook 为带 context 的日志补充 request_id 字段
+type contextHook struct{}
+
+func (contextHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (contextHook) Fire(entry *log.Entry) error {
+	if id := RequestIDFrom(entry.Context); id != "" {
+		entry.Data[FieldRequestID] = id
+	}
+	return nil
+}
+
+// Informer 返回带 informer 类型字段的日志入口
+func Informer(kind string) *log.Entry {
+	return log.WithField(FieldKind, kind)
+}
+
+// Object 返回带类型与 namespace/name 字段的日志入口
+func Object(kind, namespace, name string) *log.Entry {
+	return log.WithFields(log.Fields{FieldKind: kind, FieldNamespace: namespace, FieldName: name})
+}
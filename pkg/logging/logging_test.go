@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestAccessLogCarriesRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	out, formatter, hooks := log.StandardLogger().Out, log.StandardLogger().Formatter, log.StandardLogger().Hooks
+	log.SetOutput(&buf)
+	log.SetFormatter(&log.JSONFormatter{})
+	log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	log.AddHook(contextHook{})
+	t.Cleanup(func() {
+		log.SetOutput(out)
+		log.SetFormatter(formatter)
+		log.StandardLogger().ReplaceHooks(hooks)
+	})
+
+	engine := gin.New()
+	engine.Use(AccessLog(), gin.Recovery(), RequestID())
+	engine.GET("/items/:id", func(c *gin.Context) {
+		log.WithContext(c.Request.Context()).Info("handling")
+		c.Status(http.StatusNoContent)
+	})
+	engine.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	for _, tc := range []struct {
+		path, route string
+		status      int
+		level       string
+	}{
+		{path: "/items/1", route: "/items/:id", status: http.StatusNoContent, level: "info"},
+		{path: "/panic", route: "/panic", status: http.StatusInternalServerError, level: "error"},
+	} {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set(RequestIDHeader, "req-"+tc.route)
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+
+		var access map[string]interface{}
+		for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+			var entry map[string]interface{}
+			if err := json.Unmarshal(line, &entry); err != nil {
+				t.Fatalf("%s: log line is not JSON: %s", tc.path, line)
+			}
+			if entry[FieldRequestID] != "req-"+tc.route {
+				t.Fatalf("%s: log line without request_id: %s", tc.path, line)
+			}
+			if _, ok := entry["status"]; ok {
+				access = entry
+			}
+		}
+		if access == nil {
+			t.Fatalf("%s: no access log line in %s", tc.path, buf.String())
+		}
+		if access["route"] != tc.route || access["status"] != float64(tc.status) || access["level"] != tc.level {
+			t.Fatalf("%s: unexpected access log %v", tc.path, access)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package model
+
+// LogLevel 当前或要设置的日志级别：trace、debug、info、warn、error
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// HealthStatus 健康检查结果，Informers 为各 informer 是否已完成同步（仅 readyz 返回）
+type HealthStatus struct {
+	Status    string          `json:"status"`
+	Informers map[string]bool `json:"informers,omitempty"`
+}
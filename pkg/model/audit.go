@@ -0,0 +1,41 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditRecord 一条审计记录：配额校验结论或写操作
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId,omitempty"`
+	// Action checkLimit、checkManifest、admission 或 write
+	Action string      `json:"action"`
+	Method string      `json:"method,omitempty"`
+	Path   string      `json:"path,omitempty"`
+	Status int         `json:"status,omitempty"`
+	Caller AuditCaller `json:"caller"`
+	Dept   string      `json:"dept,omitempty"`
+	// Object 校验对象，工作负载为 kind namespace/name
+	Object string `json:"object,omitempty"`
+	// Allowed 配额校验结论，写操作记录不设置
+	Allowed *bool  `json:"allowed,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	// Request 校验的输入
+	Request json.RawMessage `json:"request,omitempty"`
+	// Checks 校验时的配额、已用、已预留与申请量
+	Checks        []QuotaCheck `json:"checks,omitempty"`
+	ReservationID string       `json:"reservationId,omitempty"`
+}
+
+// AuditCaller 发起操作的调用方，未启用认证时只有来源地址
+type AuditCaller struct {
+	Name       string   `json:"name,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	Source     string   `json:"source,omitempty"`
+	RemoteAddr string   `json:"remoteAddr,omitempty"`
+}
+
+type AuditRecordList struct {
+	Items []AuditRecord `json:"items"`
+}
@@ -1,8 +1,13 @@
 package model
 
+// ClusterResource 按节点类型汇总：XcLimitsResources/NonXcLimitsResources 为已调度 Pod 的有效 limits 内存（兼容旧字段），
+// Used 为节点实时用量（metrics-server），Requests/Limits 为已调度 Pod 的有效 requests/limits
 type ClusterResource struct {
-	XcLimitsResources    XcLimitsResources `json:"xcLimitsResources,omitempty"`
-	NonXcLimitsResources map[string]string `json:"nonXcLimitsResources,omitempty"`
+	XcLimitsResources    XcLimitsResources     `json:"xcLimitsResources,omitempty"`
+	NonXcLimitsResources map[string]string     `json:"nonXcLimitsResources,omitempty"`
+	Used                 ClassComputeResources `json:"used"`
+	Requests             ClassComputeResources `json:"requests"`
+	Limits               ClassComputeResources `json:"limits"`
 }
 
 type XcLimitsResources struct {
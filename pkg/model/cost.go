@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// CostAmount 一段时间内累计的计费量与成本：cpu 以核·小时、内存以 GiB·小时计
+type CostAmount struct {
+	CPUCoreHours   float64 `json:"cpuCoreHours"`
+	MemoryGiBHours float64 `json:"memoryGiBHours"`
+	CPUCost        float64 `json:"cpuCost"`
+	MemoryCost     float64 `json:"memoryCost"`
+	Cost           float64 `json:"cost"`
+}
+
+// CostItem 报表中一个部门（或部门下的环境、命名空间）的成本，Classes 以节点类型为键
+type CostItem struct {
+	Dept      string                `json:"dept"`
+	Env       string                `json:"env,omitempty"`
+	Namespace string                `json:"namespace,omitempty"`
+	Classes   map[string]CostAmount `json:"classes"`
+	// Cost 按计费依据直接归属的成本
+	Cost float64 `json:"cost"`
+	// IdleCost idle=split 时按各节点类型成本占比分摊到的闲置成本
+	IdleCost  float64 `json:"idleCost,omitempty"`
+	TotalCost float64 `json:"totalCost"`
+}
+
+// CostReport 一段时间内的成本报表
+type CostReport struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Currency string    `json:"currency,omitempty"`
+	// Basis 计费依据，多项时逐资源取最大值
+	Basis []string `json:"basis"`
+	// GroupBy dept、env 或 namespace
+	GroupBy string `json:"groupBy"`
+	// IdleMode none、separate 或 split
+	IdleMode string     `json:"idleMode"`
+	Items    []CostItem `json:"items"`
+	// Idle idle=separate 时未分摊的闲置成本，以节点类型为键，仅对可见全部部门的调用方返回
+	Idle map[string]CostAmount `json:"idle,omitempty"`
+	// Total 各项 TotalCost 与返回的闲置成本之和
+	Total float64 `json:"total"`
+}
@@ -1,10 +1,45 @@
 package model
 
+import "encoding/json"
+
 type DeptResourceQuotaRequest struct {
 	Dept                  string `json:"dept"`
-	RequestNonXcMemory    string `json:"requestNonXcMemory"`
-	RequestKylinArmMemory string `json:"requestKylinArmMemory"`
-	RequestKylinHgMemory  string `json:"requestKylinHgMemory"`
+	RequestNonXcMemory    string `json:"requestNonXcMemory,omitempty"`
+	RequestKylinArmMemory string `json:"requestKylinArmMemory,omitempty"`
+	RequestKylinHgMemory  string `json:"requestKylinHgMemory,omitempty"`
+	// Requests 按节点类型（nonXc、xcX86、xcArm）给出本次申请的 cpu/memory/pods，
+	// 与上面三个内存字段同时出现时两者累加
+	Requests map[string]map[string]string `json:"requests,omitempty"`
+	// Reserve 非空时，检查通过后在 TTL 内预留本次申请量
+	Reserve *ReserveOptions `json:"reserve,omitempty"`
+}
+
+// DeptResourceQuotaResponse 部门配额校验结果，未通过时 Reason 给出原因，
+// Checks 列出每个节点类型、每项资源的配额、已用、申请、剩余与结论
+type DeptResourceQuotaResponse struct {
+	Success bool         `json:"success"`
+	Reason  string       `json:"reason,omitempty"`
+	Checks  []QuotaCheck `json:"checks,omitempty"`
+	// Reservation 请求了预留且检查通过时返回的预留
+	Reservation *Reservation `json:"reservation,omitempty"`
+}
+
+// QuotaCheck 单个节点类型上单项资源的校验明细。
+// Remaining 为申请前的剩余额度（配额 - 已用 - 已预留，可能为负），Passed 表示申请量在剩余额度之内：
+// memory 未设置配额时按 0 计，且申请量须小于剩余额度；cpu/pods 未设置配额时 Limited 为 false 且不限制，申请量可等于剩余额度
+type QuotaCheck struct {
+	Class    string   `json:"class"`
+	Resource string   `json:"resource"`
+	Limited  bool     `json:"limited"`
+	Quota    Quantity `json:"quota"`
+	Used     Quantity `json:"used"`
+	// UsedFrom status（配额状态中宣布的用量）或 pods（PodInformer 汇总），取两者中较大的一方
+	UsedFrom string `json:"usedFrom"`
+	// Reserved 部门未过期预留量，计入剩余额度
+	Reserved  Quantity `json:"reserved"`
+	Requested Quantity `json:"requested"`
+	Remaining Quantity `json:"remaining"`
+	Passed    bool     `json:"passed"`
 }
 
 type ResourceLimits struct {
@@ -30,24 +65,89 @@ type Announced struct {
 	XC    SubResource    `json:"xc,omitempty"`
 }
 
+// UsedResource metrics-server 实时用量
 type UsedResource struct {
 	NonXc struct {
+		Cpu    string `json:"cpu,omitempty"`
 		Memory string `json:"memory,omitempty"`
 	} `json:"nonXc,omitempty"`
 	XC struct {
 		Arm struct {
+			Cpu    string `json:"cpu,omitempty"`
 			Memory string `json:"memory,omitempty"`
 		} `json:"arm,omitempty"`
 		X86 struct {
+			Cpu    string `json:"cpu,omitempty"`
 			Memory string `json:"memory,omitempty"`
 		} `json:"x86,omitempty"`
 	} `json:"xc,omitempty"`
 }
 
+// PendingDemand 某类节点上尚未调度的 Pod 数与其内存 requests 之和
+type PendingDemand struct {
+	Pods   int    `json:"pods"`
+	Memory string `json:"memory"`
+}
+
+// PendingResource 按节点类型的待调度需求，节点类型由 nodeSelector/亲和性推断
+type PendingResource struct {
+	NonXc PendingDemand `json:"nonXc"`
+	XC    struct {
+		Arm PendingDemand `json:"arm"`
+		X86 PendingDemand `json:"x86"`
+	} `json:"xc"`
+}
+
+// DeptResource 部门资源：Used 为实时用量，Requests/Limits 为已调度 Pod 的有效 requests/limits 之和，
+// Pods 为已调度且未结束的 Pod 数，未调度的 Pod 计入 Pending
 type DeptResource struct {
-	Name      string       `json:"name"`
-	Resources Resources    `json:"resources"`
-	Announced Announced    `json:"announced,omitempty"`
-	Used      UsedResource `json:"used,omitempty"`
-	Pods      int          `json:"pods,omitempty"`
+	Name      string                `json:"name"`
+	Resources Resources             `json:"resources"`
+	Announced Announced             `json:"announced,omitempty"`
+	Used      UsedResource          `json:"used,omitempty"`
+	Requests  ClassComputeResources `json:"requests"`
+	Limits    ClassComputeResources `json:"limits"`
+	Pods      int                   `json:"pods,omitempty"`
+	Pending   PendingResource       `json:"pending"`
+}
+
+// WorkloadRef 指向缓存中已有的工作负载，Kind 为 deployment 或 statefulset
+type WorkloadRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// ManifestQuotaRequest 基于工作负载清单的配额检查请求，Manifest 与 Workload+ReplicaDelta 二选一：
+// Manifest 为完整的 Deployment/StatefulSet（JSON 对象，或 JSON/YAML 文本）；
+// Workload+ReplicaDelta 表示对缓存中已有工作负载扩缩容
+type ManifestQuotaRequest struct {
+	// Dept 缺省时取 Pod 模板或工作负载上的 department 标签
+	Dept         string          `json:"dept,omitempty"`
+	Manifest     json.RawMessage `json:"manifest,omitempty"`
+	Workload     *WorkloadRef    `json:"workload,omitempty"`
+	ReplicaDelta *int32          `json:"replicaDelta,omitempty"`
+	// Reserve 非空时，检查通过后预留申请量；自动释放条件缺省为工作负载所在命名空间、名称与新增副本数
+	Reserve *ReserveOptions `json:"reserve,omitempty"`
+}
+
+// ManifestWorkload 说明申请量是如何从清单推算出来的
+type ManifestWorkload struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Replicas  int32  `json:"replicas"`
+	// Existing 工作负载已存在于缓存中，申请量为新旧差值
+	Existing         bool            `json:"existing"`
+	ExistingReplicas int32           `json:"existingReplicas,omitempty"`
+	Class            string          `json:"class"`
+	ClassSource      string          `json:"classSource"`
+	PodLimits        ResourceAmounts `json:"podLimits"`
+	Requested        ResourceAmounts `json:"requested"`
+}
+
+type ManifestQuotaResponse struct {
+	DeptResourceQuotaResponse
+	Dept     string           `json:"dept"`
+	Workload ManifestWorkload `json:"workload"`
 }
@@ -1,5 +1,6 @@
 package model
 
+// EnvResource 部门某环境（namespaceGroup）按节点类型的实时用量与有效 requests/limits
 type EnvResource struct {
 	Dept          string        `json:"dept,omitempty"`
 	EnvName       string        `json:"envName,omitempty"`
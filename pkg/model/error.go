@@ -0,0 +1,6 @@
+package model
+
+// ErrorResponse 接口出错时返回的统一结构
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
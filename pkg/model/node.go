@@ -4,9 +4,12 @@ type NodeList struct {
 	Items []Node `json:"items,omitempty"`
 }
 
+// Node 节点资源：Used 为 metrics-server 实时用量，Requests/Limits 为节点上 Pod 的有效 requests/limits 之和
 type Node struct {
 	Name        string            `json:"name"`
 	Type        string            `json:"type"`
 	Allocatable map[string]string `json:"allocatable,omitempty"`
 	Used        map[string]string `json:"used,omitempty"`
+	Requests    map[string]string `json:"requests,omitempty"`
+	Limits      map[string]string `json:"limits,omitempty"`
 }
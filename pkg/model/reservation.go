@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// ReserveOptions 检查通过后预留申请量的参数
+type ReserveOptions struct {
+	// TTL 预留有效期（Go duration，如 10m），缺省取服务端默认值，超过上限按上限
+	TTL string `json:"ttl,omitempty"`
+	// Namespace/Release/Pods 为自动释放条件：命名空间内 release 标签为 Release、
+	// 且在预留之后创建的 Pod 出现 Pods 个时释放；不设置时只能显式释放或等待过期
+	Namespace string `json:"namespace,omitempty"`
+	Release   string `json:"release,omitempty"`
+	Pods      int32  `json:"pods,omitempty"`
+}
+
+// Reservation 一条配额预留
+type Reservation struct {
+	ID   string `json:"id"`
+	Dept string `json:"dept"`
+	// Requests 以节点类型为键的预留量
+	Requests  map[string]ResourceAmounts `json:"requests"`
+	Namespace string                     `json:"namespace,omitempty"`
+	Release   string                     `json:"release,omitempty"`
+	Pods      int32                      `json:"pods,omitempty"`
+	// SeenPods 已出现的匹配 Pod 数
+	SeenPods  int       `json:"seenPods,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type ReservationList struct {
+	Items []Reservation `json:"items"`
+}
@@ -2,8 +2,11 @@ package model
 
 import "k8s.io/apimachinery/pkg/api/resource"
 
+// CommonResource 一组 Pod 的实时用量与有效 requests/limits 之和
 type CommonResource struct {
-	Limits ComputationResources `json:"limits,omitempty"`
+	Limits   ComputationResources `json:"limits,omitempty"`
+	Requests ComputationResources `json:"requests,omitempty"`
+	Used     ComputationResources `json:"used,omitempty"`
 }
 
 type NonXcResource struct {
@@ -19,3 +22,12 @@ type ComputationResources struct {
 	Cpu    *resource.Quantity `json:"cpu,omitempty"`
 	Memory *resource.Quantity `json:"memory,omitempty"`
 }
+
+// ClassComputeResources 按节点类型给出 cpu/memory
+type ClassComputeResources struct {
+	NonXc map[string]string `json:"nonXc"`
+	XC    struct {
+		Arm map[string]string `json:"arm"`
+		X86 map[string]string `json:"x86"`
+	} `json:"xc"`
+}
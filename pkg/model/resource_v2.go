@@ -0,0 +1,137 @@
+package model
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// 数值单位
+const (
+	UnitMillicores = "millicores"
+	UnitBytes      = "bytes"
+	UnitCount      = "count"
+)
+
+// Quantity 同时给出 Kubernetes 规范化字符串与数值：cpu 为毫核，内存/存储类为字节，其余为个数
+type Quantity struct {
+	Quantity string `json:"quantity"`
+	Value    int64  `json:"value"`
+	Unit     string `json:"unit"`
+}
+
+// ResourceAmounts 以资源名（cpu、memory、pods 及扩展资源）为键
+type ResourceAmounts map[string]Quantity
+
+// NewQuantity 按资源名换算数值
+func NewQuantity(name v1.ResourceName, q resource.Quantity) Quantity {
+	out := Quantity{Quantity: q.String()}
+	switch {
+	case name == v1.ResourceCPU:
+		out.Value = q.MilliValue()
+		out.Unit = UnitMillicores
+	case name == v1.ResourceMemory, name == v1.ResourceStorage, name == v1.ResourceEphemeralStorage,
+		strings.HasPrefix(string(name), v1.ResourceHugePagesPrefix):
+		out.Value = q.Value()
+		out.Unit = UnitBytes
+	default:
+		out.Value = q.Value()
+		out.Unit = UnitCount
+	}
+	return out
+}
+
+// NewResourceAmounts 将 ResourceList 转换为 ResourceAmounts，nil 返回空集合
+func NewResourceAmounts(list v1.ResourceList) ResourceAmounts {
+	out := make(ResourceAmounts, len(list))
+	for name, q := range list {
+		out[string(name)] = NewQuantity(name, q)
+	}
+	return out
+}
+
+// ResourceBounds 一组 requests/limits
+type ResourceBounds struct {
+	Requests ResourceAmounts `json:"requests"`
+	Limits   ResourceAmounts `json:"limits"`
+}
+
+// NodeClassResourceV2 部门在某一类节点上的资源：
+// quota 为配额，announced 为配额对象 status 中宣布的用量，used 为 metrics 实时用量，
+// requested/limited 为部门已调度 Pod 的有效 requests/limits 之和（requested 中的 pods 为 Pod 数），
+// pending 为推断调度到该类节点、尚未调度的 Pod
+type NodeClassResourceV2 struct {
+	Class     string          `json:"class"`
+	OS        string          `json:"os"`
+	Arch      string          `json:"arch"`
+	Quota     ResourceBounds  `json:"quota"`
+	Announced ResourceBounds  `json:"announced"`
+	Used      ResourceAmounts `json:"used"`
+	Requested ResourceAmounts `json:"requested"`
+	Limited   ResourceAmounts `json:"limited"`
+	Pending   PendingDemandV2 `json:"pending"`
+}
+
+// PendingDemandV2 尚未调度的 Pod 数与其 requests 之和
+type PendingDemandV2 struct {
+	Pods     int             `json:"pods"`
+	Requests ResourceAmounts `json:"requests"`
+}
+
+type DeptResourceV2 struct {
+	Name        string                `json:"name"`
+	Pods        int                   `json:"pods"`
+	PendingPods int                   `json:"pendingPods"`
+	Classes     []NodeClassResourceV2 `json:"classes"`
+}
+
+type NodeV2 struct {
+	Name        string          `json:"name"`
+	Class       string          `json:"class"`
+	OS          string          `json:"os"`
+	Arch        string          `json:"arch"`
+	Capacity    ResourceAmounts `json:"capacity"`
+	Allocatable ResourceAmounts `json:"allocatable"`
+	Used        ResourceAmounts `json:"used"`
+	Requested   ResourceAmounts `json:"requested"`
+	Limited     ResourceAmounts `json:"limited"`
+}
+
+type NodeListV2 struct {
+	Items []NodeV2 `json:"items"`
+}
+
+// ClusterClassResourceV2 集群中某一类节点的汇总
+type ClusterClassResourceV2 struct {
+	Class       string          `json:"class"`
+	OS          string          `json:"os"`
+	Arch        string          `json:"arch"`
+	Nodes       int             `json:"nodes"`
+	Capacity    ResourceAmounts `json:"capacity"`
+	Allocatable ResourceAmounts `json:"allocatable"`
+	Used        ResourceAmounts `json:"used"`
+	Requested   ResourceAmounts `json:"requested"`
+	Limited     ResourceAmounts `json:"limited"`
+}
+
+type ClusterResourceV2 struct {
+	Classes []ClusterClassResourceV2 `json:"classes"`
+}
+
+// EnvClassResourceV2 环境在某一类节点上的资源
+type EnvClassResourceV2 struct {
+	Class     string          `json:"class"`
+	OS        string          `json:"os"`
+	Arch      string          `json:"arch"`
+	Used      ResourceAmounts `json:"used"`
+	Requested ResourceAmounts `json:"requested"`
+	Limited   ResourceAmounts `json:"limited"`
+}
+
+type EnvResourceV2 struct {
+	Dept    string               `json:"dept"`
+	EnvName string               `json:"envName"`
+	Pods    int                  `json:"pods"`
+	Classes []EnvClassResourceV2 `json:"classes"`
+}
@@ -0,0 +1,51 @@
+package model
+
+// 对外 HTTP 接口路径，服务端路由注册、OpenAPI 文档与 Go 客户端共用同一份定义
+const (
+	APIV1Prefix = "/informer/v1"
+
+	GetWorkloadInstancePath = APIV1Prefix + "/getWorkloadInstance"
+	DeptCheckLimitPath      = APIV1Prefix + "/resource/dept/checkLimit"
+	DeptCheckManifestPath   = APIV1Prefix + "/resource/dept/checkLimit/manifest"
+	DeptReservationsPath    = APIV1Prefix + "/resource/dept/reservations"
+	// DeptReservationPath 单条预留，":id" 为预留 ID
+	DeptReservationPath = DeptReservationsPath + "/:id"
+	// AuditRecordsPath 查询近期审计记录
+	AuditRecordsPath = APIV1Prefix + "/audit"
+	// CostReportPath 部门成本报表
+	CostReportPath      = APIV1Prefix + "/cost"
+	NodeResourcePath    = APIV1Prefix + "/resource/node"
+	DeptResourcePath    = APIV1Prefix + "/resource/dept"
+	ClusterResourcePath = APIV1Prefix + "/resource/cluster"
+	EnvResourcePath     = APIV1Prefix + "/resource/env"
+
+	APIV2Prefix = "/informer/v2"
+
+	NodeResourceV2Path    = APIV2Prefix + "/resource/node"
+	DeptResourceV2Path    = APIV2Prefix + "/resource/dept"
+	ClusterResourceV2Path = APIV2Prefix + "/resource/cluster"
+	EnvResourceV2Path     = APIV2Prefix + "/resource/env"
+
+	// AdmissionValidatePath 准入 webhook 路径，由独立的 TLS 端口提供
+	AdmissionValidatePath = "/admission/validate"
+
+	// AdminLogLevelPath 查询与调整运行时日志级别
+	AdminLogLevelPath = "/admin/log-level"
+
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+
+	MetricsPath     = "/metrics"
+	OpenAPIPath     = "/openapi.json"
+	SwaggerUIPrefix = "/swagger"
+)
+
+// 资源查询接口的缓存响应头
+const (
+	// SnapshotVersionHeader 快照版本，单调递增，可作为 minVersion 参数
+	SnapshotVersionHeader = "X-Snapshot-Version"
+	// GeneratedAtHeader 快照生成时间（RFC3339，精确到秒）
+	GeneratedAtHeader = "X-Generated-At"
+	// CacheHeader HIT 或 MISS
+	CacheHeader = "X-Cache"
+)
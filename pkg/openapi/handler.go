@@ -0,0 +1,64 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// SpecHandler 以 JSON 返回 OpenAPI 文档，文档在首次请求前序列化一次
+func SpecHandler(doc *Document) gin.HandlerFunc {
+	body, err := json.MarshalIndent(doc, "", "  ")
+	return func(c *gin.Context) {
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
+
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: %q,
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    presets: [
+      SwaggerUIBundle.presets.apis,
+      SwaggerUIStandalonePreset
+    ],
+    plugins: [
+      SwaggerUIBundle.plugins.DownloadUrl
+    ],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// RegisterSwaggerUI 在 prefix 下挂载内置的 Swagger UI 静态资源，页面默认加载 specURL
+func RegisterSwaggerUI(r gin.IRoutes, prefix, specURL string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	initializer := fmt.Sprintf(swaggerInitializer, specURL)
+	index, _ := fs.ReadFile(swaggerFiles.FS, "index.html")
+	fileServer := http.StripPrefix(prefix, http.FileServer(http.FS(swaggerFiles.FS)))
+
+	r.GET(prefix, func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, prefix+"/")
+	})
+	r.GET(prefix+"/*filepath", func(c *gin.Context) {
+		switch c.Param("filepath") {
+		case "/", "/index.html":
+			// http.FileServer 会把 index.html 重定向回目录，这里直接返回页面内容
+			c.Data(http.StatusOK, "text/html; charset=utf-8", index)
+		case "/swagger-initializer.js":
+			c.Data(http.StatusOK, "application/javascript; charset=utf-8", []byte(initializer))
+		default:
+			fileServer.ServeHTTP(c.Writer, c.Request)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Schema OpenAPI 3 schema 对象，仅包含本服务用到的字段
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Example              interface{}        `json:"example,omitempty"`
+}
+
+var (
+	quantityType = reflect.TypeOf(resource.Quantity{})
+	timeType     = reflect.TypeOf(time.Time{})
+	rawJSONType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaRegistry 通过反射把 Go 类型转换为 schema，具名结构体统一放入 components 并以 $ref 引用，
+// 这样文档中的结构始终与 pkg/model 中的定义保持一致
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// SchemaOf 返回值 v 对应类型的 schema，v 为 nil 时返回 nil
+func (r *schemaRegistry) SchemaOf(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+	return r.schemaFor(reflect.TypeOf(v))
+}
+
+func (r *schemaRegistry) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case quantityType:
+		return &Schema{Type: "string", Description: "Kubernetes quantity, e.g. 512Mi, 2, 500m", Example: "512Mi"}
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawJSONType:
+		return &Schema{Description: "任意 JSON 值"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		return r.ref(t)
+	default:
+		// interface{} 等无法静态确定的类型不做约束
+		return &Schema{}
+	}
+}
+
+// ref 注册具名结构体并返回对它的引用
+func (r *schemaRegistry) ref(t reflect.Type) *Schema {
+	if name, ok := r.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	name := t.Name()
+	if _, taken := r.schemas[name]; taken {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	r.names[t] = name
+	// 先占位，避免自引用类型无限递归
+	r.schemas[name] = &Schema{}
+	*r.schemas[name] = *r.structSchema(t)
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	r.collectFields(t, s)
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+	return s
+}
+
+// collectFields 按 encoding/json 的规则收集字段，匿名嵌入且无 tag 的结构体字段会被展开
+func (r *schemaRegistry) collectFields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.collectFields(ft, s)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = r.schemaFor(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package openapi
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Document OpenAPI 3 文档根对象
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem 以小写 HTTP 方法为键
+type PathItem map[string]*Operation
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+type Operation struct {
+	OperationID string              `json:"operationId,omitempty"`
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Param 描述一个 query 参数
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+	// Type 为 OpenAPI 基础类型，默认 string
+	Type string
+}
+
+// Route 描述一条已注册路由的文档信息，Request/Responses 中的值仅用于反射出 schema
+type Route struct {
+	Method string
+	// Path 使用 gin 的路由语法，":name" 段会转换为 OpenAPI 的路径参数 {name}
+	Path        string
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Query       []Param
+	Request     interface{}
+	// Responses 以 HTTP 状态码为键，值为响应体样例类型，nil 表示无 JSON 响应体
+	Responses map[int]interface{}
+	// ContentType 响应体类型，默认 application/json
+	ContentType string
+}
+
+// Build 根据路由描述生成 OpenAPI 文档
+func Build(info Info, routes []Route) *Document {
+	reg := newSchemaRegistry()
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+	}
+
+	for _, rt := range routes {
+		op := &Operation{
+			OperationID: rt.OperationID,
+			Summary:     rt.Summary,
+			Description: rt.Description,
+			Tags:        rt.Tags,
+			Responses:   make(map[string]Response),
+		}
+		path, pathParams := specPath(rt.Path)
+		for _, name := range pathParams {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+		for _, p := range rt.Query {
+			typ := p.Type
+			if typ == "" {
+				typ = "string"
+			}
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        p.Name,
+				In:          "query",
+				Description: p.Description,
+				Required:    p.Required,
+				Schema:      &Schema{Type: typ},
+			})
+		}
+		if rt.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: reg.SchemaOf(rt.Request)}},
+			}
+		}
+
+		contentType := rt.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		codes := make([]int, 0, len(rt.Responses))
+		for code := range rt.Responses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			resp := Response{Description: http.StatusText(code)}
+			if body := rt.Responses[code]; body != nil {
+				resp.Content = map[string]MediaType{contentType: {Schema: reg.SchemaOf(body)}}
+			} else if contentType != "application/json" {
+				resp.Content = map[string]MediaType{contentType: {}}
+			}
+			op.Responses[strconv.Itoa(code)] = resp
+		}
+
+		item := doc.Paths[path]
+		if item == nil {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+
+	doc.Components.Schemas = reg.schemas
+	return doc
+}
+
+// specPath 把 gin 路由中的 ":name" 段转换为 "{name}"，并返回参数名
+func specPath(ginPath string) (string, []string) {
+	segments := strings.Split(ginPath, "/")
+	var params []string
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") {
+			params = append(params, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// Has 判断文档中是否描述了指定方法与路径，path 使用 gin 的路由语法
+func (d *Document) Has(method, path string) bool {
+	path, _ = specPath(path)
+	item, ok := d.Paths[path]
+	if !ok {
+		return false
+	}
+	_, ok = item[strings.ToLower(method)]
+	return ok
+}
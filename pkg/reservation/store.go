@@ -0,0 +1,392 @@
+// Package reservation 维护部门配额预留：检查通过后可以在 TTL 内预留申请量，
+// 后续检查会把未过期的预留计入已用，避免两条流水线同时通过检查后共同超出配额。
+// 预留在显式释放、过期或对应 Pod 出现在缓存中时释放，并持久化到 ConfigMap 以便重启后恢复。
+package reservation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+
+	"k8s-admin-informer/pkg/logging"
+)
+
+// dataKey ConfigMap 中保存预留列表的键
+const dataKey = "reservations.json"
+
+// Reservation 一条配额预留
+type Reservation struct {
+	ID   string `json:"id"`
+	Dept string `json:"dept"`
+	// Requests 以节点类型为键的预留量
+	Requests map[string]v1.ResourceList `json:"requests"`
+	// Namespace/Release/Pods 为自动释放条件：命名空间内 release 标签匹配、且在预留之后创建的 Pod 数达到 Pods 时释放
+	Namespace string    `json:"namespace,omitempty"`
+	Release   string    `json:"release,omitempty"`
+	Pods      int32     `json:"pods,omitempty"`
+	Seen      []string  `json:"seen,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// autoRelease 是否设置了自动释放条件
+func (r *Reservation) autoRelease() bool {
+	return r.Namespace != "" && r.Release != "" && r.Pods > 0
+}
+
+// Config 预留存储配置
+type Config struct {
+	Namespace     string
+	ConfigMapName string
+	DefaultTTL    time.Duration
+	MaxTTL        time.Duration
+}
+
+// Store 并发安全的预留存储
+type Store struct {
+	cfg    Config
+	client kubernetes.Interface
+
+	mu    sync.Mutex
+	items map[string]*Reservation
+	// credited 已抵扣过预留的 Pod UID 与抵扣时间，避免同一个 Pod 的后续事件再抵扣其他预留
+	credited map[string]time.Time
+	dirty    chan struct{}
+	now      func() time.Time
+}
+
+// NewStore 创建预留存储，client 为 nil 时只保存在内存中
+func NewStore(client kubernetes.Interface, cfg Config) *Store {
+	return &Store{
+		cfg:      cfg,
+		client:   client,
+		items:    make(map[string]*Reservation),
+		credited: make(map[string]time.Time),
+		dirty:    make(chan struct{}, 1),
+		now:      time.Now,
+	}
+}
+
+// Start 从 ConfigMap 恢复预留，并启动持久化与过期清理协程
+func (s *Store) Start(stopCh <-chan struct{}) {
+	if err := s.load(); err != nil {
+		log.Errorf("恢复配额预留失败: %v", err)
+	}
+	go s.flushLoop(stopCh)
+	go s.expireLoop(stopCh)
+}
+
+// TTL 规范化调用方指定的 TTL：0 取默认值，超过上限按上限
+func (s *Store) TTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		ttl = s.cfg.DefaultTTL
+	}
+	if s.cfg.MaxTTL > 0 && ttl > s.cfg.MaxTTL {
+		ttl = s.cfg.MaxTTL
+	}
+	return ttl
+}
+
+// Owner 被校验对象所在的命名空间与可能的 release 名称（工作负载名、Pod 的 release 标签），
+// 用于识别此前为该对象本身预留的额度
+type Owner struct {
+	Namespace string
+	Releases  []string
+}
+
+// owns 预留的自动释放条件是否指向该对象
+func (o *Owner) owns(r *Reservation) bool {
+	if o == nil || r.Namespace == "" || r.Namespace != o.Namespace {
+		return false
+	}
+	for _, release := range o.Releases {
+		if release != "" && release == r.Release {
+			return true
+		}
+	}
+	return false
+}
+
+// Reserve 在同一把锁内完成“读取部门未过期预留 → 校验 → 写入预留”，保证并发检查不会同时通过。
+// check 收到部门当前预留量之和，返回 nil 表示不预留（校验未通过或调用方未要求预留）；
+// owner 非 nil 时不计入为其预留的额度，避免对象本身的申请与它的预留被重复计算
+func (s *Store) Reserve(dept string, ttl time.Duration, owner *Owner, check func(reserved map[string]v1.ResourceList) *Reservation) *Reservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+
+	r := check(s.reservedLocked(dept, owner))
+	if r == nil {
+		return nil
+	}
+	now := s.now()
+	r.ID = string(uuid.NewUUID())
+	r.Dept = dept
+	r.CreatedAt = now
+	r.ExpiresAt = now.Add(s.TTL(ttl))
+	s.items[r.ID] = r
+	s.markDirty()
+	log.WithFields(log.Fields{logging.FieldDept: dept, "reservation": r.ID}).Infof("部门 %s 新增配额预留 %s，过期时间 %s", dept, r.ID, r.ExpiresAt.Format(time.RFC3339))
+	return copyReservation(r)
+}
+
+// Reserved 返回部门未过期预留量之和
+func (s *Store) Reserved(dept string) map[string]v1.ResourceList {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	return s.reservedLocked(dept, nil)
+}
+
+func (s *Store) reservedLocked(dept string, owner *Owner) map[string]v1.ResourceList {
+	out := make(map[string]v1.ResourceList)
+	for _, r := range s.items {
+		if r.Dept != dept || owner.owns(r) {
+			continue
+		}
+		for class, list := range r.Requests {
+			sum := out[class]
+			if sum == nil {
+				sum = v1.ResourceList{}
+				out[class] = sum
+			}
+			for name, q := range list {
+				cur := sum[name]
+				cur.Add(q)
+				sum[name] = cur
+			}
+		}
+	}
+	return out
+}
+
+// List 返回未过期的预留，dept 为空时返回全部，按创建时间排序
+func (s *Store) List(dept string) []Reservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	out := make([]Reservation, 0, len(s.items))
+	for _, r := range s.items {
+		if dept == "" || r.Dept == dept {
+			out = append(out, *copyReservation(r))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// Get 返回未过期的预留，不存在时返回 false
+func (s *Store) Get(id string) (Reservation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	r, ok := s.items[id]
+	if !ok {
+		return Reservation{}, false
+	}
+	return *copyReservation(r), true
+}
+
+// Release 显式释放预留，不存在时返回 false
+func (s *Store) Release(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.items[id]
+	if !ok {
+		return false
+	}
+	delete(s.items, id)
+	s.markDirty()
+	log.WithFields(log.Fields{logging.FieldDept: r.Dept, "reservation": id}).Infof("配额预留 %s 已释放", id)
+	return true
+}
+
+// ObservePod 把在预留之后创建、且匹配自动释放条件的 Pod 记到最早的一条未满的预留上，
+// Pod 数达到预期时释放该预留；同一个 Pod 只抵扣一条预留
+func (s *Store) ObservePod(pod *v1.Pod) {
+	release := pod.Labels["release"]
+	if release == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.credited[string(pod.UID)]; ok {
+		return
+	}
+	var target *Reservation
+	for _, r := range s.items {
+		if !r.autoRelease() || r.Namespace != pod.Namespace || r.Release != release {
+			continue
+		}
+		if pod.CreationTimestamp.Time.Before(r.CreatedAt.Truncate(time.Second)) {
+			continue
+		}
+		if target == nil || r.CreatedAt.Before(target.CreatedAt) {
+			target = r
+		}
+	}
+	if target == nil {
+		return
+	}
+	target.Seen = append(target.Seen, string(pod.UID))
+	s.credited[string(pod.UID)] = s.now()
+	s.markDirty()
+	if int32(len(target.Seen)) >= target.Pods {
+		delete(s.items, target.ID)
+		log.WithFields(log.Fields{logging.FieldDept: target.Dept, "reservation": target.ID}).Infof("配额预留 %s 对应的 %d 个 Pod 已创建，自动释放", target.ID, target.Pods)
+	}
+}
+
+// pruneLocked 删除已过期的预留，以及早于最长有效期的抵扣记录
+func (s *Store) pruneLocked() {
+	now := s.now()
+	for id, r := range s.items {
+		if !now.Before(r.ExpiresAt) {
+			delete(s.items, id)
+			s.markDirty()
+			log.WithFields(log.Fields{logging.FieldDept: r.Dept, "reservation": id}).Infof("配额预留 %s 已过期", id)
+		}
+	}
+	keep := s.cfg.MaxTTL
+	if keep <= 0 {
+		keep = time.Hour
+	}
+	for uid, at := range s.credited {
+		if now.Sub(at) > keep {
+			delete(s.credited, uid)
+		}
+	}
+}
+
+func (s *Store) markDirty() {
+	select {
+	case s.dirty <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Store) expireLoop(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.pruneLocked()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// flushLoop 合并变更后写入 ConfigMap
+func (s *Store) flushLoop(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-s.dirty:
+			if err := s.save(); err != nil {
+				log.Errorf("持久化配额预留失败: %v", err)
+				// 稍后重试
+				time.AfterFunc(5*time.Second, s.markDirty)
+			}
+		}
+	}
+}
+
+func (s *Store) snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*Reservation, 0, len(s.items))
+	for _, r := range s.items {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return json.Marshal(list)
+}
+
+func (s *Store) save() error {
+	if s.client == nil {
+		return nil
+	}
+	data, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+	cms := s.client.CoreV1().ConfigMaps(s.cfg.Namespace)
+	cm, err := cms.Get(context.TODO(), s.cfg.ConfigMapName, metaV1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = cms.Create(context.TODO(), &v1.ConfigMap{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      s.cfg.ConfigMapName,
+				Namespace: s.cfg.Namespace,
+				Labels:    map[string]string{"app.kubernetes.io/name": "k8s-admin-informer"},
+			},
+			Data: map[string]string{dataKey: string(data)},
+		}, metaV1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[dataKey] = string(data)
+	_, err = cms.Update(context.TODO(), cm, metaV1.UpdateOptions{})
+	return err
+}
+
+func (s *Store) load() error {
+	if s.client == nil {
+		return nil
+	}
+	cm, err := s.client.CoreV1().ConfigMaps(s.cfg.Namespace).Get(context.TODO(), s.cfg.ConfigMapName, metaV1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	raw := cm.Data[dataKey]
+	if raw == "" {
+		return nil
+	}
+	var list []*Reservation
+	if err := json.Unmarshal([]byte(raw), &list); err != nil {
+		return fmt.Errorf("解析 ConfigMap %s/%s 失败: %v", s.cfg.Namespace, s.cfg.ConfigMapName, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range list {
+		s.items[r.ID] = r
+		for _, uid := range r.Seen {
+			s.credited[uid] = r.CreatedAt
+		}
+	}
+	s.pruneLocked()
+	log.Infof("从 ConfigMap %s/%s 恢复 %d 条配额预留", s.cfg.Namespace, s.cfg.ConfigMapName, len(s.items))
+	return nil
+}
+
+func copyReservation(r *Reservation) *Reservation {
+	out := *r
+	out.Requests = make(map[string]v1.ResourceList, len(r.Requests))
+	for class, list := range r.Requests {
+		out.Requests[class] = list.DeepCopy()
+	}
+	out.Seen = append([]string(nil), r.Seen...)
+	return &out
+}
@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "k8s-admin-informer"
+
+// 导出方式
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// Init 按环境变量初始化全局 TracerProvider 与 W3C 传播器，返回的函数在退出前刷新并关闭导出器：
+// - OTEL_TRACES_EXPORTER none（默认，不导出）、otlp（OTLP/HTTP，地址等由 OTEL_EXPORTER_OTLP_* 配置）、stdout 或 file
+// - OTEL_TRACES_FILE file 导出器写入的文件，默认 traces.jsonl
+// - OTEL_SERVICE_NAME 服务名，默认 k8s-admin-informer
+// - 采样由 OTEL_TRACES_SAMPLER / OTEL_TRACES_SAMPLER_ARG 配置，默认 parentbased_always_on
+func Init() func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporterName := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER"))
+	if exporterName == "" || exporterName == ExporterNone {
+		return func(context.Context) error { return nil }
+	}
+	exporter, err := newExporter(exporterName)
+	if err != nil {
+		log.Errorf("初始化 trace 导出器 %s 失败，不导出 trace: %v", exporterName, err)
+		return func(context.Context) error { return nil }
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = instrumentationName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		res = resource.Default()
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	log.Infof("trace 导出器: %s", exporterName)
+	return tp.Shutdown
+}
+
+func newExporter(name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case ExporterOTLP:
+		return otlptracehttp.New(context.Background())
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		path := os.Getenv("OTEL_TRACES_FILE")
+		if path == "" {
+			path = "traces.jsonl"
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(f))
+	}
+	return nil, fmt.Errorf("未知的 OTEL_TRACES_EXPORTER %q", name)
+}
+
+// Tracer 返回本服务的 tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start 创建子 span，是 Tracer().Start 的简写
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Middleware 为每个 gin 请求创建服务端 span，名称为 "方法 路由模板"，并从请求头提取上游 trace 上下文
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx, span := Tracer().Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+			))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		if span.SpanContext().IsValid() {
+			c.Header("X-Trace-Id", span.SpanContext().TraceID().String())
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// WrapTransport 为出站 HTTP 请求创建客户端 span，用于 rest.Config.Wrap 与 Prometheus 客户端；
+// informer 的长连接 watch 不创建 span
+func WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	traced := otelhttp.NewTransport(rt, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + r.URL.Path
+	}))
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("watch") == "true" {
+			return rt.RoundTrip(req)
+		}
+		return traced.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// LogHook 为带 context 的日志（log.WithContext）补充 trace_id 与 span_id 字段
+type LogHook struct{}
+
+func (LogHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (LogHook) Fire(entry *log.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	sc := trace.SpanContextFromContext(entry.Context)
+	if !sc.IsValid() {
+		return nil
+	}
+	entry.Data["trace_id"] = sc.TraceID().String()
+	entry.Data["span_id"] = sc.SpanID().String()
+	return nil
+}
+
+// Shutdown 在超时内关闭导出器，错误只记录日志
+func Shutdown(shutdown func(context.Context) error, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		log.Errorf("关闭 trace 导出器失败: %v", err)
+	}
+}